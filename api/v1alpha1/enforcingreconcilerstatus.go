@@ -9,6 +9,23 @@ type Conditions []metav1.Condition
 // +mapType=granular
 type ConditionMap map[string]Conditions
 
+// DriftRecord records the correction of a locked resource that had drifted from its desired state
+type DriftRecord struct {
+	// RevertedAt is the time at which the drift was corrected
+	RevertedAt metav1.Time `json:"revertedAt"`
+
+	// ChangedBy is the field manager that last modified the resource before the correction, if known
+	// +kubebuilder:validation:Optional
+	ChangedBy string `json:"changedBy,omitempty"`
+
+	// Summary describes the paths that had drifted
+	Summary string `json:"summary"`
+}
+
+// DriftHistory contains the most recent drift corrections of a locked resource, oldest first
+// +listType=atomic
+type DriftHistory []DriftRecord
+
 // EnforcingReconcileStatus represents the status of the last reconcile cycle. It's used to communicate success or failure and the error message
 type EnforcingReconcileStatus struct {
 
@@ -25,6 +42,10 @@ type EnforcingReconcileStatus struct {
 	//LockedResourceStatuses contains the reconcile status for each of the managed resources
 	// +kubebuilder:validation:Optional
 	LockedPatchStatuses map[string]ConditionMap `json:"lockedPatchStatuses,omitempty"`
+
+	//LockedResourceDriftHistories contains the most recent drift corrections for each of the managed resources that has been corrected
+	// +kubebuilder:validation:Optional
+	LockedResourceDriftHistories map[string]DriftHistory `json:"lockedResourceDriftHistories,omitempty"`
 }
 
 // EnforcingReconcileStatusAware is an interfce that must be implemented by a CRD type that has been enabled with ReconcileStatus, it can then benefit of a series of utility methods.
@@ -59,8 +59,47 @@ type PatchSpec struct {
 	// PatchTemplate is a go template that will be resolved using the SourceObjectRefs as parameters. The result must be a valid patch based on the pacth type and the target object.
 	// +kubebuilder:validation:Required
 	PatchTemplate string `json:"patchTemplate,omitempty"`
+
+	// Mode determines whether drift is corrected (Enforce), only reported (Audit) or ignored (Disabled). Defaults to Enforce. CreateOnly and AdoptExisting are not valid for patches.
+	// +kubebuilder:validation:Optional
+	Mode EnforcementMode `json:"mode,omitempty"`
+
+	// SourceDeletionPolicy determines what happens to the targets when a source object of the patch is deleted. Keep (the default) leaves the patched fields on the targets, Revert restores the values they had before being patched.
+	// In both cases the targets get a SourceMissing condition until the source is recreated.
+	// With Revert, the original values are recorded in the redhat-cop.io/lockedpatch-original-values annotation of the targets when the patch is first applied.
+	// Revert is supported for the merge and strategic merge patch types only. Lists are recorded and restored as a whole.
+	// +kubebuilder:validation:Optional
+	SourceDeletionPolicy SourceDeletionPolicy `json:"sourceDeletionPolicy,omitempty"`
+
+	// RemovalPolicy determines what happens to the targets when the patch is removed or its parent is terminated. Keep (the default) leaves the patched fields on the targets, Revert restores the values they had before being patched.
+	// With Revert, the original values are recorded in the redhat-cop.io/lockedpatch-original-values annotation of the targets when the patch is first applied.
+	// Revert is supported for the merge and strategic merge patch types only. Lists are recorded and restored as a whole.
+	// +kubebuilder:validation:Optional
+	RemovalPolicy PatchRemovalPolicy `json:"removalPolicy,omitempty"`
 }
 
+// SourceDeletionPolicy determines what is done to the targets of a patch when one of its source objects is deleted
+// +kubebuilder:validation:Enum=Keep;Revert
+type SourceDeletionPolicy string
+
+const (
+	// SourceDeletionPolicyKeep leaves the patched fields on the targets, this is the default
+	SourceDeletionPolicyKeep SourceDeletionPolicy = "Keep"
+	// SourceDeletionPolicyRevert restores the values the patched fields had on the targets before being patched
+	SourceDeletionPolicyRevert SourceDeletionPolicy = "Revert"
+)
+
+// PatchRemovalPolicy determines what is done to the targets of a patch when the patch is no longer enforced
+// +kubebuilder:validation:Enum=Keep;Revert
+type PatchRemovalPolicy string
+
+const (
+	// PatchRemovalPolicyKeep leaves the patched fields on the targets, this is the default
+	PatchRemovalPolicyKeep PatchRemovalPolicy = "Keep"
+	// PatchRemovalPolicyRevert restores the values the patched fields had before the patch was applied
+	PatchRemovalPolicyRevert PatchRemovalPolicy = "Revert"
+)
+
 type TargetObjectReference struct {
 	// API version of the referent.
 	// +kubebuilder:validation:Required
@@ -97,7 +136,7 @@ func (t *TargetObjectReference) getAPIReourceForGVK(context context.Context) (*m
 		return t.apiResource, true, nil
 	}
 	apiresource, found, err := discoveryclient.GetAPIResourceForGVK(context, schema.FromAPIVersionAndKind(t.APIVersion, t.Kind))
-	if err != nil && found {
+	if err == nil && found {
 		t.apiResource = apiresource
 	}
 	return apiresource, found, err
@@ -351,7 +390,7 @@ func (t *SourceObjectReference) getAPIReourceForGVK(context context.Context) (*m
 		return t.apiResource, true, nil
 	}
 	apiresource, found, err := discoveryclient.GetAPIResourceForGVK(context, schema.FromAPIVersionAndKind(t.APIVersion, t.Kind))
-	if err != nil && found {
+	if err == nil && found {
 		t.apiResource = apiresource
 	}
 	return apiresource, found, err
@@ -4,6 +4,48 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// EnforcementMode determines what is done when a locked resource or patch drifts from its desired state
+// +kubebuilder:validation:Enum=Enforce;Audit;Disabled;CreateOnly;AdoptExisting
+type EnforcementMode string
+
+const (
+	// EnforcementModeEnforce corrects drift, this is the default
+	EnforcementModeEnforce EnforcementMode = "Enforce"
+	// EnforcementModeAudit reports drift with a Drifted condition and an event, but never writes to the cluster
+	EnforcementModeAudit EnforcementMode = "Audit"
+	// EnforcementModeDisabled ignores the resource or patch
+	EnforcementModeDisabled EnforcementMode = "Disabled"
+	// EnforcementModeCreateOnly creates the resource when it does not exist, but never corrects its drift. It is valid for locked resources only.
+	EnforcementModeCreateOnly EnforcementMode = "CreateOnly"
+	// EnforcementModeAdoptExisting corrects drift like Enforce, but refuses to overwrite an existing object that is not owned by the parent, unless the object carries the redhat-cop.io/adopt annotation set to "true".
+	// It is valid for locked resources only.
+	EnforcementModeAdoptExisting EnforcementMode = "AdoptExisting"
+)
+
+// DeletionPolicy determines what is done to a locked resource when it is no longer enforced
+// +kubebuilder:validation:Enum=Delete;Orphan;OrphanAndUnlabel
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the resource, this is the default
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan leaves the resource in place
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+	// DeletionPolicyOrphanAndUnlabel leaves the resource in place, but removes the ownership label and annotations, so that it is no longer considered for pruning
+	DeletionPolicyOrphanAndUnlabel DeletionPolicy = "OrphanAndUnlabel"
+)
+
+// PropagationPolicy determines how the dependents of a locked resource are deleted
+// +kubebuilder:validation:Enum=Foreground;Background
+type PropagationPolicy string
+
+const (
+	// PropagationPolicyForeground deletes the dependents before the resource
+	PropagationPolicyForeground PropagationPolicy = "Foreground"
+	// PropagationPolicyBackground deletes the resource immediately and the dependents in the background
+	PropagationPolicyBackground PropagationPolicy = "Background"
+)
+
 // LockedResource represents a resource to be enforced in a LockedResourceController and can be used in a API specification
 // +k8s:openapi-gen=true
 type LockedResource struct {
@@ -16,6 +58,24 @@ type LockedResource struct {
 	// +kubebuilder:validation:Optional
 	// +listType=set
 	ExcludedPaths []string `json:"excludedPaths,omitempty"`
+
+	// Mode determines whether drift is corrected (Enforce), only reported (Audit) or ignored (Disabled). Defaults to Enforce.
+	// CreateOnly only creates the resource if it does not exist, AdoptExisting enforces it but refuses to take over existing objects that are not owned by the parent.
+	// +kubebuilder:validation:Optional
+	Mode EnforcementMode `json:"mode,omitempty"`
+
+	// DependsOn are references to other locked resources of the same parent, this resource is enforced only after all of them have been reconciled successfully.
+	// +kubebuilder:validation:Optional
+	// +listType=atomic
+	DependsOn []LockedResourceReference `json:"dependsOn,omitempty"`
+
+	// DeletionPolicy determines what is done to the resource when it is no longer enforced or when the enforcing of its parent is terminated with deletion. Defaults to Delete.
+	// +kubebuilder:validation:Optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// PropagationPolicy determines how the dependents of the resource are deleted, when it is deleted. Defaults to the default of the resource type.
+	// +kubebuilder:validation:Optional
+	PropagationPolicy PropagationPolicy `json:"propagationPolicy,omitempty"`
 }
 
 // LockedResourceTemplate represents a resource template in go language to be enforced in a LockedResourceController and can be used in a API specification
@@ -26,8 +86,76 @@ type LockedResourceTemplate struct {
 	// +kubebuilder:validation:Required
 	ObjectTemplate string `json:"objectTemplate"`
 
+	// Strict makes references to missing keys errors, instead of rendering them as <no value>, and rejects the rendered resources that do not conform to their schema, including unknown fields.
+	// +kubebuilder:validation:Optional
+	Strict bool `json:"strict,omitempty"`
+
 	// ExludedPaths are a set of json paths that need not be considered by the LockedResourceReconciler
 	// +kubebuilder:validation:Optional
 	// +listType=set
 	ExcludedPaths []string `json:"excludedPaths,omitempty"`
+
+	// Mode determines whether drift is corrected (Enforce), only reported (Audit) or ignored (Disabled). Defaults to Enforce.
+	// CreateOnly only creates the resource if it does not exist, AdoptExisting enforces it but refuses to take over existing objects that are not owned by the parent.
+	// +kubebuilder:validation:Optional
+	Mode EnforcementMode `json:"mode,omitempty"`
+
+	// DependsOn are references to other locked resources of the same parent, this resource is enforced only after all of them have been reconciled successfully.
+	// +kubebuilder:validation:Optional
+	// +listType=atomic
+	DependsOn []LockedResourceReference `json:"dependsOn,omitempty"`
+
+	// DeletionPolicy determines what is done to the resource when it is no longer enforced or when the enforcing of its parent is terminated with deletion. Defaults to Delete.
+	// +kubebuilder:validation:Optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// PropagationPolicy determines how the dependents of the resource are deleted, when it is deleted. Defaults to the default of the resource type.
+	// +kubebuilder:validation:Optional
+	PropagationPolicy PropagationPolicy `json:"propagationPolicy,omitempty"`
+}
+
+// TemplateLibrary is a set of named templates, declared with define blocks, that the templates using the library can invoke with include and template
+// +k8s:openapi-gen=true
+type TemplateLibrary struct {
+	// ConfigMapRefs are references to ConfigMaps whose data entries are sources of named templates. They are loaded in order, and by key within a ConfigMap.
+	// +kubebuilder:validation:Optional
+	// +listType=atomic
+	ConfigMapRefs []ConfigMapReference `json:"configMapRefs,omitempty"`
+
+	// Templates are sources of named templates, by a name that identifies them in errors. They are loaded by name, after the ConfigMaps.
+	// Named templates defined later replace the ones with the same name defined before.
+	// +kubebuilder:validation:Optional
+	Templates map[string]string `json:"templates,omitempty"`
+}
+
+// ConfigMapReference identifies a ConfigMap
+// +k8s:openapi-gen=true
+type ConfigMapReference struct {
+	// Name of the referenced ConfigMap
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace of the referenced ConfigMap, defaults to the namespace of the referencing object
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// LockedResourceReference identifies a locked resource among the locked resources of the same parent
+// +k8s:openapi-gen=true
+type LockedResourceReference struct {
+	// API version of the referenced resource
+	// +kubebuilder:validation:Required
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the referenced resource
+	// +kubebuilder:validation:Required
+	Kind string `json:"kind"`
+
+	// Namespace of the referenced resource, empty for cluster level resources
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the referenced resource
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
 }
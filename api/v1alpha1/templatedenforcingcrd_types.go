@@ -31,6 +31,10 @@ type TemplatedEnforcingCRDSpec struct {
 	// +kubebuilder:validation:Optional
 	// +listType=atomic
 	Templates []LockedResourceTemplate `json:"templates,omitempty"`
+
+	// TemplateLibrary are named templates that the templates can invoke with include and template
+	// +kubebuilder:validation:Optional
+	TemplateLibrary *TemplateLibrary `json:"templateLibrary,omitempty"`
 }
 
 // TemplatedEnforcingCRDStatus defines the observed state of TemplatedEnforcingCRD
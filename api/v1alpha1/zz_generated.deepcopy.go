@@ -78,6 +78,58 @@ func (in Conditions) DeepCopy() Conditions {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapReference) DeepCopyInto(out *ConfigMapReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapReference.
+func (in *ConfigMapReference) DeepCopy() *ConfigMapReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in DriftHistory) DeepCopyInto(out *DriftHistory) {
+	{
+		in := &in
+		*out = make(DriftHistory, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftHistory.
+func (in DriftHistory) DeepCopy() DriftHistory {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftHistory)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftRecord) DeepCopyInto(out *DriftRecord) {
+	*out = *in
+	in.RevertedAt.DeepCopyInto(&out.RevertedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftRecord.
+func (in *DriftRecord) DeepCopy() *DriftRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EnforcingCRD) DeepCopyInto(out *EnforcingCRD) {
 	*out = *in
@@ -326,6 +378,23 @@ func (in *EnforcingReconcileStatus) DeepCopyInto(out *EnforcingReconcileStatus)
 			(*out)[key] = outVal
 		}
 	}
+	if in.LockedResourceDriftHistories != nil {
+		in, out := &in.LockedResourceDriftHistories, &out.LockedResourceDriftHistories
+		*out = make(map[string]DriftHistory, len(*in))
+		for key, val := range *in {
+			var outVal []DriftRecord
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(DriftHistory, len(*in))
+				for i := range *in {
+					(*in)[i].DeepCopyInto(&(*out)[i])
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnforcingReconcileStatus.
@@ -347,6 +416,11 @@ func (in *LockedResource) DeepCopyInto(out *LockedResource) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]LockedResourceReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LockedResource.
@@ -359,6 +433,21 @@ func (in *LockedResource) DeepCopy() *LockedResource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LockedResourceReference) DeepCopyInto(out *LockedResourceReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LockedResourceReference.
+func (in *LockedResourceReference) DeepCopy() *LockedResourceReference {
+	if in == nil {
+		return nil
+	}
+	out := new(LockedResourceReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LockedResourceTemplate) DeepCopyInto(out *LockedResourceTemplate) {
 	*out = *in
@@ -367,6 +456,11 @@ func (in *LockedResourceTemplate) DeepCopyInto(out *LockedResourceTemplate) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]LockedResourceReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LockedResourceTemplate.
@@ -548,6 +642,33 @@ func (in *TargetObjectReference) DeepCopy() *TargetObjectReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateLibrary) DeepCopyInto(out *TemplateLibrary) {
+	*out = *in
+	if in.ConfigMapRefs != nil {
+		in, out := &in.ConfigMapRefs, &out.ConfigMapRefs
+		*out = make([]ConfigMapReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateLibrary.
+func (in *TemplateLibrary) DeepCopy() *TemplateLibrary {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateLibrary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TemplatedEnforcingCRD) DeepCopyInto(out *TemplatedEnforcingCRD) {
 	*out = *in
@@ -617,6 +738,11 @@ func (in *TemplatedEnforcingCRDSpec) DeepCopyInto(out *TemplatedEnforcingCRDSpec
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.TemplateLibrary != nil {
+		in, out := &in.TemplateLibrary, &out.TemplateLibrary
+		*out = new(TemplateLibrary)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplatedEnforcingCRDSpec.
@@ -20,7 +20,6 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
-	"github.com/scylladb/go-set/strset"
 	"k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -30,6 +29,7 @@ import (
 	"github.com/redhat-cop/operator-utils/api/v1alpha1"
 	operatorutilsv1alpha1 "github.com/redhat-cop/operator-utils/api/v1alpha1"
 	"github.com/redhat-cop/operator-utils/pkg/util"
+	"github.com/redhat-cop/operator-utils/pkg/util/defaulting"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
@@ -114,23 +114,7 @@ func (r *EnforcingCRDReconciler) manageCleanUpLogic(instance *v1alpha1.Enforcing
 // IsInitialized can be used to check if instance is correctly initialized.
 // returns false it isn't.
 func (r *EnforcingCRDReconciler) IsInitialized(instance *v1alpha1.EnforcingCRD) bool {
-	needsUpdate := false
-	for i := range instance.Spec.Resources {
-		currentSet := strset.New(instance.Spec.Resources[i].ExcludedPaths...)
-		if !currentSet.IsEqual(strset.Union(lockedresource.DefaultExcludedPathsSet, currentSet)) {
-			instance.Spec.Resources[i].ExcludedPaths = strset.Union(lockedresource.DefaultExcludedPathsSet, currentSet).List()
-			needsUpdate = true
-		}
-	}
-	if len(instance.Spec.Resources) > 0 && !util.HasFinalizer(instance, controllerName) {
-		util.AddFinalizer(instance, controllerName)
-		needsUpdate = true
-	}
-	if len(instance.Spec.Resources) == 0 && util.HasFinalizer(instance, controllerName) {
-		util.RemoveFinalizer(instance, controllerName)
-		needsUpdate = true
-	}
-	return !needsUpdate
+	return !defaulting.Default(instance, controllerName)
 }
 
 func (r *EnforcingCRDReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -20,7 +20,6 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
-	"github.com/scylladb/go-set/strset"
 	"k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -30,9 +29,11 @@ import (
 	"github.com/redhat-cop/operator-utils/api/v1alpha1"
 	operatorutilsv1alpha1 "github.com/redhat-cop/operator-utils/api/v1alpha1"
 	"github.com/redhat-cop/operator-utils/pkg/util"
+	"github.com/redhat-cop/operator-utils/pkg/util/defaulting"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"github.com/redhat-cop/operator-utils/pkg/util/templates"
 )
 
 // TemplatedEnforcingCRDReconciler reconciles a TemplatedEnforcingCRD object
@@ -89,11 +90,34 @@ func (r *TemplatedEnforcingCRDReconciler) Reconcile(context context.Context, req
 		return reconcile.Result{}, nil
 	}
 
-	lockedResources, err := lockedresource.GetLockedResourcesFromTemplatesWithRestConfig(instance.Spec.Templates, r.GetRestConfig(), instance)
+	lookupRecorder := templates.NewLookupRecorder()
+	library, err := lockedresource.GetTemplateLibrary(context, r.GetRestConfig(), instance.Spec.TemplateLibrary, instance.Namespace)
 	if err != nil {
-		log.Error(err, "unable to get locked resources")
+		log.Error(err, "unable to load template library")
 		return r.ManageError(context, instance, err)
 	}
+	if instance.Spec.TemplateLibrary != nil {
+		// the templates are processed again when the ConfigMaps of the library change
+		for _, ref := range instance.Spec.TemplateLibrary.ConfigMapRefs {
+			lookupRecorder.Record(templates.LookupRecord{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+				Namespace:  lockedresource.GetConfigMapNamespace(ref, instance.Namespace),
+				Name:       ref.Name,
+			})
+		}
+	}
+	lockedResources, renderErr := lockedresource.GetLockedResourcesFromTemplatesWithLibrary(instance.Spec.Templates, r.GetRestConfig(), library, instance, lookupRecorder)
+	// the lookups are watched even if the templates cannot be processed, as they may fail because a looked up object does not exist yet
+	err = r.WatchLookups(instance, lookupRecorder.Records())
+	if err != nil {
+		log.Error(err, "unable to watch looked up objects")
+		return r.ManageError(context, instance, err)
+	}
+	if renderErr != nil {
+		log.Error(renderErr, "unable to get locked resources")
+		return r.ManageError(context, instance, renderErr)
+	}
 	err = r.UpdateLockedResources(context, instance, lockedResources, []lockedpatch.LockedPatch{})
 	if err != nil {
 		log.Error(err, "unable to update locked resources")
@@ -106,23 +130,7 @@ func (r *TemplatedEnforcingCRDReconciler) Reconcile(context context.Context, req
 // IsInitialized can be used to check if instance is correctly initialized.
 // returns false it isn't.
 func (r *TemplatedEnforcingCRDReconciler) IsInitialized(instance *v1alpha1.TemplatedEnforcingCRD) bool {
-	needsUpdate := true
-	for i := range instance.Spec.Templates {
-		currentSet := strset.New(instance.Spec.Templates[i].ExcludedPaths...)
-		if !currentSet.IsEqual(strset.Union(lockedresource.DefaultExcludedPathsSet, currentSet)) {
-			instance.Spec.Templates[i].ExcludedPaths = strset.Union(lockedresource.DefaultExcludedPathsSet, currentSet).List()
-			needsUpdate = false
-		}
-	}
-	if len(instance.Spec.Templates) > 0 && !util.HasFinalizer(instance, controllerName) {
-		util.AddFinalizer(instance, controllerName)
-		needsUpdate = false
-	}
-	if len(instance.Spec.Templates) == 0 && util.HasFinalizer(instance, controllerName) {
-		util.RemoveFinalizer(instance, controllerName)
-		needsUpdate = false
-	}
-	return needsUpdate
+	return !defaulting.Default(instance, controllerName)
 }
 
 func (r *TemplatedEnforcingCRDReconciler) manageCleanUpLogic(instance *v1alpha1.TemplatedEnforcingCRD) error {
@@ -0,0 +1,85 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	operatorutilsv1alpha1 "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/defaulting"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/webhook"
+)
+
+// +kubebuilder:webhook:path=/mutate-operator-utils-example-io-v1alpha1-enforcingcrd,mutating=true,failurePolicy=fail,sideEffects=None,groups=operator-utils.example.io,resources=enforcingcrds,verbs=create;update,versions=v1alpha1,name=menforcingcrd.operator-utils.example.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/mutate-operator-utils-example-io-v1alpha1-templatedenforcingcrd,mutating=true,failurePolicy=fail,sideEffects=None,groups=operator-utils.example.io,resources=templatedenforcingcrds,verbs=create;update,versions=v1alpha1,name=mtemplatedenforcingcrd.operator-utils.example.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-operator-utils-example-io-v1alpha1-enforcingcrd,mutating=false,failurePolicy=fail,sideEffects=None,groups=operator-utils.example.io,resources=enforcingcrds,verbs=create;update,versions=v1alpha1,name=venforcingcrd.operator-utils.example.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-operator-utils-example-io-v1alpha1-templatedenforcingcrd,mutating=false,failurePolicy=fail,sideEffects=None,groups=operator-utils.example.io,resources=templatedenforcingcrds,verbs=create;update,versions=v1alpha1,name=vtemplatedenforcingcrd.operator-utils.example.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-operator-utils-example-io-v1alpha1-enforcingpatch,mutating=false,failurePolicy=fail,sideEffects=None,groups=operator-utils.example.io,resources=enforcingpatches,verbs=create;update,versions=v1alpha1,name=venforcingpatch.operator-utils.example.io,admissionReviewVersions=v1
+
+// SetupWebhooksWithManager registers the validating webhooks of EnforcingCRD, TemplatedEnforcingCRD and EnforcingPatch and the defaulting webhooks of EnforcingCRD and TemplatedEnforcingCRD
+func SetupWebhooksWithManager(mgr ctrl.Manager) error {
+	defaulter := defaulting.NewLockedResourceDefaulter(controllerName)
+	validator := webhook.NewValidator(mgr.GetConfig(), ctrl.Log.WithName("webhooks"))
+	err := ctrl.NewWebhookManagedBy(mgr).
+		For(&operatorutilsv1alpha1.EnforcingCRD{}).
+		WithDefaulter(defaulter).
+		WithValidator(webhook.ValidatorFunc(func(ctx context.Context, obj runtime.Object) error {
+			instance, ok := obj.(*operatorutilsv1alpha1.EnforcingCRD)
+			if !ok {
+				return fmt.Errorf("expected an EnforcingCRD but got a %T", obj)
+			}
+			return validator.ValidateResources(ctx, instance.Spec.Resources)
+		})).
+		Complete()
+	if err != nil {
+		return err
+	}
+	err = ctrl.NewWebhookManagedBy(mgr).
+		For(&operatorutilsv1alpha1.TemplatedEnforcingCRD{}).
+		WithDefaulter(defaulter).
+		WithValidator(webhook.ValidatorFunc(func(ctx context.Context, obj runtime.Object) error {
+			instance, ok := obj.(*operatorutilsv1alpha1.TemplatedEnforcingCRD)
+			if !ok {
+				return fmt.Errorf("expected a TemplatedEnforcingCRD but got a %T", obj)
+			}
+			library, err := lockedresource.GetTemplateLibrary(ctx, mgr.GetConfig(), instance.Spec.TemplateLibrary, instance.Namespace)
+			if err != nil {
+				return err
+			}
+			// the templates are processed with the instance itself, as the controller does
+			return validator.ValidateResourceTemplates(ctx, instance.Spec.Templates, library, instance)
+		})).
+		Complete()
+	if err != nil {
+		return err
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&operatorutilsv1alpha1.EnforcingPatch{}).
+		WithValidator(webhook.ValidatorFunc(func(ctx context.Context, obj runtime.Object) error {
+			instance, ok := obj.(*operatorutilsv1alpha1.EnforcingPatch)
+			if !ok {
+				return fmt.Errorf("expected an EnforcingPatch but got a %T", obj)
+			}
+			return validator.ValidatePatches(ctx, instance.Spec.Patches)
+		})).
+		Complete()
+}
@@ -19,6 +19,7 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -26,6 +27,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	operatorutilsv1alpha1 "github.com/redhat-cop/operator-utils/api/v1alpha1"
 	"github.com/redhat-cop/operator-utils/controllers"
@@ -98,6 +100,19 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "TemplatedEnforcingCRD")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = controllers.SetupWebhooksWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhooks")
+			os.Exit(1)
+		}
+	}
+	if os.Getenv("ENABLE_LOCK_GUARD") == "true" {
+		exemptUsers := []string{}
+		if users := os.Getenv("LOCK_GUARD_EXEMPT_USERS"); users != "" {
+			exemptUsers = strings.Split(users, ",")
+		}
+		mgr.GetWebhookServer().Register("/validate-locked-objects", &webhook.Admission{Handler: lockedresourcecontroller.NewLockGuard(mgr.GetConfig(), exemptUsers...)})
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
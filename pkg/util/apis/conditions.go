@@ -10,6 +10,25 @@ const ReconcileError = "ReconcileError"
 const ReconcileErrorReason = "LastReconcileCycleFailed"
 const ReconcileSuccess = "ReconcileSuccess"
 const ReconcileSuccessReason = "LastReconcileCycleSucceded"
+const Conflict = "Conflict"
+const ConflictReason = "FieldManagerConflict"
+const NotOwnedReason = "ObjectNotOwned"
+const ClaimConflictReason = "ClaimedByAnotherParent"
+const DependencyConflictReason = "DependencyClaimedByAnotherParent"
+const Drifted = "Drifted"
+const DriftedReason = "DriftDetected"
+const DriftCorrectedReason = "DriftCorrected"
+const SourceMissing = "SourceMissing"
+const SourceMissingReason = "SourceNotFound"
+const Contested = "Contested"
+const ContestedReason = "FieldManagerFight"
+const WaitingForDependencies = "WaitingForDependencies"
+const WaitingForDependenciesReason = "DependenciesNotReconciled"
+const Healthy = "Healthy"
+const HealthCheckFailedReason = "HealthCheckFailed"
+const ResourcesReady = "ResourcesReady"
+const ResourcesReadyReason = "AllResourcesHealthy"
+const ResourcesNotReadyReason = "ResourcesNotHealthy"
 
 // ConditionsAware represents a CRD type that has been enabled with metav1.Conditions, it can then benefit of a series of utility methods.
 type ConditionsAware interface {
@@ -29,6 +48,28 @@ func AddOrReplaceCondition(c metav1.Condition, conditions []metav1.Condition) []
 	return conditions
 }
 
+// RemoveCondition returns the passed array of conditions without the conditions of the given type
+func RemoveCondition(conditionType string, conditions []metav1.Condition) []metav1.Condition {
+	result := []metav1.Condition{}
+	for _, condition := range conditions {
+		if condition.Type != conditionType {
+			result = append(result, condition)
+		}
+	}
+	return result
+}
+
+// RemoveConditionWithReason returns the passed array of conditions without the conditions of the given type that have the given reason, so that conditions of the same type set for different causes are left in place
+func RemoveConditionWithReason(conditionType string, reason string, conditions []metav1.Condition) []metav1.Condition {
+	result := []metav1.Condition{}
+	for _, condition := range conditions {
+		if condition.Type != conditionType || condition.Reason != reason {
+			result = append(result, condition)
+		}
+	}
+	return result
+}
+
 // GetCondition returns the condition with the given type, if it exists. If the condition does not exists it returns false.
 func GetCondition(conditionType string, conditions []metav1.Condition) (metav1.Condition, bool) {
 	for _, condition := range conditions {
@@ -57,5 +98,8 @@ func GetLastCondition(conditions []metav1.Condition) (metav1.Condition, bool) {
 }
 
 func IsErrorCondition(condition metav1.Condition) bool {
+	if condition.Type == Healthy && condition.Status == metav1.ConditionTrue {
+		return false
+	}
 	return !(condition.Type == ReconcileSuccess) || (condition.Type == "Initializing")
 }
@@ -0,0 +1,135 @@
+package defaulting
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"github.com/scylladb/go-set/strset"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var (
+	lockedResourceType         = reflect.TypeOf(utilsapi.LockedResource{})
+	lockedResourceTemplateType = reflect.TypeOf(utilsapi.LockedResourceTemplate{})
+)
+
+// LockedResourceDefaulter is an admission.CustomDefaulter for types that contain LockedResources or LockedResourceTemplates, anywhere in their structure.
+// It does at admission what operators otherwise do in IsInitialized at reconcile time: it merges the default excluded paths into the excluded paths of every LockedResource and LockedResourceTemplate
+// and it adds the finalizer when the object contains at least one of them, removing it when it contains none.
+type LockedResourceDefaulter struct {
+	finalizer string
+}
+
+var _ admission.CustomDefaulter = &LockedResourceDefaulter{}
+
+// NewLockedResourceDefaulter returns a LockedResourceDefaulter that manages the passed finalizer, no finalizer is managed if it is empty
+func NewLockedResourceDefaulter(finalizer string) *LockedResourceDefaulter {
+	return &LockedResourceDefaulter{
+		finalizer: finalizer,
+	}
+}
+
+// Default applies the defaults to the object
+func (d *LockedResourceDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	instance, ok := obj.(client.Object)
+	if !ok {
+		return fmt.Errorf("expected a client.Object but got a %T", obj)
+	}
+	Default(instance, d.finalizer)
+	return nil
+}
+
+// Default merges the default excluded paths into the excluded paths of every LockedResource and LockedResourceTemplate of the object and manages the passed finalizer, if not empty.
+// The finalizer is added when the object contains at least one LockedResource or LockedResourceTemplate and removed when it contains none. It is never added to an object that is being deleted.
+// It returns whether the object has been changed, so that it can also be used to implement IsInitialized when the defaulting webhook is not deployed.
+func Default(obj client.Object, finalizer string) bool {
+	found, changed := defaultExcludedPaths(reflect.ValueOf(obj))
+	if finalizer == "" {
+		return changed
+	}
+	if found > 0 && !util.HasFinalizer(obj, finalizer) && !util.IsBeingDeleted(obj) {
+		util.AddFinalizer(obj, finalizer)
+		changed = true
+	}
+	if found == 0 && util.HasFinalizer(obj, finalizer) {
+		util.RemoveFinalizer(obj, finalizer)
+		changed = true
+	}
+	return changed
+}
+
+// DefaultExcludedPaths merges the default excluded paths into the excluded paths of every LockedResource and LockedResourceTemplate reachable from obj, which must be a pointer.
+// It returns whether the excluded paths of any of them have been changed.
+func DefaultExcludedPaths(obj interface{}) bool {
+	_, changed := defaultExcludedPaths(reflect.ValueOf(obj))
+	return changed
+}
+
+// defaultExcludedPaths walks value, returning the number of LockedResources and LockedResourceTemplates found and whether any of them has been changed.
+// Only what can be set is walked: exported fields of structs reached through pointers, slices and maps.
+func defaultExcludedPaths(value reflect.Value) (int, bool) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return 0, false
+		}
+		return defaultExcludedPaths(value.Elem())
+	case reflect.Struct:
+		if value.Type() == lockedResourceType || value.Type() == lockedResourceTemplateType {
+			return 1, mergeDefaultExcludedPaths(value.FieldByName("ExcludedPaths"))
+		}
+		found, changed := 0, false
+		for i := 0; i < value.NumField(); i++ {
+			if !value.Field(i).CanSet() {
+				continue
+			}
+			fieldFound, fieldChanged := defaultExcludedPaths(value.Field(i))
+			found += fieldFound
+			changed = changed || fieldChanged
+		}
+		return found, changed
+	case reflect.Slice, reflect.Array:
+		found, changed := 0, false
+		for i := 0; i < value.Len(); i++ {
+			itemFound, itemChanged := defaultExcludedPaths(value.Index(i))
+			found += itemFound
+			changed = changed || itemChanged
+		}
+		return found, changed
+	case reflect.Map:
+		found, changed := 0, false
+		iter := value.MapRange()
+		for iter.Next() {
+			// map values are not addressable, so they are defaulted on a copy that is stored back
+			item := reflect.New(iter.Value().Type()).Elem()
+			item.Set(iter.Value())
+			itemFound, itemChanged := defaultExcludedPaths(item)
+			found += itemFound
+			if itemChanged {
+				value.SetMapIndex(iter.Key(), item)
+				changed = true
+			}
+		}
+		return found, changed
+	}
+	return 0, false
+}
+
+func mergeDefaultExcludedPaths(excludedPaths reflect.Value) bool {
+	current := excludedPaths.Interface().([]string)
+	currentSet := strset.New(current...)
+	if currentSet.IsSubset(lockedresource.DefaultExcludedPathsSet) {
+		return false
+	}
+	merged := strset.Union(lockedresource.DefaultExcludedPathsSet, currentSet).List()
+	sort.Strings(merged)
+	excludedPaths.Set(reflect.ValueOf(merged))
+	return true
+}
@@ -0,0 +1,159 @@
+package defaulting
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const finalizer = "example.com/finalizer"
+
+var defaultedPaths = []string{".metadata", ".spec.replicas", ".status"}
+
+// nested contains LockedResources and LockedResourceTemplates in every kind of field that DefaultExcludedPaths walks
+type nested struct {
+	Resource  utilsapi.LockedResource
+	Pointer   *utilsapi.LockedResource
+	NilPtr    *utilsapi.LockedResource
+	Templates []utilsapi.LockedResourceTemplate
+	Array     [1]utilsapi.LockedResource
+	Map       map[string]utilsapi.LockedResourceTemplate
+	Any       interface{}
+	// unexported fields cannot be set, so they are not defaulted
+	unexported utilsapi.LockedResource
+}
+
+func TestDefaultExcludedPaths(t *testing.T) {
+	obj := &nested{
+		Resource:   utilsapi.LockedResource{ExcludedPaths: []string{".data"}},
+		Pointer:    &utilsapi.LockedResource{},
+		Templates:  []utilsapi.LockedResourceTemplate{{ExcludedPaths: []string{".status"}}},
+		Map:        map[string]utilsapi.LockedResourceTemplate{"a": {}},
+		Any:        &utilsapi.LockedResource{},
+		unexported: utilsapi.LockedResource{},
+	}
+	if changed := DefaultExcludedPaths(obj); !changed {
+		t.Error("expected the object to be changed")
+	}
+	expected := map[string][]string{
+		"Resource":  {".data", ".metadata", ".spec.replicas", ".status"},
+		"Pointer":   defaultedPaths,
+		"Templates": defaultedPaths,
+		"Array":     defaultedPaths,
+		"Map":       defaultedPaths,
+		"Any":       defaultedPaths,
+	}
+	actual := map[string][]string{
+		"Resource":  obj.Resource.ExcludedPaths,
+		"Pointer":   obj.Pointer.ExcludedPaths,
+		"Templates": obj.Templates[0].ExcludedPaths,
+		"Array":     obj.Array[0].ExcludedPaths,
+		"Map":       obj.Map["a"].ExcludedPaths,
+		"Any":       obj.Any.(*utilsapi.LockedResource).ExcludedPaths,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if obj.NilPtr != nil {
+		t.Error("expected nil pointers to be left alone")
+	}
+	if obj.unexported.ExcludedPaths != nil {
+		t.Errorf("expected unexported fields to be left alone, got %v", obj.unexported.ExcludedPaths)
+	}
+	if changed := DefaultExcludedPaths(obj); changed {
+		t.Error("expected defaulting to be idempotent")
+	}
+}
+
+func newEnforcingCRD(resources int, finalizers ...string) *utilsapi.EnforcingCRD {
+	obj := &utilsapi.EnforcingCRD{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "ns", Finalizers: finalizers}}
+	for i := 0; i < resources; i++ {
+		obj.Spec.Resources = append(obj.Spec.Resources, utilsapi.LockedResource{ExcludedPaths: defaultedPaths})
+	}
+	return obj
+}
+
+func TestDefault(t *testing.T) {
+	tests := []struct {
+		name               string
+		obj                *utilsapi.EnforcingCRD
+		finalizer          string
+		deleting           bool
+		expectedChanged    bool
+		expectedFinalizers []string
+	}{
+		{
+			name:               "adds the finalizer",
+			obj:                newEnforcingCRD(1),
+			finalizer:          finalizer,
+			expectedChanged:    true,
+			expectedFinalizers: []string{finalizer},
+		},
+		{
+			name:               "keeps the finalizer",
+			obj:                newEnforcingCRD(1, finalizer),
+			finalizer:          finalizer,
+			expectedFinalizers: []string{finalizer},
+		},
+		{
+			name:               "removes the finalizer",
+			obj:                newEnforcingCRD(0, "other", finalizer),
+			finalizer:          finalizer,
+			expectedChanged:    true,
+			expectedFinalizers: []string{"other"},
+		},
+		{
+			name:      "does not add the finalizer while deleting",
+			obj:       newEnforcingCRD(1),
+			finalizer: finalizer,
+			deleting:  true,
+		},
+		{
+			name:               "no finalizer managed",
+			obj:                newEnforcingCRD(0, finalizer),
+			expectedFinalizers: []string{finalizer},
+		},
+		{
+			name:            "defaults the excluded paths",
+			obj:             &utilsapi.EnforcingCRD{Spec: utilsapi.EnforcingCRDSpec{Resources: []utilsapi.LockedResource{{}}}},
+			expectedChanged: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.deleting {
+				now := metav1.Now()
+				test.obj.SetDeletionTimestamp(&now)
+			}
+			if changed := Default(test.obj, test.finalizer); changed != test.expectedChanged {
+				t.Errorf("expected changed to be %v, got %v", test.expectedChanged, changed)
+			}
+			if finalizers := test.obj.GetFinalizers(); !reflect.DeepEqual(finalizers, test.expectedFinalizers) {
+				t.Errorf("expected finalizers %v, got %v", test.expectedFinalizers, finalizers)
+			}
+		})
+	}
+}
+
+func TestLockedResourceDefaulter(t *testing.T) {
+	defaulter := NewLockedResourceDefaulter(finalizer)
+	obj := &utilsapi.EnforcingCRD{Spec: utilsapi.EnforcingCRDSpec{Resources: []utilsapi.LockedResource{{}}}}
+	err := defaulter.Default(context.TODO(), obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(obj.Spec.Resources[0].ExcludedPaths, defaultedPaths) {
+		t.Errorf("expected excluded paths %v, got %v", defaultedPaths, obj.Spec.Resources[0].ExcludedPaths)
+	}
+	if !reflect.DeepEqual(obj.GetFinalizers(), []string{finalizer}) {
+		t.Errorf("expected finalizers %v, got %v", []string{finalizer}, obj.GetFinalizers())
+	}
+	err = defaulter.Default(context.TODO(), &runtime.Unknown{})
+	if err == nil {
+		t.Error("expected an error for objects that are not client.Objects")
+	}
+}
@@ -0,0 +1,168 @@
+package discoveryclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/utils/lru"
+)
+
+// minRefreshInterval is the minimum time between two refreshes of a DiscoveryCache triggered by a GVK that is not found, it protects the apiserver from lookups of GVKs that are not defined
+const minRefreshInterval = 10 * time.Second
+
+// MaxCachedConfigs is the maximum number of rest configs, as identified by GetConfigKey, for which clients are cached. Beyond it the least recently used ones are dropped.
+const MaxCachedConfigs = 32
+
+// DiscoveryCache caches the discovery information of a cluster in memory, along with a RESTMapper built on it.
+// It refreshes itself when a GVK is not found, for example after a CRD is installed, no more often than every minRefreshInterval.
+type DiscoveryCache struct {
+	discovery   discovery.CachedDiscoveryInterface
+	mapper      *restmapper.DeferredDiscoveryRESTMapper
+	lock        sync.Mutex
+	lastRefresh time.Time
+}
+
+var (
+	discoveryCaches     = lru.New(MaxCachedConfigs)
+	discoveryCachesLock sync.Mutex
+)
+
+// GetConfigKey returns a key identifying the cluster and the credentials of the passed rest config, so that the clients built on rest configs that only differ by pointer can be shared.
+// The transport wrappers and dialers of the rest config cannot be compared, so they are not part of the key.
+func GetConfigKey(config *rest.Config) string {
+	credentials := struct {
+		Username        string
+		Password        string
+		BearerToken     string
+		BearerTokenFile string
+		Impersonate     rest.ImpersonationConfig
+		TLSClientConfig rest.TLSClientConfig
+		AuthProvider    *clientcmdapi.AuthProviderConfig
+		ExecProvider    *clientcmdapi.ExecConfig
+	}{
+		Username:        config.Username,
+		Password:        config.Password,
+		BearerToken:     config.BearerToken,
+		BearerTokenFile: config.BearerTokenFile,
+		Impersonate:     config.Impersonate,
+		TLSClientConfig: config.TLSClientConfig,
+		AuthProvider:    config.AuthProvider,
+		ExecProvider:    config.ExecProvider,
+	}
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		// all the fields are marshallable, this is not expected
+		panic(err)
+	}
+	hash := sha256.Sum256(data)
+	return config.Host + config.APIPath + "#" + hex.EncodeToString(hash[:])
+}
+
+// GetDiscoveryCache returns the DiscoveryCache for the passed rest config, creating it the first time. Caches are shared by the rest configs with the same host and credentials, see GetConfigKey,
+// and at most MaxCachedConfigs of them are kept.
+func GetDiscoveryCache(config *rest.Config) (*DiscoveryCache, error) {
+	discoveryCachesLock.Lock()
+	defer discoveryCachesLock.Unlock()
+	key := GetConfigKey(config)
+	if discoveryCache, ok := discoveryCaches.Get(key); ok {
+		return discoveryCache.(*DiscoveryCache), nil
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	cachedDiscovery := memory.NewMemCacheClient(discoveryClient)
+	discoveryCache := &DiscoveryCache{
+		discovery:   cachedDiscovery,
+		mapper:      restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscovery),
+		lastRefresh: time.Now(),
+	}
+	discoveryCaches.Add(key, discoveryCache)
+	return discoveryCache, nil
+}
+
+// Discovery returns the cached discovery client
+func (dc *DiscoveryCache) Discovery() discovery.CachedDiscoveryInterface {
+	return dc.discovery
+}
+
+// RESTMapper returns the RESTMapper backed by the cached discovery client
+func (dc *DiscoveryCache) RESTMapper() meta.RESTMapper {
+	return dc.mapper
+}
+
+// Invalidate drops the cached discovery information, it is fetched again at the next lookup
+func (dc *DiscoveryCache) Invalidate() {
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+	dc.invalidateLocked()
+}
+
+func (dc *DiscoveryCache) invalidateLocked() {
+	// resetting the mapper also invalidates the discovery client it is built on
+	dc.mapper.Reset()
+	dc.lastRefresh = time.Now()
+}
+
+// refreshIfStale invalidates the cache unless it has been refreshed within minRefreshInterval, it returns whether it did
+func (dc *DiscoveryCache) refreshIfStale() bool {
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+	if time.Since(dc.lastRefresh) < minRefreshInterval {
+		return false
+	}
+	dc.invalidateLocked()
+	return true
+}
+
+// GetAPIResourceForGVK returns the APIResource of the passed GVK, subresources excluded. The cache is refreshed and the lookup retried if the GVK is not found.
+func (dc *DiscoveryCache) GetAPIResourceForGVK(GVK schema.GroupVersionKind) (*v1.APIResource, bool, error) {
+	apiResource, found, err := dc.getAPIResourceForGVK(GVK)
+	if err == nil && !found && dc.refreshIfStale() {
+		return dc.getAPIResourceForGVK(GVK)
+	}
+	return apiResource, found, err
+}
+
+func (dc *DiscoveryCache) getAPIResourceForGVK(GVK schema.GroupVersionKind) (*v1.APIResource, bool, error) {
+	apiResources, err := dc.discovery.ServerResourcesForGroupVersion(GVK.GroupVersion().String())
+	if err != nil {
+		if errors.Is(err, memory.ErrCacheNotFound) || apierrors.IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	for i := range apiResources.APIResources {
+		//if a resource contains a "/" it's referencing a subresource. we don't support subresource for now.
+		if apiResources.APIResources[i].Kind == GVK.Kind && !strings.Contains(apiResources.APIResources[i].Name, "/") {
+			apiResource := apiResources.APIResources[i]
+			apiResource.Group = GVK.Group
+			apiResource.Version = GVK.Version
+			return &apiResource, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// RESTMapping returns the RESTMapping of the passed GVK. The cache is refreshed and the lookup retried if the GVK is not found.
+func (dc *DiscoveryCache) RESTMapping(GVK schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := dc.mapper.RESTMapping(GVK.GroupKind(), GVK.Version)
+	if meta.IsNoMatchError(err) && dc.refreshIfStale() {
+		return dc.mapper.RESTMapping(GVK.GroupKind(), GVK.Version)
+	}
+	return mapping, err
+}
@@ -0,0 +1,95 @@
+package discoveryclient
+
+import (
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestGetConfigKey(t *testing.T) {
+	config := &rest.Config{Host: "https://cluster:6443", BearerToken: "token"}
+	tests := []struct {
+		name   string
+		modify func(config *rest.Config)
+		same   bool
+	}{
+		{
+			name:   "copy",
+			modify: func(config *rest.Config) {},
+			same:   true,
+		},
+		{
+			name: "rate limits",
+			modify: func(config *rest.Config) {
+				config.QPS = 100
+			},
+			same: true,
+		},
+		{
+			name: "host",
+			modify: func(config *rest.Config) {
+				config.Host = "https://other:6443"
+			},
+		},
+		{
+			name: "token",
+			modify: func(config *rest.Config) {
+				config.BearerToken = "other"
+			},
+		},
+		{
+			name: "impersonation",
+			modify: func(config *rest.Config) {
+				config.Impersonate.UserName = "user"
+			},
+		},
+		{
+			name: "client certificate",
+			modify: func(config *rest.Config) {
+				config.TLSClientConfig.CertData = []byte("certificate")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			modified := rest.CopyConfig(config)
+			test.modify(modified)
+			if same := GetConfigKey(config) == GetConfigKey(modified); same != test.same {
+				t.Errorf("expected same key to be %v, got %v", test.same, same)
+			}
+		})
+	}
+}
+
+func TestGetDiscoveryCache(t *testing.T) {
+	config := &rest.Config{Host: "https://cluster:6443", BearerToken: "token"}
+	first, err := GetDiscoveryCache(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := GetDiscoveryCache(rest.CopyConfig(config))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second {
+		t.Error("expected copies of the same rest config to share the cache")
+	}
+	for i := 0; i < MaxCachedConfigs; i++ {
+		other := rest.CopyConfig(config)
+		other.BearerToken = string(rune('a' + i))
+		_, err := GetDiscoveryCache(other)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if discoveryCaches.Len() != MaxCachedConfigs {
+		t.Errorf("expected %d cached configs, got %d", MaxCachedConfigs, discoveryCaches.Len())
+	}
+	third, err := GetDiscoveryCache(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first == third {
+		t.Error("expected the least recently used cache to be dropped")
+	}
+}
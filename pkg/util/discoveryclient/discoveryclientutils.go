@@ -3,7 +3,6 @@ package discoveryclient
 import (
 	"context"
 
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -26,28 +25,21 @@ func IsGVKDefined(context context.Context, GVK schema.GroupVersionKind) (bool, e
 	return found, err
 }
 
+// GetAPIResourceForGVK returns the APIResource of the passed GVK, looked up in the DiscoveryCache of the rest config
+// needs context with restConfig and log
 func GetAPIResourceForGVK(context context.Context, GVK schema.GroupVersionKind) (apiresource *v1.APIResource, found bool, err error) {
 	log := log.FromContext(context)
-	discoveryClient, err := GetDiscoveryClient(context)
+	discoveryCache, err := GetDiscoveryCache(context.Value("restConfig").(*rest.Config))
 	if err != nil {
-		log.Error(err, "Unable to get discovery client")
+		log.Error(err, "Unable to get discovery cache")
 		return nil, false, err
 	}
-	// Query for known OpenShift API resource to verify it is available
-	apiResources, err := discoveryClient.ServerResourcesForGroupVersion(GVK.GroupVersion().String())
+	apiresource, found, err = discoveryCache.GetAPIResourceForGVK(GVK)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			return nil, false, nil
-		}
 		log.Error(err, "Unable to retrive resources for", "GVK", GVK)
 		return nil, false, err
 	}
-	for i := range apiResources.APIResources {
-		if apiResources.APIResources[i].Kind == GVK.Kind {
-			return &apiResources.APIResources[i], true, nil
-		}
-	}
-	return nil, false, nil
+	return apiresource, found, nil
 }
 
 // IsGVKNamespaced checks whether the passed GVK os namespaced
@@ -2,16 +2,18 @@ package dynamicclient
 
 import (
 	"context"
-	"strings"
+	"sync"
 
+	"github.com/redhat-cop/operator-utils/pkg/util/discoveryclient"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/utils/lru"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -48,10 +50,31 @@ func GetDynamicClientForAPIResource(context context.Context, resource *metav1.AP
 	})
 }
 
+var (
+	dynamicClients     = lru.New(discoveryclient.MaxCachedConfigs)
+	dynamicClientsLock sync.Mutex
+)
+
+// getDynamicClient returns the dynamic client for the passed rest config, creating it the first time, so that a single client is shared by all the callers using the same host and credentials, see discoveryclient.GetConfigKey
+func getDynamicClient(restConfig *rest.Config) (dynamic.Interface, error) {
+	dynamicClientsLock.Lock()
+	defer dynamicClientsLock.Unlock()
+	key := discoveryclient.GetConfigKey(restConfig)
+	if intf, ok := dynamicClients.Get(key); ok {
+		return intf.(dynamic.Interface), nil
+	}
+	intf, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClients.Add(key, intf)
+	return intf, nil
+}
+
 func getDynamicClientForGVR(context context.Context, gvr schema.GroupVersionResource) (dynamic.NamespaceableResourceInterface, error) {
 	log := log.FromContext(context)
 	restConfig := context.Value("restConfig").(*rest.Config)
-	intf, err := dynamic.NewForConfig(restConfig)
+	intf, err := getDynamicClient(restConfig)
 	if err != nil {
 		log.Error(err, "Unable to get dynamic client")
 		return nil, err
@@ -78,25 +101,25 @@ func GetDynamicClientForGVK(context context.Context, gvk schema.GroupVersionKind
 }
 
 func getAPIReourceForGVK(context context.Context, gvk schema.GroupVersionKind) (*metav1.APIResource, error) {
-	res := &metav1.APIResource{}
 	log := log.FromContext(context)
 	restConfig := context.Value("restConfig").(*rest.Config)
-	discoveryClient := discovery.NewDiscoveryClientForConfigOrDie(restConfig)
-	resList, err := discoveryClient.ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	discoveryCache, err := discoveryclient.GetDiscoveryCache(restConfig)
 	if err != nil {
-		log.Error(err, "unable to retrieve resource list for", "gvk", gvk.GroupVersion().String())
+		log.Error(err, "unable to get discovery cache")
 		return nil, err
 	}
-	for i := range resList.APIResources {
-		//if a resource contains a "/" it's referencing a subresource. we don't support subresource for now.
-		if resList.APIResources[i].Kind == gvk.Kind && !strings.Contains(resList.APIResources[i].Name, "/") {
-			res = &resList.APIResources[i]
-			res.Group = gvk.Group
-			res.Version = gvk.Version
-			break
-		}
+	mapping, err := discoveryCache.RESTMapping(gvk)
+	if err != nil {
+		log.Error(err, "unable to retrieve resource mapping for", "gvk", gvk)
+		return nil, err
 	}
-	return res, nil
+	return &metav1.APIResource{
+		Name:       mapping.Resource.Resource,
+		Group:      gvk.Group,
+		Version:    gvk.Version,
+		Kind:       gvk.Kind,
+		Namespaced: mapping.Scope.Name() == meta.RESTScopeNameNamespace,
+	}, nil
 }
 
 // SetIndexField this function allows to prepare an index field for an objct so that fieldSelector can be used.
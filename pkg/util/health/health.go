@@ -0,0 +1,203 @@
+package health
+
+import (
+	"fmt"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Status is the health of a resource
+type Status string
+
+const (
+	// StatusHealthy means that the resource is working as intended
+	StatusHealthy Status = "Healthy"
+	// StatusProgressing means that the resource is not healthy yet, but it may become healthy, for example a rolling out deployment
+	StatusProgressing Status = "Progressing"
+	// StatusDegraded means that the resource failed, for example a failed job
+	StatusDegraded Status = "Degraded"
+)
+
+// Result is the outcome of a health check
+type Result struct {
+	Status  Status
+	Message string
+}
+
+// Check evaluates the health of a resource
+type Check func(obj *unstructured.Unstructured) (Result, error)
+
+var (
+	checks = map[schema.GroupKind]Check{
+		{Group: "apps", Kind: "Deployment"}:        checkDeployment,
+		{Group: "apps", Kind: "StatefulSet"}:       checkStatefulSet,
+		{Group: "apps", Kind: "DaemonSet"}:         checkDaemonSet,
+		{Group: "batch", Kind: "Job"}:              checkJob,
+		{Group: "", Kind: "PersistentVolumeClaim"}: checkPersistentVolumeClaim,
+	}
+	checksLock sync.RWMutex
+)
+
+// RegisterCheck sets the health check for a kind, replacing the built-in one if any
+func RegisterCheck(groupKind schema.GroupKind, check Check) {
+	checksLock.Lock()
+	defer checksLock.Unlock()
+	checks[groupKind] = check
+}
+
+// GetHealth evaluates the health of a resource with the check registered for its kind.
+// Kinds without a registered check are evaluated on their Ready condition, resources with no Ready condition are considered healthy.
+func GetHealth(obj *unstructured.Unstructured) (Result, error) {
+	checksLock.RLock()
+	check, ok := checks[obj.GroupVersionKind().GroupKind()]
+	checksLock.RUnlock()
+	if !ok {
+		check = checkReadyCondition
+	}
+	return check(obj)
+}
+
+func checkDeployment(obj *unstructured.Unstructured) (Result, error) {
+	deployment := &appsv1.Deployment{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), deployment)
+	if err != nil {
+		return Result{}, err
+	}
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return Result{Status: StatusProgressing, Message: "waiting for the deployment spec update to be observed"}, nil
+	}
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return Result{Status: StatusDegraded, Message: condition.Message}, nil
+		}
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Status.UpdatedReplicas < replicas {
+		return Result{Status: StatusProgressing, Message: fmt.Sprintf("%d out of %d new replicas have been updated", deployment.Status.UpdatedReplicas, replicas)}, nil
+	}
+	if deployment.Status.Replicas > deployment.Status.UpdatedReplicas {
+		return Result{Status: StatusProgressing, Message: fmt.Sprintf("%d old replicas are pending termination", deployment.Status.Replicas-deployment.Status.UpdatedReplicas)}, nil
+	}
+	if deployment.Status.AvailableReplicas < deployment.Status.UpdatedReplicas {
+		return Result{Status: StatusProgressing, Message: fmt.Sprintf("%d of %d updated replicas are available", deployment.Status.AvailableReplicas, deployment.Status.UpdatedReplicas)}, nil
+	}
+	return Result{Status: StatusHealthy}, nil
+}
+
+func checkStatefulSet(obj *unstructured.Unstructured) (Result, error) {
+	statefulSet := &appsv1.StatefulSet{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), statefulSet)
+	if err != nil {
+		return Result{}, err
+	}
+	if statefulSet.Generation > statefulSet.Status.ObservedGeneration {
+		return Result{Status: StatusProgressing, Message: "waiting for the statefulset spec update to be observed"}, nil
+	}
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	if statefulSet.Status.ReadyReplicas < replicas {
+		return Result{Status: StatusProgressing, Message: fmt.Sprintf("%d of %d replicas are ready", statefulSet.Status.ReadyReplicas, replicas)}, nil
+	}
+	if statefulSet.Spec.UpdateStrategy.Type == appsv1.RollingUpdateStatefulSetStrategyType && statefulSet.Spec.UpdateStrategy.RollingUpdate != nil &&
+		statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition != nil && *statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition > 0 {
+		// with a partitioned rollout, the revisions are not meant to converge
+		return Result{Status: StatusHealthy}, nil
+	}
+	if statefulSet.Spec.UpdateStrategy.Type != appsv1.OnDeleteStatefulSetStrategyType && statefulSet.Status.UpdateRevision != statefulSet.Status.CurrentRevision {
+		return Result{Status: StatusProgressing, Message: fmt.Sprintf("%d of %d replicas have been updated", statefulSet.Status.UpdatedReplicas, replicas)}, nil
+	}
+	return Result{Status: StatusHealthy}, nil
+}
+
+func checkDaemonSet(obj *unstructured.Unstructured) (Result, error) {
+	daemonSet := &appsv1.DaemonSet{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), daemonSet)
+	if err != nil {
+		return Result{}, err
+	}
+	if daemonSet.Generation > daemonSet.Status.ObservedGeneration {
+		return Result{Status: StatusProgressing, Message: "waiting for the daemonset spec update to be observed"}, nil
+	}
+	if daemonSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+		return Result{Status: StatusHealthy}, nil
+	}
+	if daemonSet.Status.UpdatedNumberScheduled < daemonSet.Status.DesiredNumberScheduled {
+		return Result{Status: StatusProgressing, Message: fmt.Sprintf("%d out of %d new pods have been updated", daemonSet.Status.UpdatedNumberScheduled, daemonSet.Status.DesiredNumberScheduled)}, nil
+	}
+	if daemonSet.Status.NumberAvailable < daemonSet.Status.DesiredNumberScheduled {
+		return Result{Status: StatusProgressing, Message: fmt.Sprintf("%d of %d updated pods are available", daemonSet.Status.NumberAvailable, daemonSet.Status.DesiredNumberScheduled)}, nil
+	}
+	return Result{Status: StatusHealthy}, nil
+}
+
+func checkJob(obj *unstructured.Unstructured) (Result, error) {
+	job := &batchv1.Job{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), job)
+	if err != nil {
+		return Result{}, err
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return Result{Status: StatusHealthy, Message: condition.Message}, nil
+		case batchv1.JobFailed:
+			return Result{Status: StatusDegraded, Message: condition.Message}, nil
+		}
+	}
+	return Result{Status: StatusProgressing, Message: "job has not completed yet"}, nil
+}
+
+func checkPersistentVolumeClaim(obj *unstructured.Unstructured) (Result, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), pvc)
+	if err != nil {
+		return Result{}, err
+	}
+	switch pvc.Status.Phase {
+	case corev1.ClaimBound:
+		return Result{Status: StatusHealthy}, nil
+	case corev1.ClaimLost:
+		return Result{Status: StatusDegraded, Message: "the claim lost its underlying volume"}, nil
+	default:
+		return Result{Status: StatusProgressing, Message: "the claim is not bound yet"}, nil
+	}
+}
+
+// checkReadyCondition evaluates the Ready condition that many resources report in status.conditions
+func checkReadyCondition(obj *unstructured.Unstructured) (Result, error) {
+	conditions, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil || !found {
+		return Result{Status: StatusHealthy}, nil
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		message, _ := condition["message"].(string)
+		switch condition["status"] {
+		case string(metav1.ConditionTrue):
+			return Result{Status: StatusHealthy, Message: message}, nil
+		case string(metav1.ConditionFalse):
+			return Result{Status: StatusDegraded, Message: message}, nil
+		default:
+			return Result{Status: StatusProgressing, Message: message}, nil
+		}
+	}
+	return Result{Status: StatusHealthy}, nil
+}
@@ -0,0 +1,256 @@
+package health
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func toUnstructured(t *testing.T, obj runtime.Object) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return &unstructured.Unstructured{Object: content}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func newDeployment(replicas int32, status appsv1.DeploymentStatus) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(replicas)},
+		Status:     status,
+	}
+}
+
+func newStatefulSet(partition *int32, status appsv1.StatefulSetStatus) *appsv1.StatefulSet {
+	statefulSet := &appsv1.StatefulSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Generation: 1},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:       int32Ptr(2),
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
+		},
+		Status: status,
+	}
+	if partition != nil {
+		statefulSet.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{Partition: partition}
+	}
+	return statefulSet
+}
+
+func newDaemonSet(strategy appsv1.DaemonSetUpdateStrategyType, status appsv1.DaemonSetStatus) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"},
+		ObjectMeta: metav1.ObjectMeta{Name: "agent", Generation: 1},
+		Spec:       appsv1.DaemonSetSpec{UpdateStrategy: appsv1.DaemonSetUpdateStrategy{Type: strategy}},
+		Status:     status,
+	}
+}
+
+func newJob(conditions ...batchv1.JobCondition) *batchv1.Job {
+	return &batchv1.Job{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{Name: "job"},
+		Status:     batchv1.JobStatus{Conditions: conditions},
+	}
+}
+
+func newPersistentVolumeClaim(phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+		ObjectMeta: metav1.ObjectMeta{Name: "data"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+func newCustomResource(conditions ...interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Database",
+		"metadata":   map[string]interface{}{"name": "db"},
+	}}
+	if len(conditions) > 0 {
+		obj.Object["status"] = map[string]interface{}{"conditions": conditions}
+	}
+	return obj
+}
+
+func TestGetHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      runtime.Object
+		expected Result
+	}{
+		{
+			name:     "deployment available",
+			obj:      newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}),
+			expected: Result{Status: StatusHealthy},
+		},
+		{
+			name:     "deployment spec not observed",
+			obj:      newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}),
+			expected: Result{Status: StatusProgressing, Message: "waiting for the deployment spec update to be observed"},
+		},
+		{
+			name: "deployment deadline exceeded",
+			obj: newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded", Message: "timed out"},
+			}}),
+			expected: Result{Status: StatusDegraded, Message: "timed out"},
+		},
+		{
+			name:     "deployment rolling out",
+			obj:      newDeployment(3, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 3}),
+			expected: Result{Status: StatusProgressing, Message: "1 out of 3 new replicas have been updated"},
+		},
+		{
+			name:     "deployment terminating old replicas",
+			obj:      newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2}),
+			expected: Result{Status: StatusProgressing, Message: "1 old replicas are pending termination"},
+		},
+		{
+			name:     "deployment replicas not available",
+			obj:      newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}),
+			expected: Result{Status: StatusProgressing, Message: "1 of 2 updated replicas are available"},
+		},
+		{
+			name:     "statefulset ready",
+			obj:      newStatefulSet(nil, appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 2, UpdatedReplicas: 2, CurrentRevision: "r1", UpdateRevision: "r1"}),
+			expected: Result{Status: StatusHealthy},
+		},
+		{
+			name:     "statefulset replicas not ready",
+			obj:      newStatefulSet(nil, appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 1, CurrentRevision: "r1", UpdateRevision: "r1"}),
+			expected: Result{Status: StatusProgressing, Message: "1 of 2 replicas are ready"},
+		},
+		{
+			name:     "statefulset rolling out",
+			obj:      newStatefulSet(nil, appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 2, UpdatedReplicas: 1, CurrentRevision: "r1", UpdateRevision: "r2"}),
+			expected: Result{Status: StatusProgressing, Message: "1 of 2 replicas have been updated"},
+		},
+		{
+			name:     "statefulset partitioned rollout",
+			obj:      newStatefulSet(int32Ptr(1), appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 2, UpdatedReplicas: 1, CurrentRevision: "r1", UpdateRevision: "r2"}),
+			expected: Result{Status: StatusHealthy},
+		},
+		{
+			name:     "daemonset available",
+			obj:      newDaemonSet(appsv1.RollingUpdateDaemonSetStrategyType, appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberAvailable: 3}),
+			expected: Result{Status: StatusHealthy},
+		},
+		{
+			name:     "daemonset rolling out",
+			obj:      newDaemonSet(appsv1.RollingUpdateDaemonSetStrategyType, appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 2, NumberAvailable: 3}),
+			expected: Result{Status: StatusProgressing, Message: "2 out of 3 new pods have been updated"},
+		},
+		{
+			name:     "daemonset pods not available",
+			obj:      newDaemonSet(appsv1.RollingUpdateDaemonSetStrategyType, appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberAvailable: 1}),
+			expected: Result{Status: StatusProgressing, Message: "1 of 3 updated pods are available"},
+		},
+		{
+			name:     "daemonset on delete",
+			obj:      newDaemonSet(appsv1.OnDeleteDaemonSetStrategyType, appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3}),
+			expected: Result{Status: StatusHealthy},
+		},
+		{
+			name:     "job complete",
+			obj:      newJob(batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, Message: "done"}),
+			expected: Result{Status: StatusHealthy, Message: "done"},
+		},
+		{
+			name:     "job failed",
+			obj:      newJob(batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "backoff limit exceeded"}),
+			expected: Result{Status: StatusDegraded, Message: "backoff limit exceeded"},
+		},
+		{
+			name:     "job running",
+			obj:      newJob(batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionFalse}),
+			expected: Result{Status: StatusProgressing, Message: "job has not completed yet"},
+		},
+		{
+			name:     "claim bound",
+			obj:      newPersistentVolumeClaim(corev1.ClaimBound),
+			expected: Result{Status: StatusHealthy},
+		},
+		{
+			name:     "claim lost",
+			obj:      newPersistentVolumeClaim(corev1.ClaimLost),
+			expected: Result{Status: StatusDegraded, Message: "the claim lost its underlying volume"},
+		},
+		{
+			name:     "claim pending",
+			obj:      newPersistentVolumeClaim(corev1.ClaimPending),
+			expected: Result{Status: StatusProgressing, Message: "the claim is not bound yet"},
+		},
+		{
+			name:     "no conditions",
+			obj:      newCustomResource(),
+			expected: Result{Status: StatusHealthy},
+		},
+		{
+			name:     "ready",
+			obj:      newCustomResource(map[string]interface{}{"type": "Ready", "status": "True", "message": "up"}),
+			expected: Result{Status: StatusHealthy, Message: "up"},
+		},
+		{
+			name:     "not ready",
+			obj:      newCustomResource(map[string]interface{}{"type": "Other", "status": "True"}, map[string]interface{}{"type": "Ready", "status": "False", "message": "down"}),
+			expected: Result{Status: StatusDegraded, Message: "down"},
+		},
+		{
+			name:     "readiness unknown",
+			obj:      newCustomResource(map[string]interface{}{"type": "Ready", "status": "Unknown"}),
+			expected: Result{Status: StatusProgressing},
+		},
+		{
+			name:     "no ready condition",
+			obj:      newCustomResource(map[string]interface{}{"type": "Synced", "status": "False"}),
+			expected: Result{Status: StatusHealthy},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := GetHealth(toUnstructured(t, test.obj))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestRegisterCheck(t *testing.T) {
+	groupKind := schema.GroupKind{Group: "example.com", Kind: "Widget"}
+	RegisterCheck(groupKind, func(obj *unstructured.Unstructured) (Result, error) {
+		return Result{Status: StatusDegraded, Message: "custom"}, nil
+	})
+	defer func() {
+		checksLock.Lock()
+		defer checksLock.Unlock()
+		delete(checks, groupKind)
+	}()
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1")
+	obj.SetKind("Widget")
+	result, err := GetHealth(obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := (Result{Status: StatusDegraded, Message: "custom"}); result != expected {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
@@ -0,0 +1,404 @@
+package lockedresourcecontroller
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/apis"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/yaml"
+)
+
+// PriorityAnnotation is the annotation of a parent that sets the priority of its claims on the enforced objects, by default. When two parents claim the same object, the one with the higher priority wins.
+// The default priority is 0.
+const PriorityAnnotation = "redhat-cop.io/enforcing-priority"
+
+// templateActionRegexp matches the actions of a go template, they are replaced with a placeholder to guess the fields touched by a patch before it is rendered
+var templateActionRegexp = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
+
+// objectClaim is the claim of a parent on an object, made by a locked resource or by a locked patch
+type objectClaim struct {
+	// objectKey identifies the claimed object, see getObjectKey
+	objectKey string
+	// patch is the name of the patch making the claim, empty if the claim is made by a locked resource
+	patch string
+	// resource is the key of the locked resource making the claim, see apis.GetKeyLong
+	resource string
+	// paths are the fields claimed on the object, nil means the whole object
+	paths [][]string
+}
+
+// parentClaims are the claims of a parent
+type parentClaims struct {
+	parent   client.Object
+	notify   chan<- event.GenericEvent
+	priority int
+	claims   []objectClaim
+	// sequences records when each claim was first made, the older claim wins between parents with the same priority
+	sequences []uint64
+}
+
+// claimConflict is a conflict between a claim of a parent and a claim of another parent
+type claimConflict struct {
+	claim       objectClaim
+	otherParent string
+	// won is true if the claim of the parent prevails over the claim of the other parent
+	won bool
+}
+
+// claimIndex indexes the objects claimed by the parents of all the EnforcingReconcilers of the process, so that two parents do not enforce conflicting states of the same object
+type claimIndex struct {
+	parents  map[string]*parentClaims
+	sequence uint64
+	lock     sync.Mutex
+}
+
+var globalClaimIndex = newClaimIndex()
+
+func newClaimIndex() *claimIndex {
+	return &claimIndex{
+		parents: map[string]*parentClaims{},
+	}
+}
+
+// set replaces the claims of the parent identified by parentKey. The other parents whose conflicts may have changed are notified through their channel, so that they can re-evaluate their claims.
+func (ci *claimIndex) set(parentKey string, parent client.Object, notify chan<- event.GenericEvent, priority int, claims []objectClaim) {
+	ci.lock.Lock()
+	defer ci.lock.Unlock()
+	previous, ok := ci.parents[parentKey]
+	if ok && previous.priority == priority && reflect.DeepEqual(previous.claims, claims) {
+		previous.parent = parent
+		return
+	}
+	current := &parentClaims{
+		parent:    parent,
+		notify:    notify,
+		priority:  priority,
+		claims:    claims,
+		sequences: make([]uint64, len(claims)),
+	}
+	for i := range claims {
+		current.sequences[i] = ci.getSequence(previous, claims[i])
+	}
+	ci.parents[parentKey] = current
+	changed := claims
+	if ok {
+		changed = append(append([]objectClaim{}, claims...), previous.claims...)
+	}
+	ci.notifyOverlapping(parentKey, changed)
+}
+
+// remove drops the claims of the parent identified by parentKey, the parents that were in conflict with it are notified
+func (ci *claimIndex) remove(parentKey string) {
+	ci.lock.Lock()
+	defer ci.lock.Unlock()
+	previous, ok := ci.parents[parentKey]
+	if !ok {
+		return
+	}
+	delete(ci.parents, parentKey)
+	ci.notifyOverlapping(parentKey, previous.claims)
+}
+
+// getSequence returns the sequence of the claim if it was already made by the parent, otherwise a new sequence
+func (ci *claimIndex) getSequence(previous *parentClaims, claim objectClaim) uint64 {
+	if sequence, ok := getPreviousSequence(previous, claim); ok {
+		return sequence
+	}
+	ci.sequence++
+	return ci.sequence
+}
+
+// getPreviousSequence returns the sequence of the claim if it was already made by the parent, previous can be nil
+func getPreviousSequence(previous *parentClaims, claim objectClaim) (uint64, bool) {
+	if previous == nil {
+		return 0, false
+	}
+	for i := range previous.claims {
+		if previous.claims[i].objectKey == claim.objectKey && previous.claims[i].patch == claim.patch && previous.claims[i].resource == claim.resource {
+			return previous.sequences[i], true
+		}
+	}
+	return 0, false
+}
+
+func (ci *claimIndex) notifyOverlapping(parentKey string, claims []objectClaim) {
+	for otherParentKey, other := range ci.parents {
+		if otherParentKey == parentKey || other.notify == nil {
+			continue
+		}
+		for i := range claims {
+			if other.overlaps(claims[i]) {
+				notify, parent := other.notify, other.parent
+				go func() {
+					notify <- event.GenericEvent{Object: parent}
+				}()
+				break
+			}
+		}
+	}
+}
+
+func (pc *parentClaims) overlaps(claim objectClaim) bool {
+	for i := range pc.claims {
+		if pc.claims[i].overlaps(claim) {
+			return true
+		}
+	}
+	return false
+}
+
+// getConflicts returns the conflicts of the claims of the parent identified by parentKey with the claims of the other parents
+func (ci *claimIndex) getConflicts(parentKey string) []claimConflict {
+	ci.lock.Lock()
+	defer ci.lock.Unlock()
+	current, ok := ci.parents[parentKey]
+	if !ok {
+		return []claimConflict{}
+	}
+	return ci.getParentConflicts(parentKey, current)
+}
+
+// getConflictsFor returns the conflicts that the passed claims would have with the claims of the other parents if they were set for the parent identified by parentKey, without setting them.
+// The claims that the parent has already made keep their age, the other ones are the newest.
+func (ci *claimIndex) getConflictsFor(parentKey string, priority int, claims []objectClaim) []claimConflict {
+	ci.lock.Lock()
+	defer ci.lock.Unlock()
+	current := &parentClaims{
+		priority:  priority,
+		claims:    claims,
+		sequences: make([]uint64, len(claims)),
+	}
+	for i := range claims {
+		sequence, ok := getPreviousSequence(ci.parents[parentKey], claims[i])
+		if !ok {
+			sequence = ci.sequence + 1
+		}
+		current.sequences[i] = sequence
+	}
+	return ci.getParentConflicts(parentKey, current)
+}
+
+// getParentConflicts returns the conflicts of the passed claims of the parent identified by parentKey with the claims of the other parents, it must be called holding the lock
+func (ci *claimIndex) getParentConflicts(parentKey string, current *parentClaims) []claimConflict {
+	conflicts := []claimConflict{}
+	otherParentKeys := []string{}
+	for otherParentKey := range ci.parents {
+		if otherParentKey != parentKey {
+			otherParentKeys = append(otherParentKeys, otherParentKey)
+		}
+	}
+	sort.Strings(otherParentKeys)
+	for i := range current.claims {
+		for _, otherParentKey := range otherParentKeys {
+			other := ci.parents[otherParentKey]
+			for j := range other.claims {
+				if !current.claims[i].overlaps(other.claims[j]) {
+					continue
+				}
+				conflicts = append(conflicts, claimConflict{
+					claim:       current.claims[i],
+					otherParent: otherParentKey,
+					won:         current.priority > other.priority || (current.priority == other.priority && current.sequences[i] < other.sequences[j]),
+				})
+			}
+		}
+	}
+	return conflicts
+}
+
+// overlaps returns whether two claims concern the same fields of the same object. Two locked resources always overlap, as each of them would also delete the object.
+func (oc objectClaim) overlaps(other objectClaim) bool {
+	if oc.objectKey != other.objectKey {
+		return false
+	}
+	if (oc.patch == "" && other.patch == "") || oc.paths == nil || other.paths == nil {
+		return true
+	}
+	for i := range oc.paths {
+		for j := range other.paths {
+			if isPathPrefix(oc.paths[i], other.paths[j]) || isPathPrefix(other.paths[j], oc.paths[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isPathPrefix(prefix []string, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (oc objectClaim) String() string {
+	if oc.patch != "" {
+		return "patch " + oc.patch + " on " + oc.objectKey
+	}
+	return oc.objectKey
+}
+
+// getClaims returns the claims made by the passed resources and patches. Only the resources and patches that write to the cluster make claims.
+// Patches whose target is selected by labels or annotations, rather than by name, do not make claims, since their targets are not known in advance.
+func getClaims(resources []lockedresource.LockedResource, patches []lockedpatch.LockedPatch) []objectClaim {
+	claims := []objectClaim{}
+	for i := range resources {
+		if !isWritingMode(resources[i].GetMode()) {
+			continue
+		}
+		claims = append(claims, objectClaim{
+			objectKey: getObjectKey(resources[i].GroupVersionKind().GroupKind(), resources[i].GetNamespace(), resources[i].GetName()),
+			resource:  apis.GetKeyLong(&resources[i].Unstructured),
+			paths:     getResourcePaths(&resources[i]),
+		})
+	}
+	for i := range patches {
+		target := patches[i].TargetObjectRef
+		if patches[i].GetMode() != utilsapi.EnforcementModeEnforce || target.Name == "" || target.LabelSelector != nil || target.AnnotationSelector != nil {
+			continue
+		}
+		claims = append(claims, objectClaim{
+			objectKey: getObjectKey(schema.FromAPIVersionAndKind(target.APIVersion, target.Kind).GroupKind(), target.Namespace, target.Name),
+			patch:     patches[i].Name,
+			paths:     getPatchTemplatePaths(&patches[i]),
+		})
+	}
+	return claims
+}
+
+// getResourcePaths returns the paths of the fields set by a locked resource, leaving out the fields that identify the object
+func getResourcePaths(resource *lockedresource.LockedResource) [][]string {
+	paths := [][]string{}
+	for _, path := range getPatchedPaths(resource.Object, []string{}) {
+		if reflect.DeepEqual(path, []string{"apiVersion"}) || reflect.DeepEqual(path, []string{"kind"}) ||
+			reflect.DeepEqual(path, []string{"metadata", "name"}) || reflect.DeepEqual(path, []string{"metadata", "namespace"}) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return sortPaths(paths)
+}
+
+// sortPaths sorts the passed paths in place and returns them, so that the claims can be compared
+func sortPaths(paths [][]string) [][]string {
+	sort.Slice(paths, func(i, j int) bool {
+		return toJSONPointer(paths[i]) < toJSONPointer(paths[j])
+	})
+	return paths
+}
+
+// getPatchTemplatePaths returns the paths of the fields touched by a patch, guessed from its template with the template actions replaced by a placeholder.
+// It returns nil, meaning the whole object, if the paths cannot be determined.
+func getPatchTemplatePaths(patch *lockedpatch.LockedPatch) [][]string {
+	data, err := yaml.YAMLToJSON([]byte(templateActionRegexp.ReplaceAllString(patch.PatchTemplate, "templated")))
+	if err != nil {
+		return nil
+	}
+	if patch.PatchType == types.JSONPatchType {
+		operations := []map[string]interface{}{}
+		if json.Unmarshal(data, &operations) != nil {
+			return nil
+		}
+		paths := [][]string{}
+		for _, operation := range operations {
+			path, ok := operation["path"].(string)
+			if !ok {
+				return nil
+			}
+			paths = append(paths, fromJSONPointer(path))
+		}
+		return sortPaths(paths)
+	}
+	patchMap := map[string]interface{}{}
+	if json.Unmarshal(data, &patchMap) != nil {
+		return nil
+	}
+	return sortPaths(getPatchedPaths(patchMap, []string{}))
+}
+
+// getDefaultPriority returns the priority set in the PriorityAnnotation of the parent, 0 if not set or invalid
+func getDefaultPriority(parent client.Object) int {
+	priority, err := strconv.Atoi(parent.GetAnnotations()[PriorityAnnotation])
+	if err != nil {
+		return 0
+	}
+	return priority
+}
+
+// getClaimPriority returns the priority of the claims of the passed parent, as set with WithClaimPriority or, by default, in the PriorityAnnotation
+func getClaimPriority(o options, parent client.Object) int {
+	if o.claimPriority != nil {
+		return o.claimPriority(parent)
+	}
+	return getDefaultPriority(parent)
+}
+
+// getLostClaims returns the keys of the resources and the names of the patches whose claims have been lost, each with the key of the parent that won the claim
+func getLostClaims(conflicts []claimConflict) (lostResources map[string]string, lostPatches map[string]string) {
+	lostResources = map[string]string{}
+	lostPatches = map[string]string{}
+	for _, conflict := range conflicts {
+		if conflict.won {
+			continue
+		}
+		if conflict.claim.patch != "" {
+			lostPatches[conflict.claim.patch] = conflict.otherParent
+		} else {
+			lostResources[conflict.claim.resource] = conflict.otherParent
+		}
+	}
+	return lostResources, lostPatches
+}
+
+// filterLostClaims returns the resources and patches whose claims have not been lost to other parents
+func filterLostClaims(resources []lockedresource.LockedResource, patches []lockedpatch.LockedPatch, conflicts []claimConflict) ([]lockedresource.LockedResource, []lockedpatch.LockedPatch) {
+	lostResources, lostPatches := getLostClaims(conflicts)
+	if len(lostResources) == 0 && len(lostPatches) == 0 {
+		return resources, patches
+	}
+	enforcedResources := []lockedresource.LockedResource{}
+	for i := range resources {
+		if _, ok := lostResources[apis.GetKeyLong(&resources[i].Unstructured)]; !ok {
+			enforcedResources = append(enforcedResources, resources[i])
+		}
+	}
+	enforcedPatches := []lockedpatch.LockedPatch{}
+	for i := range patches {
+		if _, ok := lostPatches[patches[i].Name]; !ok {
+			enforcedPatches = append(enforcedPatches, patches[i])
+		}
+	}
+	return enforcedResources, enforcedPatches
+}
+
+// getConflictsMessage describes the passed conflicts
+func getConflictsMessage(conflicts []claimConflict) string {
+	message := ""
+	for i, conflict := range conflicts {
+		if i > 0 {
+			message += "; "
+		}
+		if conflict.won {
+			message += conflict.claim.String() + " is also claimed by " + conflict.otherParent + ", which yields"
+		} else {
+			message += conflict.claim.String() + " is claimed by " + conflict.otherParent + ", which takes precedence, it is not enforced"
+		}
+	}
+	return message
+}
@@ -0,0 +1,251 @@
+package lockedresourcecontroller
+
+import (
+	"reflect"
+	"testing"
+
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const configMapObjectKey = "/ConfigMap/ns/config"
+
+func newClaimedConfigMap(mode utilsapi.EnforcementMode) lockedresource.LockedResource {
+	resource := lockedresource.LockedResource{Unstructured: *newConfigMap("ns", "config"), Mode: mode}
+	resource.Object["data"] = map[string]interface{}{"key": "value"}
+	return resource
+}
+
+func newClaimingPatch(name string, mode utilsapi.EnforcementMode, patchType types.PatchType, patchTemplate string) lockedpatch.LockedPatch {
+	return lockedpatch.LockedPatch{
+		Name: name,
+		TargetObjectRef: utilsapi.TargetObjectReference{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  "ns",
+			Name:       "config",
+		},
+		PatchType:     patchType,
+		PatchTemplate: patchTemplate,
+		Mode:          mode,
+	}
+}
+
+func TestGetClaims(t *testing.T) {
+	selected := newClaimingPatch("selected", "", types.MergePatchType, `{"data":{"key":"value"}}`)
+	selected.TargetObjectRef.Name = ""
+	selected.TargetObjectRef.LabelSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "a"}}
+	tests := []struct {
+		name      string
+		resources []lockedresource.LockedResource
+		patches   []lockedpatch.LockedPatch
+		expected  []objectClaim
+	}{
+		{
+			name:      "resource",
+			resources: []lockedresource.LockedResource{newClaimedConfigMap("")},
+			expected: []objectClaim{
+				{objectKey: configMapObjectKey, resource: configMapKey, paths: [][]string{{"data", "key"}}},
+			},
+		},
+		{
+			name:      "audited resource",
+			resources: []lockedresource.LockedResource{newClaimedConfigMap(utilsapi.EnforcementModeAudit)},
+			expected:  []objectClaim{},
+		},
+		{
+			name:    "merge patch",
+			patches: []lockedpatch.LockedPatch{newClaimingPatch("p", "", types.MergePatchType, `{"data":{"key":"{{ .name }}"}}`)},
+			expected: []objectClaim{
+				{objectKey: configMapObjectKey, patch: "p", paths: [][]string{{"data", "key"}}},
+			},
+		},
+		{
+			name:    "json patch",
+			patches: []lockedpatch.LockedPatch{newClaimingPatch("p", "", types.JSONPatchType, `[{"op":"replace","path":"/data/b","value":"x"},{"op":"add","path":"/data/a","value":"y"}]`)},
+			expected: []objectClaim{
+				{objectKey: configMapObjectKey, patch: "p", paths: [][]string{{"data", "a"}, {"data", "b"}}},
+			},
+		},
+		{
+			name:    "unparsable patch",
+			patches: []lockedpatch.LockedPatch{newClaimingPatch("p", "", types.MergePatchType, `{{ .patch }}`)},
+			expected: []objectClaim{
+				{objectKey: configMapObjectKey, patch: "p"},
+			},
+		},
+		{
+			name:     "audited patch",
+			patches:  []lockedpatch.LockedPatch{newClaimingPatch("p", utilsapi.EnforcementModeAudit, types.MergePatchType, `{"data":{"key":"value"}}`)},
+			expected: []objectClaim{},
+		},
+		{
+			name:     "selected targets",
+			patches:  []lockedpatch.LockedPatch{selected},
+			expected: []objectClaim{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := getClaims(test.resources, test.patches)
+			if !reflect.DeepEqual(claims, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, claims)
+			}
+		})
+	}
+}
+
+func TestObjectClaimOverlaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		claim    objectClaim
+		other    objectClaim
+		expected bool
+	}{
+		{
+			name:     "different objects",
+			claim:    objectClaim{objectKey: "/ConfigMap/ns/a", resource: "a"},
+			other:    objectClaim{objectKey: "/ConfigMap/ns/b", resource: "b"},
+			expected: false,
+		},
+		{
+			name:     "two resources",
+			claim:    objectClaim{objectKey: configMapObjectKey, resource: configMapKey, paths: [][]string{{"data", "a"}}},
+			other:    objectClaim{objectKey: configMapObjectKey, resource: configMapKey, paths: [][]string{{"data", "b"}}},
+			expected: true,
+		},
+		{
+			name:     "disjoint paths",
+			claim:    objectClaim{objectKey: configMapObjectKey, patch: "p", paths: [][]string{{"data", "a"}}},
+			other:    objectClaim{objectKey: configMapObjectKey, resource: configMapKey, paths: [][]string{{"data", "b"}}},
+			expected: false,
+		},
+		{
+			name:     "same path",
+			claim:    objectClaim{objectKey: configMapObjectKey, patch: "p", paths: [][]string{{"data", "a"}}},
+			other:    objectClaim{objectKey: configMapObjectKey, patch: "q", paths: [][]string{{"data", "a"}}},
+			expected: true,
+		},
+		{
+			name:     "prefix path",
+			claim:    objectClaim{objectKey: configMapObjectKey, patch: "p", paths: [][]string{{"data"}}},
+			other:    objectClaim{objectKey: configMapObjectKey, patch: "q", paths: [][]string{{"data", "a"}}},
+			expected: true,
+		},
+		{
+			name:     "unknown paths",
+			claim:    objectClaim{objectKey: configMapObjectKey, patch: "p"},
+			other:    objectClaim{objectKey: configMapObjectKey, patch: "q", paths: [][]string{{"data", "a"}}},
+			expected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if overlaps := test.claim.overlaps(test.other); overlaps != test.expected {
+				t.Errorf("expected overlaps to be %v, got %v", test.expected, overlaps)
+			}
+			if overlaps := test.other.overlaps(test.claim); overlaps != test.expected {
+				t.Errorf("expected the reverse overlaps to be %v, got %v", test.expected, overlaps)
+			}
+		})
+	}
+}
+
+func TestGetConflicts(t *testing.T) {
+	claim := objectClaim{objectKey: configMapObjectKey, resource: configMapKey}
+	tests := []struct {
+		name          string
+		priority      int
+		otherPriority int
+		// first is true if the parent makes its claim before the other parent
+		first    bool
+		expected []claimConflict
+	}{
+		{
+			name:     "older claim wins",
+			first:    true,
+			expected: []claimConflict{{claim: claim, otherParent: "other", won: true}},
+		},
+		{
+			name:     "newer claim loses",
+			expected: []claimConflict{{claim: claim, otherParent: "other", won: false}},
+		},
+		{
+			name:     "higher priority wins",
+			priority: 1,
+			expected: []claimConflict{{claim: claim, otherParent: "other", won: true}},
+		},
+		{
+			name:          "lower priority loses",
+			otherPriority: 1,
+			first:         true,
+			expected:      []claimConflict{{claim: claim, otherParent: "other", won: false}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ci := newClaimIndex()
+			if test.first {
+				ci.set("parent", nil, nil, test.priority, []objectClaim{claim})
+			}
+			ci.set("other", nil, nil, test.otherPriority, []objectClaim{claim})
+			if !test.first {
+				ci.set("parent", nil, nil, test.priority, []objectClaim{claim})
+			}
+			conflicts := ci.getConflicts("parent")
+			if !reflect.DeepEqual(conflicts, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, conflicts)
+			}
+		})
+	}
+}
+
+func TestGetConflictsFor(t *testing.T) {
+	claim := objectClaim{objectKey: configMapObjectKey, resource: configMapKey}
+	ci := newClaimIndex()
+	ci.set("other", nil, nil, 0, []objectClaim{claim})
+	expected := []claimConflict{{claim: claim, otherParent: "other", won: false}}
+	if conflicts := ci.getConflictsFor("parent", 0, []objectClaim{claim}); !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("expected %v, got %v", expected, conflicts)
+	}
+	if conflicts := ci.getConflicts("parent"); len(conflicts) != 0 {
+		t.Errorf("expected the claims not to be registered, got %v", conflicts)
+	}
+	ci.remove("other")
+	if conflicts := ci.getConflictsFor("parent", 0, []objectClaim{claim}); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts once the other parent is removed, got %v", conflicts)
+	}
+}
+
+func TestFilterLostClaims(t *testing.T) {
+	resources := []lockedresource.LockedResource{
+		newClaimedConfigMap(""),
+		newOrderedResource("apps/v1", "Deployment", "ns", "app", ""),
+	}
+	patches := []lockedpatch.LockedPatch{
+		newClaimingPatch("lost", "", types.MergePatchType, `{"data":{"a":"b"}}`),
+		newClaimingPatch("won", "", types.MergePatchType, `{"data":{"c":"d"}}`),
+	}
+	conflicts := []claimConflict{
+		{claim: objectClaim{objectKey: configMapObjectKey, resource: configMapKey}, otherParent: "other"},
+		{claim: objectClaim{objectKey: configMapObjectKey, patch: "lost"}, otherParent: "other"},
+		{claim: objectClaim{objectKey: configMapObjectKey, patch: "won"}, otherParent: "other", won: true},
+	}
+	enforcedResources, enforcedPatches := filterLostClaims(resources, patches, conflicts)
+	if len(enforcedResources) != 1 || enforcedResources[0].GetName() != "app" {
+		t.Errorf("expected only the deployment to be enforced, got %v", enforcedResources)
+	}
+	if len(enforcedPatches) != 1 || enforcedPatches[0].Name != "won" {
+		t.Errorf("expected only the won patch to be enforced, got %v", enforcedPatches)
+	}
+	lostResources, lostPatches := getLostClaims(conflicts)
+	if expected := map[string]string{configMapKey: "other"}; !reflect.DeepEqual(lostResources, expected) {
+		t.Errorf("expected %v, got %v", expected, lostResources)
+	}
+	if expected := map[string]string{"lost": "other"}; !reflect.DeepEqual(lostPatches, expected) {
+		t.Errorf("expected %v, got %v", expected, lostPatches)
+	}
+}
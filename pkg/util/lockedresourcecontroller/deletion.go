@@ -0,0 +1,109 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"encoding/json"
+
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DeletionPolicyAnnotation is the annotation in which the deletion policy of an enforced resource is stamped when pruning is enabled, so that it is known when the resource is pruned
+const DeletionPolicyAnnotation = "redhat-cop.io/locked-resource-deletion-policy"
+
+// PropagationPolicyAnnotation is the annotation in which the propagation policy of an enforced resource is stamped when pruning is enabled
+const PropagationPolicyAnnotation = "redhat-cop.io/locked-resource-propagation-policy"
+
+// deleteResource deletes the passed locked resource according to its deletion and propagation policies. It doesn't fail if the resource does not exist.
+// Resources in Audit and Disabled modes are never written, so they are left in place, as are resources in AdoptExisting mode if the object has not been adopted by the owner identified by ownerKey.
+func deleteResource(ctx context.Context, c client.Client, resource *lockedresource.LockedResource, ownerKey string) error {
+	mlog := log.FromContext(ctx)
+	if !isWritingMode(resource.GetMode()) {
+		mlog.V(1).Info("not deleting object that is not enforced", "object", resource.Unstructured, "mode", resource.GetMode())
+		return nil
+	}
+	if resource.GetMode() == utilsapi.EnforcementModeAdoptExisting {
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(resource.GroupVersionKind())
+		err := c.Get(ctx, client.ObjectKeyFromObject(&resource.Unstructured), current)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			mlog.Error(err, "unable to lookup", "object", resource.Unstructured)
+			return err
+		}
+		if current.GetAnnotations()[OwnerAnnotation] != ownerKey {
+			mlog.V(1).Info("not deleting object that has not been adopted", "object", resource.Unstructured)
+			return nil
+		}
+	}
+	switch resource.GetDeletionPolicy() {
+	case utilsapi.DeletionPolicyOrphan:
+		mlog.V(1).Info("orphaning", "resource", resource.Unstructured)
+		return nil
+	case utilsapi.DeletionPolicyOrphanAndUnlabel:
+		mlog.V(1).Info("orphaning and unlabeling", "resource", resource.Unstructured)
+		return unstampOwnership(ctx, c, &resource.Unstructured)
+	}
+	opts := []client.DeleteOption{}
+	if resource.PropagationPolicy != "" {
+		opts = append(opts, client.PropagationPolicy(metav1.DeletionPropagation(resource.PropagationPolicy)))
+	}
+	err := c.Delete(ctx, resource.Unstructured.DeepCopy(), opts...)
+	if err != nil && !apierrors.IsNotFound(err) {
+		mlog.Error(err, "unable to delete object ", "object", resource.Unstructured)
+		return err
+	}
+	return nil
+}
+
+// deleteResources deletes the passed locked resources, in the reverse order of their dependencies, according to their deletion and propagation policies
+func deleteResources(ctx context.Context, c client.Client, resources []lockedresource.LockedResource, kindOrdering bool, ownerKey string) error {
+	sortedResources, err := getDeletionOrder(resources, kindOrdering)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to sort resources by dependencies")
+		return err
+	}
+	for i := range sortedResources {
+		err := deleteResource(ctx, c, &sortedResources[i], ownerKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unstampOwnership removes from the passed object the ownership label and annotations
+func unstampOwnership(ctx context.Context, c client.Client, obj *unstructured.Unstructured) error {
+	patchMap := map[string]interface{}{}
+	setNestedValue(patchMap, []string{"metadata", "labels", OwnerLabel}, nil)
+	for _, annotation := range []string{OwnerAnnotation, DeletionPolicyAnnotation, PropagationPolicyAnnotation} {
+		setNestedValue(patchMap, []string{"metadata", "annotations", annotation}, nil)
+	}
+	data, err := json.Marshal(patchMap)
+	if err != nil {
+		return err
+	}
+	err = c.Patch(ctx, obj.DeepCopy(), client.RawPatch(types.MergePatchType, data))
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "unable to remove ownership from", "object", obj)
+		return err
+	}
+	return nil
+}
+
+// getStampedPolicies returns a locked resource for the passed object, with the deletion and propagation policies stamped on it
+func getStampedPolicies(obj *unstructured.Unstructured) lockedresource.LockedResource {
+	return lockedresource.LockedResource{
+		Unstructured:      *obj,
+		DeletionPolicy:    utilsapi.DeletionPolicy(obj.GetAnnotations()[DeletionPolicyAnnotation]),
+		PropagationPolicy: utilsapi.PropagationPolicy(obj.GetAnnotations()[PropagationPolicyAnnotation]),
+	}
+}
@@ -0,0 +1,112 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"testing"
+
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDeleteResource(t *testing.T) {
+	const ownerKey = "example.com/Parent/ns/parent"
+	tests := []struct {
+		name           string
+		mode           utilsapi.EnforcementMode
+		deletionPolicy utilsapi.DeletionPolicy
+		owner          string
+		deleted        bool
+	}{
+		{
+			name:    "enforced",
+			mode:    utilsapi.EnforcementModeEnforce,
+			deleted: true,
+		},
+		{
+			name:    "default mode",
+			deleted: true,
+		},
+		{
+			name:    "create only",
+			mode:    utilsapi.EnforcementModeCreateOnly,
+			deleted: true,
+		},
+		{
+			name:    "audited",
+			mode:    utilsapi.EnforcementModeAudit,
+			deleted: false,
+		},
+		{
+			name:    "disabled",
+			mode:    utilsapi.EnforcementModeDisabled,
+			deleted: false,
+		},
+		{
+			name:    "adopted",
+			mode:    utilsapi.EnforcementModeAdoptExisting,
+			owner:   ownerKey,
+			deleted: true,
+		},
+		{
+			name:    "not adopted",
+			mode:    utilsapi.EnforcementModeAdoptExisting,
+			owner:   "example.com/Parent/ns/other",
+			deleted: false,
+		},
+		{
+			name:           "orphaned",
+			mode:           utilsapi.EnforcementModeEnforce,
+			deletionPolicy: utilsapi.DeletionPolicyOrphan,
+			deleted:        false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			existing := newConfigMap("ns", "a")
+			if test.owner != "" {
+				existing.SetAnnotations(map[string]string{OwnerAnnotation: test.owner})
+			}
+			c := fake.NewClientBuilder().WithObjects(existing).Build()
+			resource := &lockedresource.LockedResource{
+				Unstructured:   *newConfigMap("ns", "a"),
+				Mode:           test.mode,
+				DeletionPolicy: test.deletionPolicy,
+			}
+			err := deleteResource(context.TODO(), c, resource, ownerKey)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = c.Get(context.TODO(), client.ObjectKeyFromObject(existing), newConfigMap("", ""))
+			if deleted := apierrors.IsNotFound(err); deleted != test.deleted {
+				t.Errorf("expected deleted to be %v, got %v (%v)", test.deleted, deleted, err)
+			}
+		})
+	}
+}
+
+// TestDeleteResourcesKeepsAudited checks the deletion performed by Stop(true) and by UpdateLockedResources on the resources that are no longer enforced
+func TestDeleteResourcesKeepsAudited(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(newConfigMap("ns", "enforced"), newConfigMap("ns", "audited")).Build()
+	resources := []lockedresource.LockedResource{
+		{Unstructured: *newConfigMap("ns", "enforced")},
+		{Unstructured: *newConfigMap("ns", "audited"), Mode: utilsapi.EnforcementModeAudit},
+	}
+	err := deleteResources(context.TODO(), c, resources, false, "example.com/Parent/ns/parent")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion("v1")
+	list.SetKind("ConfigMapList")
+	err = c.List(context.TODO(), list)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].GetName() != "audited" {
+		t.Errorf("expected only the audited resource to survive, got %v", list.Items)
+	}
+}
@@ -0,0 +1,85 @@
+package lockedresourcecontroller
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+// maxDriftSummaryPaths is the maximum number of paths listed in a drift summary
+const maxDriftSummaryPaths = 10
+
+// summarizeDrift returns a compact description of the paths at which the current state of an object differs from the desired state, ignoring the excluded paths.
+// Lists are compared as a whole, so a difference in a list element is reported at the path of the list.
+func summarizeDrift(current *unstructured.Unstructured, desired *unstructured.Unstructured, excludePaths []string) (string, error) {
+	filteredCurrent, err := lockedresource.FilterOutPaths(current, excludePaths)
+	if err != nil {
+		return "", err
+	}
+	filteredDesired, err := lockedresource.FilterOutPaths(desired, excludePaths)
+	if err != nil {
+		return "", err
+	}
+	paths := []string{}
+	collectDriftedPaths("", filteredCurrent.Object, filteredDesired.Object, &paths)
+	sort.Strings(paths)
+	if len(paths) > maxDriftSummaryPaths {
+		more := len(paths) - maxDriftSummaryPaths
+		paths = append(paths[:maxDriftSummaryPaths], "and "+strconv.Itoa(more)+" more")
+	}
+	return "drifted paths: " + strings.Join(paths, ", "), nil
+}
+
+func collectDriftedPaths(path string, current interface{}, desired interface{}, paths *[]string) {
+	currentMap, currentIsMap := current.(map[string]interface{})
+	desiredMap, desiredIsMap := desired.(map[string]interface{})
+	if currentIsMap && desiredIsMap {
+		for key := range desiredMap {
+			collectDriftedPaths(path+"."+key, currentMap[key], desiredMap[key], paths)
+		}
+		for key := range currentMap {
+			if _, ok := desiredMap[key]; !ok {
+				*paths = append(*paths, path+"."+key)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(current, desired) {
+		*paths = append(*paths, path)
+	}
+}
+
+// getFieldManager returns the field manager the api server records for the writes made with config: fieldManager if set,
+// otherwise the prefix of the user agent of config, from which the api server derives it, the user agent defaults to the one of client-go
+func getFieldManager(fieldManager string, config *rest.Config) string {
+	if fieldManager != "" {
+		return fieldManager
+	}
+	userAgent := rest.DefaultKubernetesUserAgent()
+	if config != nil && config.UserAgent != "" {
+		userAgent = config.UserAgent
+	}
+	return strings.SplitN(userAgent, "/", 2)[0]
+}
+
+// getLastFieldManager returns the field manager that most recently modified the passed object, ignoring the passed field manager, which is usually the one of the operator, see getFieldManager
+func getLastFieldManager(obj *unstructured.Unstructured, ignoredManager string) string {
+	lastManager := ""
+	var lastTime *metav1.Time
+	for _, managedFields := range obj.GetManagedFields() {
+		if managedFields.Manager == ignoredManager || managedFields.Time == nil {
+			continue
+		}
+		if lastTime == nil || !managedFields.Time.Before(lastTime) {
+			lastManager = managedFields.Manager
+			lastTime = managedFields.Time
+		}
+	}
+	return lastManager
+}
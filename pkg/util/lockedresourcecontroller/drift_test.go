@@ -0,0 +1,277 @@
+package lockedresourcecontroller
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-cop/operator-utils/pkg/util"
+	"github.com/redhat-cop/operator-utils/pkg/util/apis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestSummarizeDrift(t *testing.T) {
+	manyKeys := func(prefix string) map[string]interface{} {
+		data := map[string]interface{}{}
+		for i := 0; i < 12; i++ {
+			data["k"+strconv.Itoa(i)] = prefix
+		}
+		return data
+	}
+	tests := []struct {
+		name         string
+		current      map[string]interface{}
+		desired      map[string]interface{}
+		excludePaths []string
+		expected     string
+	}{
+		{
+			name:     "changed value",
+			current:  map[string]interface{}{"data": map[string]interface{}{"a": "1", "b": "2"}},
+			desired:  map[string]interface{}{"data": map[string]interface{}{"a": "1", "b": "3"}},
+			expected: "drifted paths: .data.b",
+		},
+		{
+			name:     "missing and extra fields",
+			current:  map[string]interface{}{"data": map[string]interface{}{"extra": "1"}},
+			desired:  map[string]interface{}{"data": map[string]interface{}{"missing": "1"}},
+			expected: "drifted paths: .data.extra, .data.missing",
+		},
+		{
+			name:     "list compared as a whole",
+			current:  map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{int64(80), int64(443)}}},
+			desired:  map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{int64(80)}}},
+			expected: "drifted paths: .spec.ports",
+		},
+		{
+			name:     "map replaced by a value",
+			current:  map[string]interface{}{"data": "x"},
+			desired:  map[string]interface{}{"data": map[string]interface{}{"a": "1"}},
+			expected: "drifted paths: .data",
+		},
+		{
+			name:         "excluded paths",
+			current:      map[string]interface{}{"data": map[string]interface{}{"a": "1", "b": "2"}, "status": map[string]interface{}{"phase": "x"}},
+			desired:      map[string]interface{}{"data": map[string]interface{}{"a": "2", "b": "2"}},
+			excludePaths: []string{".status", ".data.a"},
+			expected:     "drifted paths: ",
+		},
+		{
+			name:     "truncated",
+			current:  map[string]interface{}{"data": manyKeys("x")},
+			desired:  map[string]interface{}{"data": manyKeys("y")},
+			expected: "drifted paths: .data.k0, .data.k1, .data.k10, .data.k11, .data.k2, .data.k3, .data.k4, .data.k5, .data.k6, .data.k7, and 2 more",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current := &unstructured.Unstructured{Object: test.current}
+			current.SetAPIVersion("v1")
+			current.SetKind("ConfigMap")
+			desired := &unstructured.Unstructured{Object: test.desired}
+			desired.SetAPIVersion("v1")
+			desired.SetKind("ConfigMap")
+			summary, err := summarizeDrift(current, desired, test.excludePaths)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if summary != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, summary)
+			}
+		})
+	}
+}
+
+func TestManageDriftRecordsChangesOnly(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	statusChange := make(chan event.GenericEvent, 10)
+	lor := &LockedResourceReconciler{
+		Resource:       *newConfigMap("ns", "config"),
+		ReconcilerBase: util.NewReconcilerBase(nil, nil, nil, recorder, nil),
+		statusChange:   statusChange,
+		status:         []metav1.Condition{{Type: apis.ReconcileSuccess, Status: metav1.ConditionTrue}},
+		log:            ctrl.Log,
+	}
+	lpr := &LockedPatchReconciler{
+		ReconcilerBase: util.NewReconcilerBase(nil, nil, nil, recorder, nil),
+		statusChange:   statusChange,
+		status:         map[string][]metav1.Condition{},
+		log:            ctrl.Log,
+	}
+	target := newConfigMap("ns", "target")
+	tests := []struct {
+		name        string
+		manageDrift func(summary string)
+		getStatus   func() []metav1.Condition
+	}{
+		{
+			name:        "resource",
+			manageDrift: func(summary string) { lor.manageDrift(lor.Resource.DeepCopy(), summary) },
+			getStatus:   lor.GetStatus,
+		},
+		{
+			name:        "patch",
+			manageDrift: func(summary string) { lpr.manageDrift(target, summary) },
+			getStatus:   func() []metav1.Condition { return lpr.GetStatus()[apis.GetKeyShort(target)] },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, summary := range []string{"drifted paths: .data.a", "drifted paths: .data.a", "drifted paths: .data.b"} {
+				test.manageDrift(summary)
+				expected := 1
+				if i == 1 {
+					expected = 0
+				}
+				if len(recorder.Events) != expected {
+					t.Errorf("reconcile %d: expected %d events, got %d", i, expected, len(recorder.Events))
+				}
+				if len(statusChange) != expected {
+					t.Errorf("reconcile %d: expected %d status changes, got %d", i, expected, len(statusChange))
+				}
+				for len(recorder.Events) > 0 {
+					<-recorder.Events
+				}
+				for len(statusChange) > 0 {
+					<-statusChange
+				}
+				condition, ok := apis.GetCondition(apis.Drifted, test.getStatus())
+				if !ok || condition.Message != summary {
+					t.Errorf("reconcile %d: expected a Drifted condition with message %q, got %v", i, summary, test.getStatus())
+				}
+				if _, ok := apis.GetCondition(apis.ReconcileSuccess, test.getStatus()); ok {
+					t.Errorf("reconcile %d: expected ReconcileSuccess to be removed, got %v", i, test.getStatus())
+				}
+			}
+		})
+	}
+}
+
+func TestGetFieldManager(t *testing.T) {
+	tests := []struct {
+		name         string
+		fieldManager string
+		config       *rest.Config
+		expected     string
+	}{
+		{
+			name:         "field manager option",
+			fieldManager: "my-operator",
+			config:       &rest.Config{UserAgent: "other/v1.0.0"},
+			expected:     "my-operator",
+		},
+		{
+			name:     "user agent",
+			config:   &rest.Config{UserAgent: "my-operator/v1.0.0 (linux/amd64) kubernetes/abcdef"},
+			expected: "my-operator",
+		},
+		{
+			name:     "default user agent",
+			config:   &rest.Config{},
+			expected: strings.SplitN(rest.DefaultKubernetesUserAgent(), "/", 2)[0],
+		},
+		{
+			name:     "no config",
+			expected: strings.SplitN(rest.DefaultKubernetesUserAgent(), "/", 2)[0],
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if fieldManager := getFieldManager(test.fieldManager, test.config); fieldManager != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, fieldManager)
+			}
+		})
+	}
+}
+
+func newManagedConfigMap(managers ...string) *unstructured.Unstructured {
+	obj := newConfigMap("ns", "config")
+	managedFields := []metav1.ManagedFieldsEntry{}
+	for i, manager := range managers {
+		managedFields = append(managedFields, metav1.ManagedFieldsEntry{
+			Manager: manager,
+			Time:    &metav1.Time{Time: time.Date(2020, 1, 1, i, 0, 0, 0, time.UTC)},
+		})
+	}
+	obj.SetManagedFields(managedFields)
+	return obj
+}
+
+func TestGetLastFieldManager(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		expected string
+	}{
+		{
+			name:     "other manager wrote last",
+			obj:      newManagedConfigMap("my-operator", "kubectl"),
+			expected: "kubectl",
+		},
+		{
+			name:     "operator wrote last",
+			obj:      newManagedConfigMap("kubectl", "helm", "my-operator"),
+			expected: "helm",
+		},
+		{
+			name: "only the operator",
+			obj:  newManagedConfigMap("my-operator"),
+		},
+		{
+			name: "no managed fields",
+			obj:  newConfigMap("ns", "config"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if manager := getLastFieldManager(test.obj, "my-operator"); manager != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, manager)
+			}
+		})
+	}
+}
+
+func TestRecordCorrectionIgnoresOperator(t *testing.T) {
+	desired := newConfigMap("ns", "config")
+	current := newManagedConfigMap("kubectl", "my-operator")
+	current.Object["data"] = map[string]interface{}{"key": "value"}
+	tests := []struct {
+		name         string
+		fieldManager string
+		userAgent    string
+	}{
+		{
+			name:      "user agent",
+			userAgent: "my-operator/v1.0.0 (linux/amd64) kubernetes/abcdef",
+		},
+		{
+			name:         "server side apply field manager",
+			fieldManager: "my-operator",
+			userAgent:    "other/v1.0.0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lor := &LockedResourceReconciler{
+				Resource:       *desired,
+				ReconcilerBase: util.NewReconcilerBase(nil, nil, &rest.Config{UserAgent: test.userAgent}, record.NewFakeRecorder(10), nil),
+				status:         []metav1.Condition{{Type: apis.ReconcileSuccess, Status: metav1.ConditionTrue}},
+				options:        options{fieldManager: test.fieldManager, driftHistorySize: 1},
+				metricLabels:   metricLabels("/EnforcingCRD/ns/parent", desired.GroupVersionKind().String(), ""),
+				fightDetector:  newFightDetector(3, time.Minute, time.Minute),
+				log:            ctrl.Log,
+			}
+			lor.recordCorrection(current, desired, nil)
+			history := lor.GetDriftHistory()
+			if len(history) != 1 || history[0].ChangedBy != "kubectl" {
+				t.Errorf("expected one correction of a change by kubectl, got %v", history)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package lockedresourcecontroller
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/go-logr/logr"
@@ -10,6 +12,7 @@ import (
 	"github.com/redhat-cop/operator-utils/pkg/util/apis"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"github.com/redhat-cop/operator-utils/pkg/util/templates"
 	"github.com/scylladb/go-set/strset"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,7 +21,9 @@ import (
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -33,13 +38,17 @@ type EnforcingReconciler struct {
 	clusterWatchers             bool
 	log                         logr.Logger
 	returnOnlyFailingStatuses   bool
+	opts                        []Option
+	sharedCache                 *sharedCache
+	lookupWatcher               *lookupWatcher
 }
 
 // NewEnforcingReconciler creates a new EnforcingReconciler
 // clusterWatcher determines whether the created watchers should be at the cluster level or namespace level.
 // this affects the kind of permissions needed to run the controller
 // also creating multiple namespace level permissions can create performance issue as one watch per object type per namespace is opened to the API server, if in doubt pass true here.
-func NewEnforcingReconciler(client client.Client, scheme *runtime.Scheme, restConfig *rest.Config, apireader client.Reader, recorder record.EventRecorder, clusterWatchers bool, returnOnlyFailingStatuses bool) EnforcingReconciler {
+// opts can be used to enable optional behaviours, see Option.
+func NewEnforcingReconciler(client client.Client, scheme *runtime.Scheme, restConfig *rest.Config, apireader client.Reader, recorder record.EventRecorder, clusterWatchers bool, returnOnlyFailingStatuses bool, opts ...Option) EnforcingReconciler {
 	return EnforcingReconciler{
 		ReconcilerBase:              util.NewReconcilerBase(client, scheme, restConfig, recorder, apireader),
 		lockedResourceManagers:      map[string]*LockedResourceManager{},
@@ -48,11 +57,12 @@ func NewEnforcingReconciler(client client.Client, scheme *runtime.Scheme, restCo
 		clusterWatchers:             clusterWatchers,
 		log:                         ctrl.Log.WithName("enforcing-reconciler"),
 		returnOnlyFailingStatuses:   returnOnlyFailingStatuses,
+		opts:                        opts,
 	}
 }
 
-func NewFromManager(mgr manager.Manager, recorderName string, clusterWatchers bool, returnOnlyFailingStatuses bool) EnforcingReconciler {
-	return NewEnforcingReconciler(mgr.GetClient(), mgr.GetScheme(), mgr.GetConfig(), mgr.GetAPIReader(), mgr.GetEventRecorderFor(recorderName), clusterWatchers, returnOnlyFailingStatuses)
+func NewFromManager(mgr manager.Manager, recorderName string, clusterWatchers bool, returnOnlyFailingStatuses bool, opts ...Option) EnforcingReconciler {
+	return NewEnforcingReconciler(mgr.GetClient(), mgr.GetScheme(), mgr.GetConfig(), mgr.GetAPIReader(), mgr.GetEventRecorderFor(recorderName), clusterWatchers, returnOnlyFailingStatuses, opts...)
 }
 
 // GetStatusChangeChannel returns the channel through which status change events can be received
@@ -71,7 +81,17 @@ func (er *EnforcingReconciler) getLockedResourceManager(instance client.Object)
 	defer er.lockedResourceManagersMutex.Unlock()
 	lockedResourceManager, ok := er.lockedResourceManagers[apis.GetKeyShort(instance)]
 	if !ok {
-		lockedResourceManager, err := NewLockedResourceManager(er.GetRestConfig(), manager.Options{}, instance, er.statusChange, er.clusterWatchers)
+		opts, err := er.getOptions()
+		if err != nil {
+			er.log.Error(err, "unable to create shared cache")
+			return &LockedResourceManager{}, err
+		}
+		ownerKey, err := er.getOwnerKey(instance)
+		if err != nil {
+			return &LockedResourceManager{}, err
+		}
+		opts = append(opts, withOwnerKey(ownerKey))
+		lockedResourceManager, err := NewLockedResourceManager(er.GetRestConfig(), manager.Options{}, instance, er.statusChange, er.clusterWatchers, opts...)
 		if err != nil {
 			er.log.Error(err, "unable to create LockedResourceManager")
 			return &LockedResourceManager{}, err
@@ -82,11 +102,87 @@ func (er *EnforcingReconciler) getLockedResourceManager(instance client.Object)
 	return lockedResourceManager, nil
 }
 
+// getOwnerKey returns the key with which the objects enforced for the passed parent are stamped, see getObjectKey
+func (er *EnforcingReconciler) getOwnerKey(instance client.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(instance, er.GetScheme())
+	if err != nil {
+		er.log.Error(err, "unable to determine the type of", "parent", instance)
+		return "", err
+	}
+	return getObjectKey(gvk.GroupKind(), instance.GetNamespace(), instance.GetName()), nil
+}
+
+// getOptions returns the options to be passed to the LockedResourceManagers, lazily creating the shared cache if requested
+func (er *EnforcingReconciler) getOptions() ([]Option, error) {
+	opts := append([]Option{}, er.opts...)
+	opts = append(opts, withParentRecorder(er.GetRecorder()))
+	if !newOptions(er.opts...).useSharedCache {
+		return opts, nil
+	}
+	sharedCache, err := er.getSharedCache()
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, withSharedCache(sharedCache)), nil
+}
+
+// getSharedCache returns the shared cache, creating it the first time. It must be called holding lockedResourceManagersMutex.
+func (er *EnforcingReconciler) getSharedCache() (*sharedCache, error) {
+	if er.sharedCache == nil {
+		sharedCache, err := newSharedCache(er.GetRestConfig(), manager.Options{})
+		if err != nil {
+			return nil, err
+		}
+		er.sharedCache = sharedCache
+	}
+	return er.sharedCache, nil
+}
+
+// WatchLookups watches the objects looked up by the templates of instance, as recorded by a templates.LookupRecorder, see lockedresource.GetLockedResourcesFromTemplatesWithLookupRecorder.
+// When one of them is created, changed or deleted a generic event for instance is sent on the status change channel, so that its templates can be processed again.
+// The passed records replace the ones passed previously for the same instance, no records stop the watches. Watches are also stopped by Terminate.
+// The watches are served by the shared cache, so the operator needs the permissions to list and watch the looked up types at the cluster level.
+func (er *EnforcingReconciler) WatchLookups(instance client.Object, records []templates.LookupRecord) error {
+	lookupWatcher, err := er.getLookupWatcher()
+	if err != nil {
+		er.log.Error(err, "unable to create shared cache")
+		return err
+	}
+	err = lookupWatcher.set(apis.GetKeyShort(instance), instance, records)
+	if err != nil {
+		er.log.Error(err, "unable to watch the lookups of", "parent", instance)
+		return err
+	}
+	return nil
+}
+
+func (er *EnforcingReconciler) getLookupWatcher() (*lookupWatcher, error) {
+	er.lockedResourceManagersMutex.Lock()
+	defer er.lockedResourceManagersMutex.Unlock()
+	if er.lookupWatcher == nil {
+		sharedCache, err := er.getSharedCache()
+		if err != nil {
+			return nil, err
+		}
+		er.lookupWatcher = newLookupWatcher(sharedCache, er.GetRestConfig(), er.statusChange, er.log.WithName("lookup-watcher"))
+	}
+	return er.lookupWatcher, nil
+}
+
+func (er *EnforcingReconciler) removeLookups(instance client.Object) {
+	er.lockedResourceManagersMutex.Lock()
+	lookupWatcher := er.lookupWatcher
+	er.lockedResourceManagersMutex.Unlock()
+	if lookupWatcher != nil {
+		lookupWatcher.remove(apis.GetKeyShort(instance))
+	}
+}
+
 // UpdateLockedResources will do the following:
 //  1. initialize or retrieve the LockedResourceManager related to the passed parent resource
 //  2. compare the currently enforced resources with the one passed as parameters and then
 //     a. return immediately if they are the same
-//     b. restart the LockedResourceManager if they don't match
+//     b. update the LockedResourceManager if they don't match, only the reconcilers of the changed resources and patches are restarted
 func (er *EnforcingReconciler) UpdateLockedResources(context context.Context, instance client.Object, lockedResources []lockedresource.LockedResource, lockedPatches []lockedpatch.LockedPatch) error {
 	return er.UpdateLockedResourcesWithRestConfig(context, instance, lockedResources, lockedPatches, er.GetRestConfig())
 }
@@ -95,8 +191,9 @@ func (er *EnforcingReconciler) UpdateLockedResources(context context.Context, in
 //  1. initialize or retrieve the LockedResourceManager related to the passed parent resource
 //  2. compare the currently enforced resources with the one passed as parameters and then
 //     a. return immediately if they are the same
-//     b. restart the LockedResourceManager if they don't match
+//     b. update the LockedResourceManager if they don't match, only the reconcilers of the changed resources and patches are restarted
 //
+// Resources and patches that conflict with the ones of another parent, and that have a lower priority, are not enforced. See WithClaimPriority.
 // this variant allows passing a rest config
 func (er *EnforcingReconciler) UpdateLockedResourcesWithRestConfig(context context.Context, instance client.Object, lockedResources []lockedresource.LockedResource, lockedPatches []lockedpatch.LockedPatch, config *rest.Config) error {
 	lockedResourceManager, err := er.getLockedResourceManager(instance)
@@ -104,25 +201,79 @@ func (er *EnforcingReconciler) UpdateLockedResourcesWithRestConfig(context conte
 		er.log.Error(err, "unable to get LockedResourceManager")
 		return err
 	}
-	sameResources, leftDifference, _, _ := lockedResourceManager.IsSameResources(lockedResources)
+	parentKey := lockedResourceManager.getOwnerKey()
+	priority := getClaimPriority(newOptions(er.opts...), instance)
+	claims := getClaims(lockedResources, lockedPatches)
+	// the claims are registered only once they are enforced, so that a failed update does not make other parents yield to claims that are not enforced
+	conflicts := globalClaimIndex.getConflictsFor(parentKey, priority, claims)
+	enforcedResources, enforcedPatches := filterLostClaims(lockedResources, lockedPatches, conflicts)
+	sameResources, leftDifference, _, _ := lockedResourceManager.IsSameResources(enforcedResources)
 	//the resource in the leftDifference are not necessarily to be deleted, we need to check if the resource has simply been updated maintinign the sam type/namespace/value.
+	//resources lost to another parent are still needed, so they are not deleted.
 	toBeDeleted := getToBeDeletdResources(lockedResources, leftDifference)
-	samePatches, _, _, _ := lockedResourceManager.IsSamePatches(lockedPatches)
+	samePatches, _, _, _ := lockedResourceManager.IsSamePatches(enforcedPatches)
 	if !sameResources || !samePatches {
-		err = er.DeleteUnstructuredResources(context, lockedresource.AsListOfUnstructured(toBeDeleted))
+		// the resources that depend on lost resources are enforced, but they report a Conflict until the claims change
+		lostResources, _ := getLostClaims(conflicts)
+		lockedResourceManager.setLostResources(lostResources)
+		err := lockedResourceManager.Update(context, enforcedResources, enforcedPatches, config)
+		if err != nil {
+			er.log.Error(err, "unable to update", "manager", lockedResourceManager)
+			return err
+		}
+		globalClaimIndex.set(parentKey, instance, er.statusChange, priority, claims)
+		// resources are deleted once their reconcilers are stopped, so that they are not recreated or stamped again
+		err = deleteResources(log.IntoContext(context, er.log), er.GetClient(), toBeDeleted, newOptions(er.opts...).kindOrdering, lockedResourceManager.getOwnerKey())
 		if err != nil {
 			er.log.Error(err, "unable to delete unmanaged", "resources", leftDifference)
 			return err
 		}
-		err := lockedResourceManager.Restart(context, lockedResources, lockedPatches, false, config)
+		err = lockedResourceManager.Prune(context, lockedResources, config)
 		if err != nil {
-			er.log.Error(err, "unable to restart", "manager", lockedResourceManager)
+			er.log.Error(err, "unable to prune resources no longer owned by", "parent", instance)
 			return err
 		}
+		return nil
 	}
+	// nothing to update, but the priority of the claims or the parent object may have changed
+	globalClaimIndex.set(parentKey, instance, er.statusChange, priority, claims)
 	return nil
 }
 
+// Plan returns what UpdateLockedResources would do with the passed resources and patches, without modifying anything in the cluster.
+// See LockedResourceManager.Plan for details.
+func (er *EnforcingReconciler) Plan(context context.Context, instance client.Object, lockedResources []lockedresource.LockedResource, lockedPatches []lockedpatch.LockedPatch) (Plan, error) {
+	return er.PlanWithRestConfig(context, instance, lockedResources, lockedPatches, er.GetRestConfig())
+}
+
+// PlanWithRestConfig returns what UpdateLockedResourcesWithRestConfig would do with the passed resources and patches, without modifying anything in the cluster.
+// this variant allows passing a rest config
+func (er *EnforcingReconciler) PlanWithRestConfig(context context.Context, instance client.Object, lockedResources []lockedresource.LockedResource, lockedPatches []lockedpatch.LockedPatch, config *rest.Config) (Plan, error) {
+	er.lockedResourceManagersMutex.Lock()
+	lockedResourceManager, ok := er.lockedResourceManagers[apis.GetKeyShort(instance)]
+	er.lockedResourceManagersMutex.Unlock()
+	if !ok {
+		// nothing is enforced yet for this instance, a throwaway LockedResourceManager is enough
+		ownerKey, err := er.getOwnerKey(instance)
+		if err != nil {
+			return Plan{}, err
+		}
+		opts := append(append([]Option{}, er.opts...), withOwnerKey(ownerKey))
+		newLockedResourceManager, err := NewLockedResourceManager(config, manager.Options{}, instance, nil, er.clusterWatchers, opts...)
+		if err != nil {
+			er.log.Error(err, "unable to create LockedResourceManager")
+			return Plan{}, err
+		}
+		lockedResourceManager = &newLockedResourceManager
+	}
+	plan, err := lockedResourceManager.Plan(context, lockedResources, lockedPatches, config)
+	if err != nil {
+		er.log.Error(err, "unable to compute plan", "for instance", instance)
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
 func getToBeDeletdResources(neededResources []lockedresource.LockedResource, modifiedResources []lockedresource.LockedResource) []lockedresource.LockedResource {
 	neededResourceSet := strset.New()
 	modifiedResourcesSet := strset.New()
@@ -155,9 +306,10 @@ func (er *EnforcingReconciler) ManageError(context context.Context, instance cli
 			Status:             metav1.ConditionTrue,
 		}
 		status := v1alpha1.EnforcingReconcileStatus{
-			Conditions:             apis.AddOrReplaceCondition(condition, enforcingReconcileStatusAware.GetEnforcingReconcileStatus().Conditions),
-			LockedResourceStatuses: er.GetLockedResourceStatuses(instance),
-			LockedPatchStatuses:    er.GetLockedPatchStatuses(instance),
+			Conditions:                   er.manageClaimConflicts(instance, er.manageResourcesReady(instance, apis.AddOrReplaceCondition(condition, enforcingReconcileStatusAware.GetEnforcingReconcileStatus().Conditions))),
+			LockedResourceStatuses:       er.GetLockedResourceStatuses(instance),
+			LockedPatchStatuses:          er.GetLockedPatchStatuses(instance),
+			LockedResourceDriftHistories: er.GetLockedResourceDriftHistories(instance),
 		}
 		enforcingReconcileStatusAware.SetEnforcingReconcileStatus(status)
 		err := er.GetClient().Status().Update(context, instance)
@@ -186,9 +338,10 @@ func (er *EnforcingReconciler) ManageSuccess(context context.Context, instance c
 			Status:             metav1.ConditionTrue,
 		}
 		status := v1alpha1.EnforcingReconcileStatus{
-			Conditions:             apis.AddOrReplaceCondition(condition, enforcingReconcileStatusAware.GetEnforcingReconcileStatus().Conditions),
-			LockedResourceStatuses: er.GetLockedResourceStatuses(instance),
-			LockedPatchStatuses:    er.GetLockedPatchStatuses(instance),
+			Conditions:                   er.manageClaimConflicts(instance, er.manageResourcesReady(instance, apis.AddOrReplaceCondition(condition, enforcingReconcileStatusAware.GetEnforcingReconcileStatus().Conditions))),
+			LockedResourceStatuses:       er.GetLockedResourceStatuses(instance),
+			LockedPatchStatuses:          er.GetLockedPatchStatuses(instance),
+			LockedResourceDriftHistories: er.GetLockedResourceDriftHistories(instance),
 		}
 		enforcingReconcileStatusAware.SetEnforcingReconcileStatus(status)
 		err := er.GetClient().Status().Update(context, instance)
@@ -206,6 +359,69 @@ func (er *EnforcingReconciler) ManageSuccess(context context.Context, instance c
 	return reconcile.Result{}, nil
 }
 
+// manageClaimConflicts adds to the passed conditions a Conflict condition describing the conflicts between the claims of the passed parent and the ones of other parents, it removes it if there are none.
+// The transition time is kept as long as the conflicts do not change.
+func (er *EnforcingReconciler) manageClaimConflicts(instance client.Object, conditions []metav1.Condition) []metav1.Condition {
+	lockedResourceManager, err := er.getLockedResourceManager(instance)
+	if err != nil {
+		er.log.Error(err, "unable to get locked resource manager for", "parent", instance)
+		return conditions
+	}
+	conflicts := globalClaimIndex.getConflicts(lockedResourceManager.getOwnerKey())
+	if len(conflicts) == 0 {
+		return apis.RemoveConditionWithReason(apis.Conflict, apis.ClaimConflictReason, conditions)
+	}
+	condition := metav1.Condition{
+		Type:               apis.Conflict,
+		LastTransitionTime: metav1.Now(),
+		Message:            getConflictsMessage(conflicts),
+		ObservedGeneration: instance.GetGeneration(),
+		Reason:             apis.ClaimConflictReason,
+		Status:             metav1.ConditionTrue,
+	}
+	if current, ok := apis.GetCondition(apis.Conflict, conditions); ok && current.Reason == condition.Reason && current.Message == condition.Message {
+		condition.LastTransitionTime = current.LastTransitionTime
+	}
+	return apis.AddOrReplaceCondition(condition, conditions)
+}
+
+// manageResourcesReady adds to the passed conditions a ResourcesReady condition reporting whether all of the locked resources are healthy, if health checks are enabled.
+// The transition time is kept as long as the readiness does not change.
+func (er *EnforcingReconciler) manageResourcesReady(instance client.Object, conditions []metav1.Condition) []metav1.Condition {
+	if !newOptions(er.opts...).healthChecks {
+		return conditions
+	}
+	lockedResourceManager, err := er.getLockedResourceManager(instance)
+	if err != nil {
+		er.log.Error(err, "unable to get locked resource manager for", "parent", instance)
+		return conditions
+	}
+	notReady := []string{}
+	for _, lockedResourceReconciler := range lockedResourceManager.GetResourceReconcilers() {
+		healthy, ok := apis.GetCondition(apis.Healthy, lockedResourceReconciler.GetStatus())
+		if !ok || healthy.Status != metav1.ConditionTrue {
+			notReady = append(notReady, apis.GetKeyLong(&lockedResourceReconciler.Resource))
+		}
+	}
+	sort.Strings(notReady)
+	condition := metav1.Condition{
+		Type:               apis.ResourcesReady,
+		LastTransitionTime: metav1.Now(),
+		ObservedGeneration: instance.GetGeneration(),
+		Reason:             apis.ResourcesReadyReason,
+		Status:             metav1.ConditionTrue,
+	}
+	if len(notReady) > 0 {
+		condition.Reason = apis.ResourcesNotReadyReason
+		condition.Status = metav1.ConditionFalse
+		condition.Message = "not healthy yet: " + strings.Join(notReady, ", ")
+	}
+	if current, ok := apis.GetCondition(apis.ResourcesReady, conditions); ok && current.Status == condition.Status {
+		condition.LastTransitionTime = current.LastTransitionTime
+	}
+	return apis.AddOrReplaceCondition(condition, conditions)
+}
+
 // GetLockedResourceStatuses returns the status for all LockedResources
 func (er *EnforcingReconciler) GetLockedResourceStatuses(instance client.Object) map[string]v1alpha1.Conditions {
 	lockedResourceManager, err := er.getLockedResourceManager(instance)
@@ -227,6 +443,22 @@ func (er *EnforcingReconciler) GetLockedResourceStatuses(instance client.Object)
 	return lockedResourceReconcileStatuses
 }
 
+// GetLockedResourceDriftHistories returns the recent drift corrections for all LockedResources that have been corrected
+func (er *EnforcingReconciler) GetLockedResourceDriftHistories(instance client.Object) map[string]v1alpha1.DriftHistory {
+	lockedResourceManager, err := er.getLockedResourceManager(instance)
+	if err != nil {
+		er.log.Error(err, "unable to get locked resource manager for", "parent", instance)
+		return map[string]v1alpha1.DriftHistory{}
+	}
+	driftHistories := map[string]v1alpha1.DriftHistory{}
+	for _, lockedResourceReconciler := range lockedResourceManager.GetResourceReconcilers() {
+		if driftHistory := lockedResourceReconciler.GetDriftHistory(); len(driftHistory) > 0 {
+			driftHistories[apis.GetKeyLong(&lockedResourceReconciler.Resource)] = driftHistory
+		}
+	}
+	return driftHistories
+}
+
 // GetLockedPatchStatuses returns the status for all LockedPatches
 func (er *EnforcingReconciler) GetLockedPatchStatuses(instance client.Object) map[string]v1alpha1.ConditionMap {
 	lockedResourceManager, err := er.getLockedResourceManager(instance)
@@ -254,14 +486,17 @@ func (er *EnforcingReconciler) GetLockedPatchStatuses(instance client.Object) ma
 	return lockedPatchReconcileStatuses
 }
 
-// Terminate will stop the execution for the current instance. It will also optionally delete the locked resources.
+// Terminate will stop the execution for the current instance, including the watches of its lookups. It will also optionally delete the locked resources, according to their deletion policies. Patches with the Revert removal policy are always reverted.
 func (er *EnforcingReconciler) Terminate(instance client.Object, deleteResources bool) error {
 	defer er.removeLockedResourceManager(instance)
+	defer er.removeLookups(instance)
 	lockedResourceManager, err := er.getLockedResourceManager(instance)
 	if err != nil {
 		er.log.Error(err, "unable to get locked resource manager for", "parent", instance)
 		return err
 	}
+	// the parents whose claims were lost to this one are notified, so that they can take over
+	defer globalClaimIndex.remove(lockedResourceManager.getOwnerKey())
 	if lockedResourceManager.IsStarted() {
 		err = lockedResourceManager.Stop(deleteResources)
 		if err != nil {
@@ -269,5 +504,10 @@ func (er *EnforcingReconciler) Terminate(instance client.Object, deleteResources
 			return err
 		}
 	}
+	err = lockedResourceManager.RevertPatches(context.TODO(), lockedResourceManager.GetPatches())
+	if err != nil {
+		er.log.Error(err, "unable to revert patches of ", "lockedResourceManager", lockedResourceManager)
+		return err
+	}
 	return nil
 }
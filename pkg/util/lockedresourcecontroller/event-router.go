@@ -0,0 +1,178 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// eventRouter multiplexes the events of a shared cache to the reconcilers of many LockedResourceManagers.
+// A single event handler is registered on the informer of each GVK, events are then dispatched by <namespace>/<name> key to the subscribed handlers.
+// Handlers that cannot be keyed, for example because they select objects by label, subscribe to all the events of a GVK.
+type eventRouter struct {
+	cache  cache.Cache
+	routes map[schema.GroupVersionKind]*gvkRoute
+	lock   sync.Mutex
+}
+
+func newEventRouter(cache cache.Cache) *eventRouter {
+	return &eventRouter{
+		cache:  cache,
+		routes: map[schema.GroupVersionKind]*gvkRoute{},
+	}
+}
+
+// subscribe routes the events of the passed object type to the passed handler, until the passed context is done.
+// key is the <namespace>/<name> key of the objects of interest, an empty key subscribes to all the objects of the type.
+// Objects already present in the cache are replayed to the handler as create events.
+func (r *eventRouter) subscribe(ctx context.Context, object client.Object, key string, handler toolscache.ResourceEventHandler) error {
+	gvk := object.GetObjectKind().GroupVersionKind()
+	route, err := r.getRoute(ctx, object)
+	if err != nil {
+		return err
+	}
+	route.add(key, handler)
+	go func() {
+		<-ctx.Done()
+		route.remove(key, handler)
+	}()
+	if !r.cache.WaitForCacheSync(ctx) {
+		return ctx.Err()
+	}
+	return r.replay(ctx, gvk, key, handler)
+}
+
+func (r *eventRouter) getRoute(ctx context.Context, object client.Object) (*gvkRoute, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	gvk := object.GetObjectKind().GroupVersionKind()
+	if route, ok := r.routes[gvk]; ok {
+		return route, nil
+	}
+	informer, err := r.cache.GetInformer(ctx, object)
+	if err != nil {
+		return nil, err
+	}
+	route := &gvkRoute{
+		keyed:    map[string]map[toolscache.ResourceEventHandler]struct{}{},
+		wildcard: map[toolscache.ResourceEventHandler]struct{}{},
+	}
+	_, err = informer.AddEventHandler(route)
+	if err != nil {
+		return nil, err
+	}
+	r.routes[gvk] = route
+	return route, nil
+}
+
+func (r *eventRouter) replay(ctx context.Context, gvk schema.GroupVersionKind, key string, handler toolscache.ResourceEventHandler) error {
+	if key != "" {
+		namespace, name, err := toolscache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return err
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		err = r.cache.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		handler.OnAdd(obj, true)
+		return nil
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	err := r.cache.List(ctx, list)
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		handler.OnAdd(&list.Items[i], true)
+	}
+	return nil
+}
+
+// gvkRoute is the event handler registered on the informer of a GVK, it dispatches events to the subscribed handlers
+type gvkRoute struct {
+	keyed    map[string]map[toolscache.ResourceEventHandler]struct{}
+	wildcard map[toolscache.ResourceEventHandler]struct{}
+	lock     sync.RWMutex
+}
+
+func (r *gvkRoute) add(key string, handler toolscache.ResourceEventHandler) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if key == "" {
+		r.wildcard[handler] = struct{}{}
+		return
+	}
+	if _, ok := r.keyed[key]; !ok {
+		r.keyed[key] = map[toolscache.ResourceEventHandler]struct{}{}
+	}
+	r.keyed[key][handler] = struct{}{}
+}
+
+func (r *gvkRoute) remove(key string, handler toolscache.ResourceEventHandler) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if key == "" {
+		delete(r.wildcard, handler)
+		return
+	}
+	delete(r.keyed[key], handler)
+	if len(r.keyed[key]) == 0 {
+		delete(r.keyed, key)
+	}
+}
+
+// handlersFor returns the handlers interested in the passed object
+func (r *gvkRoute) handlersFor(obj interface{}) []toolscache.ResourceEventHandler {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, ok := obj.(client.Object)
+	if !ok {
+		return nil
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	handlers := []toolscache.ResourceEventHandler{}
+	for handler := range r.keyed[object.GetNamespace()+"/"+object.GetName()] {
+		handlers = append(handlers, handler)
+	}
+	for handler := range r.wildcard {
+		handlers = append(handlers, handler)
+	}
+	return handlers
+}
+
+// OnAdd implements toolscache.ResourceEventHandler
+func (r *gvkRoute) OnAdd(obj interface{}, isInInitialList bool) {
+	for _, handler := range r.handlersFor(obj) {
+		handler.OnAdd(obj, isInInitialList)
+	}
+}
+
+// OnUpdate implements toolscache.ResourceEventHandler
+func (r *gvkRoute) OnUpdate(oldObj, newObj interface{}) {
+	for _, handler := range r.handlersFor(newObj) {
+		handler.OnUpdate(oldObj, newObj)
+	}
+}
+
+// OnDelete implements toolscache.ResourceEventHandler
+func (r *gvkRoute) OnDelete(obj interface{}) {
+	for _, handler := range r.handlersFor(obj) {
+		handler.OnDelete(obj)
+	}
+}
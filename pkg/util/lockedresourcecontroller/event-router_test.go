@@ -0,0 +1,154 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+// recordingHandler records the events it receives as <event> <namespace>/<name>
+type recordingHandler struct {
+	name   string
+	events *[]string
+}
+
+func (h *recordingHandler) record(event string, obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object := obj.(*unstructured.Unstructured)
+	*h.events = append(*h.events, h.name+" "+event+" "+object.GetNamespace()+"/"+object.GetName())
+}
+
+func (h *recordingHandler) OnAdd(obj interface{}, isInInitialList bool) { h.record("add", obj) }
+func (h *recordingHandler) OnUpdate(oldObj, newObj interface{})         { h.record("update", newObj) }
+func (h *recordingHandler) OnDelete(obj interface{})                    { h.record("delete", obj) }
+
+func newConfigMap(namespace string, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestGVKRoute(t *testing.T) {
+	tests := []struct {
+		name     string
+		dispatch func(route *gvkRoute)
+		expected []string
+	}{
+		{
+			name: "keyed add",
+			dispatch: func(route *gvkRoute) {
+				route.OnAdd(newConfigMap("ns", "a"), false)
+			},
+			expected: []string{"a add ns/a", "wildcard add ns/a"},
+		},
+		{
+			name: "keyed update",
+			dispatch: func(route *gvkRoute) {
+				route.OnUpdate(newConfigMap("ns", "b"), newConfigMap("ns", "b"))
+			},
+			expected: []string{"b update ns/b", "wildcard update ns/b"},
+		},
+		{
+			name: "unkeyed object",
+			dispatch: func(route *gvkRoute) {
+				route.OnDelete(newConfigMap("other", "a"))
+			},
+			expected: []string{"wildcard delete other/a"},
+		},
+		{
+			name: "tombstone",
+			dispatch: func(route *gvkRoute) {
+				route.OnDelete(toolscache.DeletedFinalStateUnknown{Key: "ns/a", Obj: newConfigMap("ns", "a")})
+			},
+			expected: []string{"a delete ns/a", "wildcard delete ns/a"},
+		},
+		{
+			name: "not an object",
+			dispatch: func(route *gvkRoute) {
+				route.OnAdd("ns/a", false)
+			},
+			expected: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := []string{}
+			route := &gvkRoute{
+				keyed:    map[string]map[toolscache.ResourceEventHandler]struct{}{},
+				wildcard: map[toolscache.ResourceEventHandler]struct{}{},
+			}
+			route.add("ns/a", &recordingHandler{name: "a", events: &events})
+			route.add("ns/b", &recordingHandler{name: "b", events: &events})
+			route.add("", &recordingHandler{name: "wildcard", events: &events})
+			test.dispatch(route)
+			sort.Strings(events)
+			if !reflect.DeepEqual(events, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, events)
+			}
+		})
+	}
+}
+
+func TestGVKRouteRemove(t *testing.T) {
+	events := []string{}
+	route := &gvkRoute{
+		keyed:    map[string]map[toolscache.ResourceEventHandler]struct{}{},
+		wildcard: map[toolscache.ResourceEventHandler]struct{}{},
+	}
+	first := &recordingHandler{name: "first", events: &events}
+	second := &recordingHandler{name: "second", events: &events}
+	wildcard := &recordingHandler{name: "wildcard", events: &events}
+	route.add("ns/a", first)
+	route.add("ns/a", second)
+	route.add("", wildcard)
+	route.remove("ns/a", first)
+	route.remove("", wildcard)
+	route.OnAdd(newConfigMap("ns", "a"), false)
+	if expected := []string{"second add ns/a"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+	route.remove("ns/a", second)
+	if len(route.keyed) != 0 {
+		t.Errorf("expected no keys left, got %v", route.keyed)
+	}
+}
+
+func TestEventRouterSharesInformers(t *testing.T) {
+	informers := &informertest.FakeInformers{}
+	router := newEventRouter(informers)
+	first, err := router.getRoute(context.TODO(), newConfigMap("ns", "a"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := router.getRoute(context.TODO(), newConfigMap("other", "b"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second {
+		t.Error("expected objects of the same type to share a route")
+	}
+	if len(informers.InformersByGVK) != 1 {
+		t.Errorf("expected a single informer, got %d", len(informers.InformersByGVK))
+	}
+
+	events := []string{}
+	first.add("ns/a", &recordingHandler{name: "a", events: &events})
+	informer, err := informers.FakeInformerFor(newConfigMap("ns", "a"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	informer.Add(newConfigMap("ns", "a"))
+	if expected := []string{"a add ns/a"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+}
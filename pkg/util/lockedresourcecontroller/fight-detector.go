@@ -0,0 +1,116 @@
+package lockedresourcecontroller
+
+import (
+	"sync"
+	"time"
+)
+
+// fightInitialBackoff is the backoff applied when a fight is first detected, it doubles at every further correction
+const fightInitialBackoff = time.Second
+
+// fightDetector detects when a reconciler keeps correcting the same object, which happens when another controller writes the fields it enforces.
+// When threshold corrections happen inside window, the object is contested: further corrections are delayed with an exponential backoff, capped at maxBackoff.
+// A nil fightDetector never detects fights.
+type fightDetector struct {
+	threshold  int
+	window     time.Duration
+	maxBackoff time.Duration
+	fights     map[string]*fight
+	lock       sync.Mutex
+}
+
+type fight struct {
+	corrections  []time.Time
+	changedBy    string
+	backoffUntil time.Time
+}
+
+func newFightDetector(threshold int, window time.Duration, maxBackoff time.Duration) *fightDetector {
+	if threshold <= 0 {
+		return nil
+	}
+	return &fightDetector{
+		threshold:  threshold,
+		window:     window,
+		maxBackoff: maxBackoff,
+		fights:     map[string]*fight{},
+	}
+}
+
+// recordCorrection records a correction of the object identified by key. changedBy is the field manager that last changed the object before the correction, if known.
+func (fd *fightDetector) recordCorrection(key string, changedBy string, now time.Time) {
+	if fd == nil {
+		return
+	}
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	f, ok := fd.fights[key]
+	if !ok {
+		f = &fight{}
+		fd.fights[key] = f
+	}
+	f.corrections = append(fd.pruneCorrections(f.corrections, now), now)
+	if changedBy != "" {
+		f.changedBy = changedBy
+	}
+	if len(f.corrections) >= fd.threshold {
+		backoff := fightInitialBackoff << (len(f.corrections) - fd.threshold)
+		if backoff > fd.maxBackoff || backoff <= 0 {
+			backoff = fd.maxBackoff
+		}
+		f.backoffUntil = now.Add(backoff)
+	}
+}
+
+// getContest returns whether the object identified by key is contested and the field manager it is contested with, empty if unknown
+func (fd *fightDetector) getContest(key string, now time.Time) (bool, string) {
+	if fd == nil {
+		return false, ""
+	}
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	f, ok := fd.fights[key]
+	if !ok {
+		return false, ""
+	}
+	f.corrections = fd.pruneCorrections(f.corrections, now)
+	if len(f.corrections) == 0 && !now.Before(f.backoffUntil) {
+		delete(fd.fights, key)
+		return false, ""
+	}
+	return len(f.corrections) >= fd.threshold || now.Before(f.backoffUntil), f.changedBy
+}
+
+// getBackoff returns how long corrections of the object identified by key must still be delayed
+func (fd *fightDetector) getBackoff(key string, now time.Time) time.Duration {
+	if fd == nil {
+		return 0
+	}
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	f, ok := fd.fights[key]
+	if !ok || !now.Before(f.backoffUntil) {
+		return 0
+	}
+	return f.backoffUntil.Sub(now)
+}
+
+// forget drops what is known about the object identified by key
+func (fd *fightDetector) forget(key string) {
+	if fd == nil {
+		return
+	}
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	delete(fd.fights, key)
+}
+
+func (fd *fightDetector) pruneCorrections(corrections []time.Time, now time.Time) []time.Time {
+	result := []time.Time{}
+	for _, correction := range corrections {
+		if now.Sub(correction) < fd.window {
+			result = append(result, correction)
+		}
+	}
+	return result
+}
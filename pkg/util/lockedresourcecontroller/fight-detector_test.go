@@ -0,0 +1,138 @@
+package lockedresourcecontroller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFightDetector(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		changedBy []string
+		// corrections are the offsets from start of the recorded corrections
+		corrections []time.Duration
+		// at is the offset from start at which the detector is queried
+		at                time.Duration
+		expectedContested bool
+		expectedChangedBy string
+		expectedBackoff   time.Duration
+	}{
+		{
+			name:        "below threshold",
+			changedBy:   []string{"kubectl", "kubectl"},
+			corrections: []time.Duration{0, time.Second},
+			at:          2 * time.Second,
+		},
+		{
+			name:              "threshold reached",
+			changedBy:         []string{"kubectl", "kubectl", "kubectl"},
+			corrections:       []time.Duration{0, time.Second, 2 * time.Second},
+			at:                2 * time.Second,
+			expectedContested: true,
+			expectedChangedBy: "kubectl",
+			expectedBackoff:   time.Second,
+		},
+		{
+			name:              "exponential backoff",
+			changedBy:         []string{"kubectl", "kubectl", "kubectl", "kubectl", "kubectl"},
+			corrections:       []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second},
+			at:                4 * time.Second,
+			expectedContested: true,
+			expectedChangedBy: "kubectl",
+			expectedBackoff:   4 * time.Second,
+		},
+		{
+			name:              "capped backoff",
+			changedBy:         []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
+			corrections:       []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second, 5 * time.Second, 6 * time.Second, 7 * time.Second, 8 * time.Second, 9 * time.Second},
+			at:                9 * time.Second,
+			expectedContested: true,
+			expectedChangedBy: "j",
+			expectedBackoff:   10 * time.Second,
+		},
+		{
+			name:              "unknown field manager",
+			changedBy:         []string{"kubectl", "", ""},
+			corrections:       []time.Duration{0, time.Second, 2 * time.Second},
+			at:                2 * time.Second,
+			expectedContested: true,
+			expectedChangedBy: "kubectl",
+			expectedBackoff:   time.Second,
+		},
+		{
+			name:        "corrections outside window",
+			changedBy:   []string{"kubectl", "kubectl", "kubectl"},
+			corrections: []time.Duration{0, time.Second, 70 * time.Second},
+			at:          70 * time.Second,
+		},
+		{
+			name:              "backoff elapsed inside window",
+			changedBy:         []string{"kubectl", "kubectl", "kubectl"},
+			corrections:       []time.Duration{0, time.Second, 2 * time.Second},
+			at:                30 * time.Second,
+			expectedContested: true,
+			expectedChangedBy: "kubectl",
+		},
+		{
+			name:        "contest expired",
+			changedBy:   []string{"kubectl", "kubectl", "kubectl"},
+			corrections: []time.Duration{0, time.Second, 2 * time.Second},
+			at:          100 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fd := newFightDetector(3, time.Minute, 10*time.Second)
+			for i, correction := range test.corrections {
+				fd.recordCorrection("ns/a", test.changedBy[i], start.Add(correction))
+			}
+			now := start.Add(test.at)
+			if backoff := fd.getBackoff("ns/a", now); backoff != test.expectedBackoff {
+				t.Errorf("expected backoff %v, got %v", test.expectedBackoff, backoff)
+			}
+			contested, changedBy := fd.getContest("ns/a", now)
+			if contested != test.expectedContested {
+				t.Errorf("expected contested to be %v, got %v", test.expectedContested, contested)
+			}
+			if contested && changedBy != test.expectedChangedBy {
+				t.Errorf("expected changed by %q, got %q", test.expectedChangedBy, changedBy)
+			}
+			if contested, _ := fd.getContest("ns/b", now); contested {
+				t.Error("expected other objects not to be contested")
+			}
+		})
+	}
+}
+
+func TestFightDetectorForget(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	fd := newFightDetector(1, time.Minute, 10*time.Second)
+	fd.recordCorrection("ns/a", "kubectl", start)
+	if contested, _ := fd.getContest("ns/a", start); !contested {
+		t.Fatal("expected the object to be contested")
+	}
+	fd.forget("ns/a")
+	if contested, _ := fd.getContest("ns/a", start); contested {
+		t.Error("expected the object to be forgotten")
+	}
+	if backoff := fd.getBackoff("ns/a", start); backoff != 0 {
+		t.Errorf("expected no backoff, got %v", backoff)
+	}
+}
+
+func TestFightDetectorDisabled(t *testing.T) {
+	fd := newFightDetector(0, time.Minute, 10*time.Second)
+	if fd != nil {
+		t.Fatal("expected a nil detector when the threshold is not positive")
+	}
+	now := time.Now()
+	fd.recordCorrection("ns/a", "kubectl", now)
+	if contested, _ := fd.getContest("ns/a", now); contested {
+		t.Error("expected a nil detector never to detect fights")
+	}
+	if backoff := fd.getBackoff("ns/a", now); backoff != 0 {
+		t.Errorf("expected no backoff, got %v", backoff)
+	}
+	fd.forget("ns/a")
+}
@@ -0,0 +1,174 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"errors"
+
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var _ source.SyncingSource = &informerSource{}
+
+// informerSource is a source.SyncingSource that registers an event handler on the shared informer of a cache.
+// Differently from source.Kind, the event handler is removed from the informer when the controller using the source is stopped,
+// so that controllers can be started and stopped on a long-lived cache without leaking event handlers.
+// When a router is set, the event handler subscribes to the router with the given key instead of being registered on the informer.
+type informerSource struct {
+	cache   cache.Cache
+	object  client.Object
+	router  *eventRouter
+	key     string
+	started chan error
+}
+
+func newInformerSource(cache cache.Cache, object client.Object) *informerSource {
+	return &informerSource{
+		cache:  cache,
+		object: object,
+	}
+}
+
+// newSource returns a source for the passed object, routed through the shared cache router if configured.
+// key is the <namespace>/<name> key of the objects of interest, an empty key selects all the objects of the type.
+func newSource(cache cache.Cache, object client.Object, key string, o options) *informerSource {
+	source := newInformerSource(cache, object)
+	if o.sharedCache != nil {
+		source.router = o.sharedCache.router
+		source.key = key
+	}
+	return source
+}
+
+// Start implements source.Source
+func (s *informerSource) Start(ctx context.Context, h handler.EventHandler, queue workqueue.RateLimitingInterface, prcts ...predicate.Predicate) error {
+	s.started = make(chan error)
+	eventHandler := &informerEventHandler{
+		ctx:        ctx,
+		handler:    h,
+		queue:      queue,
+		predicates: prcts,
+	}
+	if s.router != nil {
+		go func() {
+			err := s.router.subscribe(ctx, s.object, s.key, eventHandler)
+			if err != nil {
+				s.started <- err
+				return
+			}
+			close(s.started)
+		}()
+		return nil
+	}
+	go func() {
+		informer, err := s.cache.GetInformer(ctx, s.object)
+		if err != nil {
+			s.started <- err
+			return
+		}
+		registration, err := informer.AddEventHandler(eventHandler)
+		if err != nil {
+			s.started <- err
+			return
+		}
+		go func() {
+			<-ctx.Done()
+			_ = informer.RemoveEventHandler(registration)
+		}()
+		if !s.cache.WaitForCacheSync(ctx) {
+			s.started <- errors.New("cache did not sync")
+			return
+		}
+		close(s.started)
+	}()
+	return nil
+}
+
+// WaitForSync implements source.SyncingSource
+func (s *informerSource) WaitForSync(ctx context.Context) error {
+	select {
+	case err := <-s.started:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil
+		}
+		return errors.New("timed out waiting for cache to be synced")
+	}
+}
+
+func (s *informerSource) String() string {
+	return "informer source: " + s.object.GetObjectKind().GroupVersionKind().String()
+}
+
+// informerEventHandler adapts a handler.EventHandler and its predicates to a client-go event handler
+type informerEventHandler struct {
+	ctx        context.Context
+	handler    handler.EventHandler
+	queue      workqueue.RateLimitingInterface
+	predicates []predicate.Predicate
+}
+
+// OnAdd implements toolscache.ResourceEventHandler
+func (e *informerEventHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	object, ok := obj.(client.Object)
+	if !ok {
+		return
+	}
+	evt := event.CreateEvent{Object: object}
+	for _, p := range e.predicates {
+		if !p.Create(evt) {
+			return
+		}
+	}
+	e.handler.Create(e.ctx, evt, e.queue)
+}
+
+// OnUpdate implements toolscache.ResourceEventHandler
+func (e *informerEventHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldObject, ok := oldObj.(client.Object)
+	if !ok {
+		return
+	}
+	newObject, ok := newObj.(client.Object)
+	if !ok {
+		return
+	}
+	evt := event.UpdateEvent{ObjectOld: oldObject, ObjectNew: newObject}
+	for _, p := range e.predicates {
+		if !p.Update(evt) {
+			return
+		}
+	}
+	e.handler.Update(e.ctx, evt, e.queue)
+}
+
+// OnDelete implements toolscache.ResourceEventHandler
+func (e *informerEventHandler) OnDelete(obj interface{}) {
+	evt := event.DeleteEvent{}
+	switch object := obj.(type) {
+	case client.Object:
+		evt.Object = object
+	case toolscache.DeletedFinalStateUnknown:
+		tombstoned, ok := object.Obj.(client.Object)
+		if !ok {
+			return
+		}
+		evt.Object = tombstoned
+		evt.DeleteStateUnknown = true
+	default:
+		return
+	}
+	for _, p := range e.predicates {
+		if !p.Delete(evt) {
+			return
+		}
+	}
+	e.handler.Delete(e.ctx, evt, e.queue)
+}
@@ -0,0 +1,361 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/go-logr/logr"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/apis"
+	"github.com/scylladb/go-set/strset"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authenticationv1beta1 "k8s.io/api/authentication/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+)
+
+// defaultExemptUsers are the users whose requests are always allowed by the LockGuard, they delete objects on behalf of the cluster, for example when the owner or the namespace of a locked object is deleted
+var defaultExemptUsers = []string{
+	"system:serviceaccount:kube-system:generic-garbage-collector",
+	"system:serviceaccount:kube-system:namespace-controller",
+}
+
+// selfLookupInterval is the minimum time between two lookups of the identity of the operator, when they fail
+const selfLookupInterval = 10 * time.Second
+
+// managerLocks are the reconcilers of a started LockedResourceManager, as known to the LockGuard
+type managerLocks struct {
+	ownerKey            string
+	resourceReconcilers []*LockedResourceReconciler
+	patchReconcilers    []*LockedPatchReconciler
+}
+
+// lockIndex records the reconcilers of all the started LockedResourceManagers of the process, so that the LockGuard knows which objects are locked
+type lockIndex struct {
+	managers map[*LockedResourceManager]managerLocks
+	lock     sync.RWMutex
+}
+
+var globalLockIndex = &lockIndex{
+	managers: map[*LockedResourceManager]managerLocks{},
+}
+
+// set records the current reconcilers of the passed LockedResourceManager
+func (li *lockIndex) set(lrm *LockedResourceManager) {
+	li.lock.Lock()
+	defer li.lock.Unlock()
+	li.managers[lrm] = managerLocks{
+		ownerKey:            lrm.getOwnerKey(),
+		resourceReconcilers: append([]*LockedResourceReconciler{}, lrm.resourceReconcilers...),
+		patchReconcilers:    append([]*LockedPatchReconciler{}, lrm.patchReconcilers...),
+	}
+}
+
+// remove forgets the reconcilers of the passed LockedResourceManager
+func (li *lockIndex) remove(lrm *LockedResourceManager) {
+	li.lock.Lock()
+	defer li.lock.Unlock()
+	delete(li.managers, lrm)
+}
+
+func (li *lockIndex) list() []managerLocks {
+	li.lock.RLock()
+	defer li.lock.RUnlock()
+	locks := []managerLocks{}
+	for _, managerLocks := range li.managers {
+		locks = append(locks, managerLocks)
+	}
+	return locks
+}
+
+// LockGuard is a validating admission handler that prevents changes to locked objects instead of reverting them afterwards.
+// It is fed from the LockedResourceManagers started in the same process and it rejects:
+// the deletion of objects enforced by a locked resource, the updates that change the paths of an object enforced by a locked resource that are not excluded,
+// and the updates that change the fields of an object set by a locked patch, leaving them different from what the patch sets.
+// Updates that leave the object in its locked state are allowed, as well as any request from the identity of the operator and from the exempt users.
+// Resources and patches that are not in the Enforce mode are not locked, neither are objects in the AdoptExisting mode that have not been adopted. Requests to subresources are not checked.
+// Locks are evaluated only when the object is sent in the same version as the locked resource or the patch target. When a lock cannot be evaluated, the request is allowed and the reconcilers correct any drift as usual.
+type LockGuard struct {
+	config         *rest.Config
+	exemptUsers    *strset.Set
+	self           string
+	lastSelfLookup time.Time
+	// selfLookupFailed is set once a failed lookup of the identity of the operator has been logged, the following failures are only logged at debug level
+	selfLookupFailed bool
+	selfLock         sync.Mutex
+	log              logr.Logger
+}
+
+var _ admission.Handler = &LockGuard{}
+
+// NewLockGuard returns a LockGuard for the LockedResourceManagers of the process. config is the rest config of the operator, its identity is exempt from the checks, as well as the passed users.
+func NewLockGuard(config *rest.Config, exemptUsers ...string) *LockGuard {
+	return &LockGuard{
+		config:      config,
+		exemptUsers: strset.New(append(exemptUsers, defaultExemptUsers...)...),
+		log:         ctrl.Log.WithName("lock-guard"),
+	}
+}
+
+// Handle validates an admission request
+func (lg *LockGuard) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if (req.Operation != admissionv1.Update && req.Operation != admissionv1.Delete) || req.SubResource != "" {
+		return admission.Allowed("")
+	}
+	if lg.isExempt(ctx, req.UserInfo.Username) {
+		return admission.Allowed("")
+	}
+	oldObj := &unstructured.Unstructured{}
+	err := oldObj.UnmarshalJSON(req.OldObject.Raw)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	var newObj *unstructured.Unstructured
+	if req.Operation == admissionv1.Update {
+		newObj = &unstructured.Unstructured{}
+		err = newObj.UnmarshalJSON(req.Object.Raw)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	ctx = context.WithValue(ctx, "restConfig", lg.config)
+	ctx = log.IntoContext(ctx, lg.log)
+	violations := []string{}
+	for _, locks := range globalLockIndex.list() {
+		for _, reconciler := range locks.resourceReconcilers {
+			violation, err := getResourceLockViolation(reconciler, oldObj, newObj)
+			if err != nil {
+				lg.log.Error(err, "unable to evaluate the lock of", "resource", apis.GetKeyLong(&reconciler.Resource), "on object", apis.GetKeyLong(oldObj))
+				continue
+			}
+			if violation != "" {
+				violations = append(violations, violation+" locked by "+locks.ownerKey)
+			}
+		}
+		if newObj == nil {
+			continue
+		}
+		for _, reconciler := range locks.patchReconcilers {
+			violation, err := getPatchLockViolation(ctx, reconciler, oldObj, newObj)
+			if err != nil {
+				lg.log.Error(err, "unable to evaluate the lock of", "patch", reconciler.patch.GetKey(), "on object", apis.GetKeyLong(newObj))
+				continue
+			}
+			if violation != "" {
+				violations = append(violations, violation+" locked by patch "+reconciler.patch.GetKey()+" of "+locks.ownerKey)
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return admission.Denied(strings.Join(violations, "; "))
+	}
+	return admission.Allowed("")
+}
+
+// isExempt returns whether the requests of the passed user are always allowed
+func (lg *LockGuard) isExempt(ctx context.Context, username string) bool {
+	if lg.exemptUsers.Has(username) {
+		return true
+	}
+	self := lg.getSelf(ctx)
+	return self != "" && username == self
+}
+
+// getSelf returns the user of the operator, empty if it is not known. It is looked up until a lookup succeeds, no more often than every selfLookupInterval.
+func (lg *LockGuard) getSelf(ctx context.Context) string {
+	lg.selfLock.Lock()
+	defer lg.selfLock.Unlock()
+	if lg.self != "" || time.Since(lg.lastSelfLookup) < selfLookupInterval {
+		return lg.self
+	}
+	lg.lastSelfLookup = time.Now()
+	clientset, err := kubernetes.NewForConfig(lg.config)
+	if err != nil {
+		lg.log.Error(err, "unable to create clientset")
+		return ""
+	}
+	self, err := lookupSelf(ctx, clientset)
+	if err != nil {
+		if lg.selfLookupFailed {
+			lg.log.V(1).Info("unable to look up the identity of the operator", "error", err.Error())
+			return ""
+		}
+		lg.selfLookupFailed = true
+		lg.log.Error(err, "unable to look up the identity of the operator, until it is known its requests are not exempt. Pass the user of the operator, such as system:serviceaccount:<namespace>:<name> for its service account, as an exempt user to NewLockGuard")
+		return ""
+	}
+	lg.self = self
+	return lg.self
+}
+
+// lookupSelf returns the user of the passed clientset with a SelfSubjectReview, the v1beta1 API is used on the clusters that do not serve the v1 API, before Kubernetes 1.28
+func lookupSelf(ctx context.Context, clientset kubernetes.Interface) (string, error) {
+	review, err := clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err == nil {
+		return review.Status.UserInfo.Username, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	betaReview, err := clientset.AuthenticationV1beta1().SelfSubjectReviews().Create(ctx, &authenticationv1beta1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return betaReview.Status.UserInfo.Username, nil
+}
+
+// getResourceLockViolation returns a description of how the request breaks the lock of the locked resource of the passed reconciler on the object, empty if it doesn't. newObj is nil for deletions.
+func getResourceLockViolation(reconciler *LockedResourceReconciler, oldObj *unstructured.Unstructured, newObj *unstructured.Unstructured) (string, error) {
+	if reconciler.Resource.GroupVersionKind().GroupKind() != oldObj.GroupVersionKind().GroupKind() ||
+		reconciler.Resource.GetNamespace() != oldObj.GetNamespace() || reconciler.Resource.GetName() != oldObj.GetName() {
+		return "", nil
+	}
+	if reconciler.Mode != utilsapi.EnforcementModeEnforce && (reconciler.Mode != utilsapi.EnforcementModeAdoptExisting || !reconciler.isOwned(oldObj)) {
+		return "", nil
+	}
+	if newObj == nil {
+		return "deletion of " + apis.GetKeyLong(oldObj) + " is", nil
+	}
+	if reconciler.Resource.GetAPIVersion() != newObj.GetAPIVersion() {
+		return "", nil
+	}
+	desired := &reconciler.Resource
+	excludePaths := append(append([]string{}, reconciler.ExcludePaths...), serverManagedPaths...)
+	if reconciler.options.serverSideApply {
+		// only the fields declared in the resource are owned with server-side apply, so the desired state is the current one with those fields applied
+		data, err := getApplyObject(&reconciler.Resource).MarshalJSON()
+		if err != nil {
+			return "", err
+		}
+		desired, err = applyPatchLocally(newObj, client.RawPatch(types.MergePatchType, data))
+		if err != nil {
+			return "", err
+		}
+		excludePaths = serverManagedPaths
+	}
+	compliant, err := isEqualIgnoringPaths(desired, newObj, excludePaths)
+	if err != nil || compliant {
+		return "", err
+	}
+	unchanged, err := isEqualIgnoringPaths(oldObj, newObj, excludePaths)
+	if err != nil || unchanged {
+		return "", err
+	}
+	summary, err := summarizeDrift(newObj, desired, excludePaths)
+	if err != nil {
+		return "", err
+	}
+	return "update of " + apis.GetKeyLong(newObj) + " with " + summary + " is", nil
+}
+
+// getPatchLockViolation returns a description of how the update breaks the lock of the patch of the passed reconciler on the object, empty if it doesn't
+func getPatchLockViolation(ctx context.Context, reconciler *LockedPatchReconciler, oldObj *unstructured.Unstructured, newObj *unstructured.Unstructured) (string, error) {
+	if reconciler.patch.GetMode() != utilsapi.EnforcementModeEnforce || !mayTarget(&reconciler.patch.TargetObjectRef, newObj) {
+		return "", nil
+	}
+	selected, err := reconciler.patch.TargetObjectRef.Selects(ctx, newObj)
+	if err != nil || !selected {
+		return "", err
+	}
+	patch, err := renderPatch(ctx, &reconciler.patch, newObj)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// with a source missing the patch is not enforced
+			return "", nil
+		}
+		return "", err
+	}
+	patched, err := applyPatchLocally(newObj, patch)
+	if err != nil {
+		return "", err
+	}
+	compliant, err := isEqualIgnoringPaths(newObj, patched, serverManagedPaths)
+	if err != nil || compliant {
+		return "", err
+	}
+	unchanged, err := isEqualIgnoringPaths(oldObj, newObj, serverManagedPaths)
+	if err != nil || unchanged {
+		return "", err
+	}
+	summary, err := summarizeDrift(newObj, patched, serverManagedPaths)
+	if err != nil {
+		return "", err
+	}
+	return "update of " + apis.GetKeyLong(newObj) + " with " + summary + " is", nil
+}
+
+// mayTarget returns whether the target reference may select the passed object, comparing only the fields that do not need a lookup, so that the patches are rendered only for the objects they may target.
+// The namespace is not compared for cluster level objects, as it is ignored by TargetObjectReference.Selects.
+func mayTarget(target *utilsapi.TargetObjectReference, obj *unstructured.Unstructured) bool {
+	return target.APIVersion == obj.GetAPIVersion() && target.Kind == obj.GetKind() &&
+		(target.Namespace == "" || obj.GetNamespace() == "" || target.Namespace == obj.GetNamespace()) &&
+		(target.Name == "" || target.Name == obj.GetName())
+}
+
+// applyPatchLocally returns the result of applying the patch to the passed object, computed without calling the API server.
+// Strategic merge patches on types that are not known to the client-go scheme and apply patches are computed as merge patches.
+func applyPatchLocally(obj *unstructured.Unstructured, patch client.Patch) (*unstructured.Unstructured, error) {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return nil, err
+	}
+	original, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var patched []byte
+	switch patch.Type() {
+	case types.JSONPatchType:
+		decoded, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return nil, err
+		}
+		patched, err = decoded.Apply(original)
+		if err != nil {
+			return nil, err
+		}
+	case types.StrategicMergePatchType:
+		if dataStruct, err := clientgoscheme.Scheme.New(obj.GroupVersionKind()); err == nil {
+			patched, err = strategicpatch.StrategicMergePatch(original, data, dataStruct)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		patched, err = jsonpatch.MergePatch(original, data)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		data, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		patched, err = jsonpatch.MergePatch(original, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := &unstructured.Unstructured{}
+	err = result.UnmarshalJSON(patched)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
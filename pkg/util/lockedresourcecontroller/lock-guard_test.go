@@ -0,0 +1,145 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"k8s.io/client-go/rest"
+)
+
+func TestMayTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   utilsapi.TargetObjectReference
+		expected bool
+	}{
+		{
+			name:     "same object",
+			target:   utilsapi.TargetObjectReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "a"},
+			expected: true,
+		},
+		{
+			name:     "any name",
+			target:   utilsapi.TargetObjectReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns"},
+			expected: true,
+		},
+		{
+			name:     "any namespace",
+			target:   utilsapi.TargetObjectReference{APIVersion: "v1", Kind: "ConfigMap"},
+			expected: true,
+		},
+		{
+			name:     "other kind",
+			target:   utilsapi.TargetObjectReference{APIVersion: "v1", Kind: "Secret", Namespace: "ns", Name: "a"},
+			expected: false,
+		},
+		{
+			name:     "other version",
+			target:   utilsapi.TargetObjectReference{APIVersion: "v2", Kind: "ConfigMap", Namespace: "ns", Name: "a"},
+			expected: false,
+		},
+		{
+			name:     "other namespace",
+			target:   utilsapi.TargetObjectReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "other"},
+			expected: false,
+		},
+		{
+			name:     "other name",
+			target:   utilsapi.TargetObjectReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "b"},
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if mayTarget := mayTarget(&test.target, newConfigMap("ns", "a")); mayTarget != test.expected {
+				t.Errorf("expected may target to be %v, got %v", test.expected, mayTarget)
+			}
+		})
+	}
+	// the namespace of the target is ignored for cluster level objects
+	target := utilsapi.TargetObjectReference{APIVersion: "v1", Kind: "Namespace", Namespace: "ns", Name: "a"}
+	obj := newConfigMap("", "a")
+	obj.SetKind("Namespace")
+	if !mayTarget(&target, obj) {
+		t.Error("expected the target to select the cluster level object")
+	}
+}
+
+func TestLockGuardRetriesSelfLookup(t *testing.T) {
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/authentication.k8s.io/v1/selfsubjectreviews" {
+			http.NotFound(w, r)
+			return
+		}
+		if failures > 0 {
+			failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"apiVersion":"authentication.k8s.io/v1","kind":"SelfSubjectReview","status":{"userInfo":{"username":"operator"}}}`))
+	}))
+	defer server.Close()
+	lg := NewLockGuard(&rest.Config{Host: server.URL}, "admin")
+	if !lg.isExempt(context.TODO(), "admin") {
+		t.Error("expected the passed users to be exempt")
+	}
+	if lg.isExempt(context.TODO(), "operator") {
+		t.Error("expected the operator not to be exempt while its identity is not known")
+	}
+	if lg.isExempt(context.TODO(), "operator") {
+		t.Error("expected the lookup not to be retried before the interval")
+	}
+	lg.lastSelfLookup = time.Now().Add(-selfLookupInterval)
+	if !lg.isExempt(context.TODO(), "operator") {
+		t.Error("expected the lookup to be retried after a failure")
+	}
+	if lg.isExempt(context.TODO(), "user") {
+		t.Error("expected other users not to be exempt")
+	}
+}
+
+func TestLockGuardFallsBackToV1beta1SelfLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/authentication.k8s.io/v1beta1/selfsubjectreviews" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"apiVersion":"authentication.k8s.io/v1beta1","kind":"SelfSubjectReview","status":{"userInfo":{"username":"operator"}}}`))
+	}))
+	defer server.Close()
+	lg := NewLockGuard(&rest.Config{Host: server.URL})
+	if !lg.isExempt(context.TODO(), "operator") {
+		t.Error("expected the operator to be exempt on clusters that only serve the v1beta1 SelfSubjectReviews")
+	}
+}
+
+func TestLockGuardLogsSelfLookupFailureOnce(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	lg := NewLockGuard(&rest.Config{Host: server.URL})
+	messages := []string{}
+	lg.log = funcr.New(func(prefix, args string) {
+		messages = append(messages, args)
+	}, funcr.Options{})
+	for i := 0; i < 3; i++ {
+		lg.lastSelfLookup = time.Now().Add(-selfLookupInterval)
+		if lg.isExempt(context.TODO(), "operator") {
+			t.Error("expected the operator not to be exempt while its identity is not known")
+		}
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected the failure to be logged once, got %v", messages)
+	}
+	if !strings.Contains(messages[0], "as an exempt user") {
+		t.Errorf("expected the log to explain how to exempt the operator, got %q", messages[0])
+	}
+}
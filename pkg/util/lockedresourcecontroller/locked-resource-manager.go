@@ -6,21 +6,16 @@ import (
 	"errors"
 
 	"github.com/go-logr/logr"
-	multierror "github.com/hashicorp/go-multierror"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
 	"github.com/redhat-cop/operator-utils/pkg/util"
 	"github.com/redhat-cop/operator-utils/pkg/util/apis"
-	"github.com/redhat-cop/operator-utils/pkg/util/discoveryclient"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource/lockedresourceset"
 	"github.com/redhat-cop/operator-utils/pkg/util/stoppablemanager"
-	"github.com/redhat-cop/operator-utils/pkg/util/templates"
 	"github.com/scylladb/go-set/strset"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
-	"k8s.io/kubectl/pkg/util/openapi"
-	"k8s.io/kubectl/pkg/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -44,7 +39,17 @@ type LockedResourceManager struct {
 	parent              client.Object
 	statusChange        chan<- event.GenericEvent
 	clusterWatchers     bool
+	opts                []Option
 	log                 logr.Logger
+	// ctx and cancel control the lifecycle of the manager and of the reconcilers, startConfig and namespaces record how the manager was started
+	ctx         context.Context
+	cancel      context.CancelFunc
+	startConfig *rest.Config
+	namespaces  []string
+	started     bool
+	sharedCache *sharedCache
+	// lostResources are the resources claimed by other parents, keyed to the winning parent, they are not enforced but they can be referenced in DependsOn
+	lostResources map[string]string
 }
 
 // NewLockedResourceManager build a new LockedResourceManager
@@ -52,13 +57,16 @@ type LockedResourceManager struct {
 // options: the manager options
 // parent: an object to which send notification when a recocilianton cicle completes for one of the reconcilers
 // statusChange: a channel through which send the notifications
-func NewLockedResourceManager(config *rest.Config, options manager.Options, parent client.Object, statusChange chan<- event.GenericEvent, clusterWatchers bool) (LockedResourceManager, error) {
+// opts: optional behaviours passed down to the reconcilers
+func NewLockedResourceManager(config *rest.Config, options manager.Options, parent client.Object, statusChange chan<- event.GenericEvent, clusterWatchers bool, opts ...Option) (LockedResourceManager, error) {
 	lockedResourceManager := LockedResourceManager{
 		config:          config,
 		options:         options,
 		parent:          parent,
 		statusChange:    statusChange,
 		clusterWatchers: clusterWatchers,
+		opts:            opts,
+		sharedCache:     newOptions(opts...).sharedCache,
 		log:             ctrl.Log.WithName("locker-resource-manager").WithName(apis.GetKeyShort(parent)),
 	}
 	return lockedResourceManager, nil
@@ -76,7 +84,7 @@ func (lrm *LockedResourceManager) GetPatches() []lockedpatch.LockedPatch {
 
 // SetResources set the resources to be enforced. Can be called only when the LockedResourceManager is stopped.
 func (lrm *LockedResourceManager) SetResources(resources []lockedresource.LockedResource) error {
-	if lrm.stoppableManager != nil && lrm.stoppableManager.IsStarted() {
+	if lrm.IsStarted() {
 		return errors.New("cannot set resources while enforcing is on")
 	}
 	err := lrm.validateLockedResources(resources)
@@ -84,16 +92,35 @@ func (lrm *LockedResourceManager) SetResources(resources []lockedresource.Locked
 		lrm.log.Error(err, "unable to validate resources against running api server")
 		return err
 	}
+	_, err = getDependencies(resources, newOptions(lrm.opts...).kindOrdering, getKeySet(lrm.lostResources))
+	if err != nil {
+		lrm.log.Error(err, "unable to validate resource dependencies")
+		return err
+	}
 	lrm.resources = resources
 	return nil
 }
 
 // SetPatches set the patches to be enforced. Can be called only when the LockedResourceManager is stopped.
 func (lrm *LockedResourceManager) SetPatches(patches []lockedpatch.LockedPatch) error {
-	if lrm.stoppableManager != nil && lrm.stoppableManager.IsStarted() {
+	if lrm.IsStarted() {
 		return errors.New("cannot set resources while enforcing is on")
 	}
-	// verifyPatchID Uniqueness
+	err := verifyPatchIDs(patches)
+	if err != nil {
+		return err
+	}
+	err = lrm.validateLockedPatches(patches)
+	if err != nil {
+		lrm.log.Error(err, "unable to validate patches against running api server")
+		return err
+	}
+	lrm.patches = patches
+	return nil
+}
+
+// verifyPatchIDs verifies that patch IDs are initialized and unique
+func verifyPatchIDs(patches []lockedpatch.LockedPatch) error {
 	lockedPatchMap := map[string]lockedpatch.LockedPatch{}
 	for _, lockedPatch := range patches {
 		if lockedPatch.Name == "" {
@@ -104,76 +131,148 @@ func (lrm *LockedResourceManager) SetPatches(patches []lockedpatch.LockedPatch)
 		}
 		lockedPatchMap[lockedPatch.Name] = lockedPatch
 	}
-	err := lrm.validateLockedPatches(patches)
-	if err != nil {
-		lrm.log.Error(err, "unable to validate patches against running api server")
-		return err
-	}
-	lrm.patches = patches
 	return nil
 }
 
 // IsStarted returns whether the LockedResourceManager is started
 func (lrm *LockedResourceManager) IsStarted() bool {
-	return lrm.stoppableManager != nil && lrm.stoppableManager.IsStarted()
+	return lrm.started
 }
 
 // Start starts the LockedResourceManager
+// When a shared cache is used, config must be the rest config the shared cache was created with.
 func (lrm *LockedResourceManager) Start(ctx context.Context, config *rest.Config) error {
-	if lrm.stoppableManager != nil && lrm.stoppableManager.IsStarted() {
+	if lrm.IsStarted() {
 		return nil
 	}
 
-	//diabling metrics
-	options := lrm.options
-	options.MetricsBindAddress = "0"
-	options.LeaderElection = false
+	if lrm.sharedCache != nil {
+		if config != lrm.sharedCache.stoppableManager.GetConfig() {
+			return errors.New("the shared cache cannot be used with a different rest config")
+		}
+		lrm.stoppableManager = lrm.sharedCache.stoppableManager
+	} else {
+		//diabling metrics
+		options := lrm.options
+		options.MetricsBindAddress = "0"
+		options.LeaderElection = false
 
-	if !lrm.clusterWatchers {
-		namespaces := lrm.scanNamespaces()
-		lrm.log.V(1).Info("starting multicache with the following ", "namespaces", namespaces)
-		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
-	}
+		lrm.namespaces = nil
+		if !lrm.clusterWatchers {
+			lrm.namespaces = scanNamespaces(lrm.GetResources(), lrm.GetPatches())
+			lrm.log.V(1).Info("starting multicache with the following ", "namespaces", lrm.namespaces)
+			options.NewCache = cache.MultiNamespacedCacheBuilder(lrm.namespaces)
+		}
 
-	stoppableManager, err := stoppablemanager.NewStoppableManager(config, options)
-	lrm.stoppableManager = &stoppableManager
+		stoppableManager, err := stoppablemanager.NewStoppableManager(config, options)
+		lrm.stoppableManager = &stoppableManager
 
-	if err != nil {
-		lrm.log.Error(err, "unable to create stoppable manager")
-		return err
+		if err != nil {
+			lrm.log.Error(err, "unable to create stoppable manager")
+			return err
+		}
 	}
 
 	resourceReconcilers := []*LockedResourceReconciler{}
 	for _, resource := range lrm.resources {
-		reconciler, err := NewLockedObjectReconciler(lrm.stoppableManager.Manager, resource.Unstructured, resource.ExcludedPaths, lrm.statusChange, lrm.parent)
+		if resource.GetMode() == utilsapi.EnforcementModeDisabled {
+			continue
+		}
+		reconciler, err := lrm.newResourceReconciler(resource)
 		if err != nil {
-			lrm.log.Error(err, "unable to create reconciler", "for locked resource", resource)
 			return err
 		}
 		resourceReconcilers = append(resourceReconcilers, reconciler)
 	}
 	lrm.resourceReconcilers = resourceReconcilers
+	err := setReconcilerDependencies(lrm.resources, lrm.resourceReconcilers, newOptions(lrm.opts...).kindOrdering, lrm.lostResources)
+	if err != nil {
+		lrm.log.Error(err, "unable to set resource dependencies")
+		return err
+	}
 
 	patchReconcilers := []*LockedPatchReconciler{}
 	for _, patch := range lrm.patches {
-		reconciler, err := NewLockedPatchReconciler(lrm.stoppableManager.Manager, patch, lrm.statusChange, lrm.parent)
+		if patch.GetMode() == utilsapi.EnforcementModeDisabled {
+			continue
+		}
+		reconciler, err := lrm.newPatchReconciler(patch)
 		if err != nil {
-			lrm.log.Error(err, "unable to create reconciler", "for locked patch", patch)
 			return err
 		}
 		patchReconcilers = append(patchReconcilers, reconciler)
 	}
 	lrm.patchReconcilers = patchReconcilers
 
-	lrm.stoppableManager.Start(ctx)
+	lrm.ctx, lrm.cancel = context.WithCancel(ctx)
+	lrm.startConfig = config
+	if lrm.sharedCache != nil {
+		lrm.sharedCache.start(ctx)
+	} else {
+		lrm.stoppableManager.Start(lrm.ctx)
+	}
+	for _, reconciler := range lrm.resourceReconcilers {
+		reconciler.start(lrm.ctx)
+	}
+	for _, reconciler := range lrm.patchReconcilers {
+		reconciler.start(lrm.ctx)
+	}
+	lrm.started = true
+	activeLockedResourceManagers.Inc()
+	lrm.updateReconcilerMetrics()
+	globalLockIndex.set(lrm)
 	return nil
 }
 
+func (lrm *LockedResourceManager) updateReconcilerMetrics() {
+	activeResourceReconcilers.WithLabelValues(lrm.getOwnerKey()).Set(float64(len(lrm.resourceReconcilers)))
+	activePatchReconcilers.WithLabelValues(lrm.getOwnerKey()).Set(float64(len(lrm.patchReconcilers)))
+}
+
+func (lrm *LockedResourceManager) newResourceReconciler(resource lockedresource.LockedResource) (*LockedResourceReconciler, error) {
+	object := lrm.getStampedResource(resource)
+	reconciler, err := NewLockedObjectReconciler(lrm.stoppableManager.Manager, object, resource.ExcludedPaths, lrm.statusChange, lrm.parent, lrm.opts...)
+	if err != nil {
+		lrm.log.Error(err, "unable to create reconciler", "for locked resource", resource)
+		return nil, err
+	}
+	reconciler.Mode = resource.GetMode()
+	reconciler.lockedResource = resource
+	return reconciler, nil
+}
+
+func (lrm *LockedResourceManager) newPatchReconciler(patch lockedpatch.LockedPatch) (*LockedPatchReconciler, error) {
+	reconciler, err := NewLockedPatchReconciler(lrm.stoppableManager.Manager, patch, lrm.statusChange, lrm.parent, lrm.opts...)
+	if err != nil {
+		lrm.log.Error(err, "unable to create reconciler", "for locked patch", patch)
+		return nil, err
+	}
+	return reconciler, nil
+}
+
 // Stop stops the LockedResourceManager.
-// deleteResource controls whether the managed resources should be deleted or left in place
+// deleteResource controls whether the managed resources should be deleted or left in place, resources are deleted according to their deletion policies
 // notice that lrm will always succeed at stopping the manager, but it might fail at deleting resources
+// a shared cache is never stopped, only the reconcilers of this LockedResourceManager are.
 func (lrm *LockedResourceManager) Stop(deleteResources bool) error {
-	lrm.stoppableManager.Stop()
+	globalLockIndex.remove(lrm)
+	for _, reconciler := range lrm.resourceReconcilers {
+		reconciler.stop()
+	}
+	for _, reconciler := range lrm.patchReconcilers {
+		reconciler.stop()
+	}
+	if lrm.sharedCache == nil {
+		lrm.stoppableManager.Stop()
+	}
+	if lrm.cancel != nil {
+		lrm.cancel()
+	}
+	if lrm.started {
+		activeLockedResourceManagers.Dec()
+		deleteParentMetrics(lrm.getOwnerKey())
+	}
+	lrm.started = false
 	if deleteResources {
 		err := lrm.deleteResources(context.TODO())
 		if err != nil {
@@ -184,14 +283,14 @@ func (lrm *LockedResourceManager) Stop(deleteResources bool) error {
 	return nil
 }
 
-func (lrm *LockedResourceManager) scanNamespaces() []string {
+func scanNamespaces(resources []lockedresource.LockedResource, patches []lockedpatch.LockedPatch) []string {
 	namespaceSet := strset.New()
-	for _, resource := range lrm.GetResources() {
+	for _, resource := range resources {
 		if resource.GetNamespace() != "" {
 			namespaceSet.Add(resource.GetNamespace())
 		}
 	}
-	for _, patch := range lrm.GetPatches() {
+	for _, patch := range patches {
 		if patch.TargetObjectRef.Namespace != "" {
 			namespaceSet.Add(patch.TargetObjectRef.Namespace)
 		}
@@ -202,7 +301,7 @@ func (lrm *LockedResourceManager) scanNamespaces() []string {
 		}
 	}
 	//in case no namesopace is added it means that all of the objects are cluster scoped, then we need to add an emptu string to activate the cache.
-	if len(lrm.GetResources())+len(lrm.GetPatches()) > 0 && len(namespaceSet.List()) == 0 {
+	if len(resources)+len(patches) > 0 && len(namespaceSet.List()) == 0 {
 		return []string{""}
 	}
 	return namespaceSet.List()
@@ -210,6 +309,7 @@ func (lrm *LockedResourceManager) scanNamespaces() []string {
 
 // Restart restarts the manager with a different set of resources
 // if deleteResources is set, resources that were enforced are deleted.
+// Patches that are no longer enforced are reverted according to their removal policy.
 func (lrm *LockedResourceManager) Restart(ctx context.Context, resources []lockedresource.LockedResource,
 	patches []lockedpatch.LockedPatch, deleteResources bool, config *rest.Config) error {
 	if lrm.IsStarted() {
@@ -219,7 +319,13 @@ func (lrm *LockedResourceManager) Restart(ctx context.Context, resources []locke
 			return err
 		}
 	}
-	err := lrm.SetResources(resources)
+	_, removedPatches, _, _ := lrm.IsSamePatches(patches)
+	err := lrm.RevertPatches(ctx, removedPatches)
+	if err != nil {
+		lrm.log.Error(err, "unable to revert", "patches", removedPatches)
+		return err
+	}
+	err = lrm.SetResources(resources)
 	if err != nil {
 		lrm.log.Error(err, "unable to set", "resources", resources)
 		return err
@@ -232,6 +338,142 @@ func (lrm *LockedResourceManager) Restart(ctx context.Context, resources []locke
 	return lrm.Start(ctx, config)
 }
 
+// Update changes the set of enforced resources and patches without restarting the LockedResourceManager.
+// Only the reconcilers of the resources and patches that have been removed, added or modified are stopped or started, the manager and its cache are reused.
+// A full restart is performed instead when the LockedResourceManager is not started, when the rest config changes or, with namespace level watchers and no shared cache, when the set of watched namespaces changes.
+// Resources that are no longer enforced are not deleted, patches that are no longer enforced are reverted according to their removal policy.
+func (lrm *LockedResourceManager) Update(ctx context.Context, resources []lockedresource.LockedResource,
+	patches []lockedpatch.LockedPatch, config *rest.Config) error {
+	if !lrm.IsStarted() || config != lrm.startConfig ||
+		(!lrm.clusterWatchers && lrm.sharedCache == nil && !strset.New(scanNamespaces(resources, patches)...).IsEqual(strset.New(lrm.namespaces...))) {
+		return lrm.Restart(ctx, resources, patches, false, config)
+	}
+	_, leftResources, _, rightResources := lrm.IsSameResources(resources)
+	_, leftPatches, intersectionPatches, rightPatches := lrm.IsSamePatches(patches)
+	changedPatches, err := lrm.getChangedPatches(intersectionPatches)
+	if err != nil {
+		lrm.log.Error(err, "unable to compare", "patches", intersectionPatches)
+		return err
+	}
+	err = verifyPatchIDs(patches)
+	if err != nil {
+		return err
+	}
+	_, err = getDependencies(resources, newOptions(lrm.opts...).kindOrdering, getKeySet(lrm.lostResources))
+	if err != nil {
+		lrm.log.Error(err, "unable to validate resource dependencies")
+		return err
+	}
+	if len(rightResources) > 0 {
+		err = lrm.validateLockedResources(rightResources)
+		if err != nil {
+			lrm.log.Error(err, "unable to validate resources against running api server")
+			return err
+		}
+	}
+	newPatches := append(rightPatches, changedPatches...)
+	if len(newPatches) > 0 {
+		err = lrm.validateLockedPatches(newPatches)
+		if err != nil {
+			lrm.log.Error(err, "unable to validate patches against running api server")
+			return err
+		}
+	}
+
+	// create the new reconcilers first, so that the current reconcilers are left untouched in case of error
+	newResourceReconcilers := []*LockedResourceReconciler{}
+	for _, resource := range rightResources {
+		if resource.GetMode() == utilsapi.EnforcementModeDisabled {
+			continue
+		}
+		reconciler, err := lrm.newResourceReconciler(resource)
+		if err != nil {
+			return err
+		}
+		newResourceReconcilers = append(newResourceReconcilers, reconciler)
+	}
+	newPatchReconcilers := []*LockedPatchReconciler{}
+	for _, patch := range newPatches {
+		if patch.GetMode() == utilsapi.EnforcementModeDisabled {
+			continue
+		}
+		reconciler, err := lrm.newPatchReconciler(patch)
+		if err != nil {
+			return err
+		}
+		newPatchReconcilers = append(newPatchReconcilers, reconciler)
+	}
+
+	removedResourceSet := lockedresourceset.New(leftResources...)
+	resourceReconcilers := []*LockedResourceReconciler{}
+	for _, reconciler := range lrm.resourceReconcilers {
+		if removedResourceSet.Has(reconciler.lockedResource) {
+			reconciler.stop()
+			continue
+		}
+		resourceReconcilers = append(resourceReconcilers, reconciler)
+	}
+	removedPatchSet := strset.New()
+	for _, patch := range append(leftPatches, changedPatches...) {
+		removedPatchSet.Add(patch.GetKey())
+	}
+	patchReconcilers := []*LockedPatchReconciler{}
+	for _, reconciler := range lrm.patchReconcilers {
+		if removedPatchSet.Has(reconciler.GetKey()) {
+			reconciler.stop()
+			continue
+		}
+		patchReconcilers = append(patchReconcilers, reconciler)
+	}
+
+	resourceReconcilers = append(resourceReconcilers, newResourceReconcilers...)
+	// dependencies may have changed for the existing reconcilers too, and must be set before the new ones start
+	err = setReconcilerDependencies(resources, resourceReconcilers, newOptions(lrm.opts...).kindOrdering, lrm.lostResources)
+	if err != nil {
+		lrm.log.Error(err, "unable to set resource dependencies")
+		return err
+	}
+	for _, reconciler := range newResourceReconcilers {
+		reconciler.start(lrm.ctx)
+	}
+	for _, reconciler := range newPatchReconcilers {
+		reconciler.start(lrm.ctx)
+	}
+	lrm.resourceReconcilers = resourceReconcilers
+	lrm.patchReconcilers = append(patchReconcilers, newPatchReconcilers...)
+	lrm.resources = resources
+	lrm.patches = patches
+	lrm.updateReconcilerMetrics()
+	globalLockIndex.set(lrm)
+	err = lrm.RevertPatches(ctx, leftPatches)
+	if err != nil {
+		lrm.log.Error(err, "unable to revert", "patches", leftPatches)
+		return err
+	}
+	lrm.log.V(1).Info("updated", "removed resources", len(leftResources), "added resources", len(rightResources), "removed patches", len(leftPatches), "added patches", len(rightPatches), "changed patches", len(changedPatches))
+	return nil
+}
+
+// getChangedPatches returns the patches, among the passed ones, whose definition differs from the currently enforced patch with the same id
+func (lrm *LockedResourceManager) getChangedPatches(patches []lockedpatch.LockedPatch) ([]lockedpatch.LockedPatch, error) {
+	currentPatchMap, _ := lockedpatch.GetLockedPatchMap(lrm.GetPatches())
+	changedPatches := []lockedpatch.LockedPatch{}
+	for _, patch := range patches {
+		currentPatch, err := json.Marshal(currentPatchMap[patch.GetKey()])
+		if err != nil {
+			return nil, err
+		}
+		newPatch, err := json.Marshal(patch)
+		if err != nil {
+			return nil, err
+		}
+		if string(currentPatch) != string(newPatch) {
+			changedPatches = append(changedPatches, patch)
+		}
+	}
+	return changedPatches, nil
+}
+
 // IsSameResources checks whether the currently enforced resources are the same as the ones passed as parameters
 // same is true is current resources are the same as the resources passed as a parameter
 // leftDifference contains the resources that are in the current resources but not in passed in the parameter
@@ -283,19 +525,25 @@ func (lrm *LockedResourceManager) IsSamePatches(patches []lockedpatch.LockedPatc
 
 func (lrm *LockedResourceManager) deleteResources(context context.Context) error {
 	reconcilerBase := util.NewFromManager(lrm.stoppableManager.Manager, lrm.stoppableManager.GetEventRecorderFor("resource-deleter"))
-	for _, resource := range lrm.GetResources() {
+	resources := lrm.GetResources()
+	for _, resource := range resources {
 		gvk := resource.Unstructured.GetObjectKind().GroupVersionKind()
 		groupVersion := schema.GroupVersion{Group: gvk.Group, Version: gvk.Version}
 		lrm.stoppableManager.GetScheme().AddKnownTypes(groupVersion, &resource.Unstructured)
-		err := reconcilerBase.DeleteResourceIfExists(context, &resource.Unstructured)
-		if err != nil {
-			lrm.log.Error(err, "unable to delete", "resource", resource.Unstructured)
-			return err
-		}
+	}
+	err := deleteResources(log.IntoContext(context, lrm.log), reconcilerBase.GetClient(), resources, newOptions(lrm.opts...).kindOrdering, lrm.getOwnerKey())
+	if err != nil {
+		lrm.log.Error(err, "unable to delete", "resources", resources)
+		return err
 	}
 	return nil
 }
 
+// setLostResources records the resources claimed by other parents, keyed to the winning parent, before the enforced resources are set or updated
+func (lrm *LockedResourceManager) setLostResources(lostResources map[string]string) {
+	lrm.lostResources = lostResources
+}
+
 // GetResourceReconcilers return the currently active resource reconcilers
 func (lrm *LockedResourceManager) GetResourceReconcilers() []*LockedResourceReconciler {
 	if lrm.IsStarted() {
@@ -305,63 +553,7 @@ func (lrm *LockedResourceManager) GetResourceReconcilers() []*LockedResourceReco
 }
 
 func (lrm *LockedResourceManager) validateLockedResources(lockedResources []lockedresource.LockedResource) error {
-	ctx := context.TODO()
-	ctx = context.WithValue(ctx, "restConfig", lrm.config)
-	ctx = log.IntoContext(ctx, lrm.log)
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(lrm.config)
-	if err != nil {
-		lrm.log.Error(err, "unable to create discovery client")
-		return err
-	}
-	//lockedResourceAPIResource.
-	// validate the unstructured object is conformant to the openapi
-	doc, err := discoveryClient.OpenAPISchema()
-	if err != nil {
-		lrm.log.Error(err, "unable to get openapi schema")
-		return err
-	}
-	resources, err := openapi.NewOpenAPIData(doc)
-	if err != nil {
-		lrm.log.Error(err, "unable to get resources from openapi doc")
-		return err
-	}
-	schemaValidation := validation.NewSchemaValidation(resources)
-	result := &multierror.Error{}
-	for _, lockedResource := range lockedResources {
-		defined, err := discoveryclient.IsUnstructuredDefined(ctx, &lockedResource.Unstructured)
-		if err != nil {
-			lrm.log.Error(err, "unable to validate", "unstructured", lockedResource.Unstructured)
-			result = multierror.Append(result, err)
-			continue
-		}
-		if !defined {
-			result = multierror.Append(result, errors.New("resource type:"+lockedResource.Unstructured.GroupVersionKind().String()+"not defined"))
-			continue
-		}
-		err = templates.ValidateUnstructured(ctx, &lockedResource.Unstructured, schemaValidation)
-		if err != nil {
-			lrm.log.Error(err, "unable to validate", "unstructured", lockedResource.Unstructured)
-			result = multierror.Append(result, err)
-			continue
-		}
-		namespaced, err := discoveryclient.IsUnstructuredNamespaced(ctx, &lockedResource.Unstructured)
-		if err != nil {
-			lrm.log.Error(err, "unable to determine if namespaced", "unstructured", lockedResource.Unstructured)
-			result = multierror.Append(result, err)
-			continue
-		}
-		if namespaced && lockedResource.Unstructured.GetNamespace() == "" {
-			err := errors.New("namespaced resources must specify a namespace")
-			lrm.log.Error(err, "unable to validate", "unstructured", lockedResource.Unstructured)
-			result = multierror.Append(result, err)
-			continue
-		}
-	}
-	if result.ErrorOrNil() != nil {
-		lrm.log.Error(result, "encountered errors during resources validation")
-		return result
-	}
-	return nil
+	return ValidateLockedResources(log.IntoContext(context.TODO(), lrm.log), lrm.config, lockedResources)
 }
 
 // GetPatchReconcilers return the currently active patch reconcilers
@@ -373,38 +565,5 @@ func (lrm *LockedResourceManager) GetPatchReconcilers() []*LockedPatchReconciler
 }
 
 func (lrm *LockedResourceManager) validateLockedPatches(patches []lockedpatch.LockedPatch) error {
-	ctx := context.TODO()
-	ctx = context.WithValue(ctx, "restConfig", lrm.config)
-	ctx = log.IntoContext(ctx, lrm.log)
-	result := &multierror.Error{}
-	for _, lockedPatch := range patches {
-		GVKs := []schema.GroupVersionKind{}
-		for i := range lockedPatch.SourceObjectRefs {
-			GVKs = append(GVKs, schema.FromAPIVersionAndKind(lockedPatch.SourceObjectRefs[i].APIVersion, lockedPatch.SourceObjectRefs[i].Kind))
-		}
-		GVKs = append(GVKs, schema.FromAPIVersionAndKind(lockedPatch.TargetObjectRef.APIVersion, lockedPatch.TargetObjectRef.Kind))
-		for i := range GVKs {
-			defined, err := discoveryclient.IsGVKDefined(ctx, GVKs[i])
-			if err != nil {
-				lrm.log.Error(err, "undefined resource in this cluster", "gvk", GVKs[i])
-				result = multierror.Append(result, err)
-				continue
-			}
-			if !defined {
-				result = multierror.Append(result, errors.New("resource type:"+GVKs[i].String()+"not defined"))
-				continue
-			}
-			// if resource.Namespaced && objref.Namespace == "" {
-			// 	err := errors.New("namespace must be specified for namespaced resources")
-			// 	lrm.log.Error(err, "unable to validate", "objectref", objref)
-			// 	result = multierror.Append(result, err)
-			// 	continue
-			// }
-		}
-	}
-	if result.ErrorOrNil() != nil {
-		lrm.log.Error(result, "encountered errors during patch validation")
-		return result
-	}
-	return nil
+	return ValidateLockedPatches(log.IntoContext(context.TODO(), lrm.log), lrm.config, patches)
 }
@@ -22,6 +22,35 @@ type LockedPatch struct {
 	PatchType        types.PatchType                  `json:"patchType,omitempty"`
 	PatchTemplate    string                           `json:"patchTemplate,omitempty"`
 	Template         template.Template                `json:"-"`
+	Mode             utilsapi.EnforcementMode         `json:"mode,omitempty"`
+	// SourceDeletionPolicy determines what is done to the targets when a source object is deleted
+	SourceDeletionPolicy utilsapi.SourceDeletionPolicy `json:"sourceDeletionPolicy,omitempty"`
+	// RemovalPolicy determines what is done to the targets when the patch is no longer enforced
+	RemovalPolicy utilsapi.PatchRemovalPolicy `json:"removalPolicy,omitempty"`
+}
+
+// GetMode returns the enforcement mode of this patch, defaulting to Enforce
+func (lp *LockedPatch) GetMode() utilsapi.EnforcementMode {
+	if lp.Mode == "" {
+		return utilsapi.EnforcementModeEnforce
+	}
+	return lp.Mode
+}
+
+// GetSourceDeletionPolicy returns the source deletion policy of this patch, defaulting to Keep
+func (lp *LockedPatch) GetSourceDeletionPolicy() utilsapi.SourceDeletionPolicy {
+	if lp.SourceDeletionPolicy == "" {
+		return utilsapi.SourceDeletionPolicyKeep
+	}
+	return lp.SourceDeletionPolicy
+}
+
+// GetRemovalPolicy returns the removal policy of this patch, defaulting to Keep
+func (lp *LockedPatch) GetRemovalPolicy() utilsapi.PatchRemovalPolicy {
+	if lp.RemovalPolicy == "" {
+		return utilsapi.PatchRemovalPolicyKeep
+	}
+	return lp.RemovalPolicy
 }
 
 // GetKey returns a not so unique key for a patch
@@ -50,20 +79,28 @@ func GetLockedPatchesFromLockedPatcheSet(lockedPatchSet *strset.Set, lockedPatch
 
 // GetLockedPatches returns a slice of LockedPatches from a slice of apis.Patches
 func GetLockedPatches(patches map[string]utilsapi.PatchSpec, config *rest.Config, logger logr.Logger) ([]LockedPatch, error) {
+	return GetLockedPatchesWithLibrary(patches, config, nil, logger)
+}
+
+// GetLockedPatchesWithLibrary works as GetLockedPatches, the patch templates can also invoke the named templates of library with include and template. library can be nil.
+func GetLockedPatchesWithLibrary(patches map[string]utilsapi.PatchSpec, config *rest.Config, library *utilstemplate.Library, logger logr.Logger) ([]LockedPatch, error) {
 	lockedPatches := []LockedPatch{}
 	for key, patch := range patches {
-		template, err := template.New(patch.PatchTemplate).Funcs(utilstemplate.AdvancedTemplateFuncMap(config, logger)).Parse(patch.PatchTemplate)
+		template, err := utilstemplate.NewTemplate(key, patch.PatchTemplate, config, logger, library)
 		if err != nil {
 			log.Error(err, "unable to parse ", "template", patch.PatchTemplate)
 			return []LockedPatch{}, err
 		}
 		lockedPatches = append(lockedPatches, LockedPatch{
-			SourceObjectRefs: patch.SourceObjectRefs,
-			PatchTemplate:    patch.PatchTemplate,
-			PatchType:        patch.PatchType,
-			TargetObjectRef:  patch.TargetObjectRef,
-			Template:         *template,
-			Name:             key,
+			SourceObjectRefs:     patch.SourceObjectRefs,
+			PatchTemplate:        patch.PatchTemplate,
+			PatchType:            patch.PatchType,
+			TargetObjectRef:      patch.TargetObjectRef,
+			Template:             *template,
+			Name:                 key,
+			Mode:                 patch.Mode,
+			SourceDeletionPolicy: patch.SourceDeletionPolicy,
+			RemovalPolicy:        patch.RemovalPolicy,
 		})
 	}
 	return lockedPatches, nil
@@ -0,0 +1,353 @@
+package lockedresource
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/third_party/forked/golang/template"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// match is a value found while evaluating a JSONPath expression, along with the JSON Pointer segments that locate it in the document.
+// Literals appearing in filter expressions have no location, their path is nil.
+type match struct {
+	value interface{}
+	path  []string
+}
+
+// ValidateJSONPaths verifies that the passed expressions are valid JSONPath expressions that can be used as excluded paths.
+func ValidateJSONPaths(jsonPaths []string) error {
+	for _, jsonPath := range jsonPaths {
+		_, err := parseJSONPath(jsonPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findPaths evaluates a JSONPath expression, such as .spec.containers[?(@.name=="sidecar")].image, against a document and returns the location of all the matched values.
+// Expressions use the kubectl JSONPath syntax, with or without the enclosing braces and the leading $.
+func findPaths(doc map[string]interface{}, jsonPath string) ([][]string, error) {
+	node, err := parseJSONPath(jsonPath)
+	if err != nil {
+		return [][]string{}, err
+	}
+	matches, err := evalList([]match{{value: doc, path: []string{}}}, node)
+	if err != nil {
+		return [][]string{}, err
+	}
+	paths := [][]string{}
+	for _, m := range matches {
+		// the document root cannot be removed
+		if len(m.path) > 0 {
+			paths = append(paths, m.path)
+		}
+	}
+	return paths, nil
+}
+
+func parseJSONPath(jsonPath string) (*jsonpath.ListNode, error) {
+	expression := strings.TrimSpace(jsonPath)
+	if !strings.HasPrefix(expression, "{") {
+		expression = "{" + expression + "}"
+	}
+	parser, err := jsonpath.Parse(jsonPath, expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonpath %q: %w", jsonPath, err)
+	}
+	if len(parser.Root.Nodes) != 1 || parser.Root.Nodes[0].Type() != jsonpath.NodeList {
+		return nil, fmt.Errorf("invalid jsonpath %q: must be a single expression", jsonPath)
+	}
+	node := parser.Root.Nodes[0].(*jsonpath.ListNode)
+	err = validateNode(node)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonpath %q: %w", jsonPath, err)
+	}
+	return node, nil
+}
+
+func validateNode(node jsonpath.Node) error {
+	switch typed := node.(type) {
+	case *jsonpath.ListNode:
+		for _, child := range typed.Nodes {
+			err := validateNode(child)
+			if err != nil {
+				return err
+			}
+		}
+	case *jsonpath.UnionNode:
+		for _, child := range typed.Nodes {
+			err := validateNode(child)
+			if err != nil {
+				return err
+			}
+		}
+	case *jsonpath.FilterNode:
+		err := validateNode(typed.Left)
+		if err != nil {
+			return err
+		}
+		return validateNode(typed.Right)
+	case *jsonpath.IdentifierNode:
+		return errors.New("range and end are not supported")
+	case *jsonpath.TextNode:
+		// a text node is a string literal in a filter
+	case *jsonpath.FieldNode, *jsonpath.ArrayNode, *jsonpath.WildcardNode, *jsonpath.RecursiveNode, *jsonpath.IntNode, *jsonpath.FloatNode, *jsonpath.BoolNode:
+	default:
+		return fmt.Errorf("unsupported expression %s", node)
+	}
+	return nil
+}
+
+func evalList(input []match, node *jsonpath.ListNode) ([]match, error) {
+	var err error
+	current := input
+	for _, child := range node.Nodes {
+		current, err = evalNode(current, child)
+		if err != nil {
+			return []match{}, err
+		}
+	}
+	return current, nil
+}
+
+func evalNode(input []match, node jsonpath.Node) ([]match, error) {
+	switch typed := node.(type) {
+	case *jsonpath.ListNode:
+		return evalList(input, typed)
+	case *jsonpath.TextNode:
+		return []match{{value: string(typed.Text)}}, nil
+	case *jsonpath.IntNode:
+		return []match{{value: typed.Value}}, nil
+	case *jsonpath.FloatNode:
+		return []match{{value: typed.Value}}, nil
+	case *jsonpath.BoolNode:
+		return []match{{value: typed.Value}}, nil
+	case *jsonpath.FieldNode:
+		return evalField(input, typed), nil
+	case *jsonpath.ArrayNode:
+		return evalArray(input, typed)
+	case *jsonpath.FilterNode:
+		return evalFilter(input, typed)
+	case *jsonpath.WildcardNode:
+		return evalWildcard(input), nil
+	case *jsonpath.RecursiveNode:
+		return evalRecursive(input), nil
+	case *jsonpath.UnionNode:
+		result := []match{}
+		for _, child := range typed.Nodes {
+			matches, err := evalList(input, child)
+			if err != nil {
+				return []match{}, err
+			}
+			result = append(result, matches...)
+		}
+		return result, nil
+	default:
+		return []match{}, fmt.Errorf("unsupported expression %s", node)
+	}
+}
+
+func evalField(input []match, node *jsonpath.FieldNode) []match {
+	// an empty field is the current object
+	if node.Value == "" {
+		return input
+	}
+	result := []match{}
+	for _, m := range input {
+		object, ok := m.value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, found := object[node.Value]
+		if !found {
+			continue
+		}
+		result = append(result, match{value: value, path: appendPath(m.path, node.Value)})
+	}
+	return result
+}
+
+// evalArray selects the elements of the arrays in input. Indexes out of the bounds of an array select nothing, because there is nothing to exclude there.
+func evalArray(input []match, node *jsonpath.ArrayNode) ([]match, error) {
+	step := 1
+	if node.Params[2].Known {
+		if node.Params[2].Value <= 0 {
+			return []match{}, errors.New("step must be > 0")
+		}
+		step = node.Params[2].Value
+	}
+	result := []match{}
+	for _, m := range input {
+		array, ok := m.value.([]interface{})
+		if !ok {
+			continue
+		}
+		start := 0
+		if node.Params[0].Known {
+			start = node.Params[0].Value
+		}
+		if start < 0 {
+			start += len(array)
+		}
+		end := len(array)
+		if node.Params[1].Known {
+			end = node.Params[1].Value
+		}
+		if end < 0 || (end == 0 && node.Params[1].Derived) {
+			end += len(array)
+		}
+		if start < 0 {
+			start = 0
+		}
+		if end > len(array) {
+			end = len(array)
+		}
+		for i := start; i < end; i += step {
+			result = append(result, match{value: array[i], path: appendPath(m.path, strconv.Itoa(i))})
+		}
+	}
+	return result, nil
+}
+
+// evalFilter selects the elements of the arrays in input for which the filter holds. Elements whose values cannot be compared with the filter operands are not selected.
+func evalFilter(input []match, node *jsonpath.FilterNode) ([]match, error) {
+	result := []match{}
+	for _, m := range input {
+		array, ok := m.value.([]interface{})
+		if !ok {
+			continue
+		}
+		for i := range array {
+			element := []match{{value: array[i], path: appendPath(m.path, strconv.Itoa(i))}}
+			lefts, err := evalList(element, node.Left)
+			if err != nil {
+				return []match{}, err
+			}
+			if node.Operator == "exists" {
+				if len(lefts) > 0 {
+					result = append(result, element...)
+				}
+				continue
+			}
+			rights, err := evalList(element, node.Right)
+			if err != nil {
+				return []match{}, err
+			}
+			if len(lefts) != 1 || len(rights) != 1 {
+				continue
+			}
+			pass, err := compare(lefts[0].value, rights[0].value, node.Operator)
+			if err != nil {
+				continue
+			}
+			if pass {
+				result = append(result, element...)
+			}
+		}
+	}
+	return result, nil
+}
+
+func compare(left interface{}, right interface{}, operator string) (bool, error) {
+	switch operator {
+	case "<":
+		return template.Less(left, right)
+	case ">":
+		return template.Greater(left, right)
+	case "==":
+		return template.Equal(left, right)
+	case "!=":
+		return template.NotEqual(left, right)
+	case "<=":
+		return template.LessEqual(left, right)
+	case ">=":
+		return template.GreaterEqual(left, right)
+	default:
+		return false, fmt.Errorf("unrecognized filter operator %s", operator)
+	}
+}
+
+func evalWildcard(input []match) []match {
+	result := []match{}
+	for _, m := range input {
+		switch typed := m.value.(type) {
+		case map[string]interface{}:
+			for _, key := range sortedKeys(typed) {
+				result = append(result, match{value: typed[key], path: appendPath(m.path, key)})
+			}
+		case []interface{}:
+			for i := range typed {
+				result = append(result, match{value: typed[i], path: appendPath(m.path, strconv.Itoa(i))})
+			}
+		}
+	}
+	return result
+}
+
+// evalRecursive returns the objects and arrays in input along with all of their descendants
+func evalRecursive(input []match) []match {
+	result := []match{}
+	for _, m := range input {
+		switch m.value.(type) {
+		case map[string]interface{}, []interface{}:
+			result = append(result, m)
+			result = append(result, evalRecursive(evalWildcard([]match{m}))...)
+		}
+	}
+	return result
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func appendPath(path []string, segment string) []string {
+	result := make([]string, len(path), len(path)+1)
+	copy(result, path)
+	return append(result, segment)
+}
+
+// toJSONPointers deduplicates and escapes the paths, ordering them so that removing a pointer never shifts the array indexes of the following ones.
+func toJSONPointers(paths [][]string) []string {
+	sort.SliceStable(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) > len(paths[j])
+		}
+		for k := range paths[i] {
+			if paths[i][k] == paths[j][k] {
+				continue
+			}
+			left, lerr := strconv.Atoi(paths[i][k])
+			right, rerr := strconv.Atoi(paths[j][k])
+			if lerr == nil && rerr == nil {
+				return left > right
+			}
+			return paths[i][k] > paths[j][k]
+		}
+		return false
+	})
+	result := []string{}
+	seen := map[string]bool{}
+	for _, path := range paths {
+		segments := make([]string, len(path))
+		for i := range path {
+			segments[i] = strings.ReplaceAll(strings.ReplaceAll(path[i], "~", "~0"), "/", "~1")
+		}
+		pointer := "/" + strings.Join(segments, "/")
+		if !seen[pointer] {
+			seen[pointer] = true
+			result = append(result, pointer)
+		}
+	}
+	return result
+}
@@ -2,19 +2,28 @@ package lockedresource
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/go-logr/logr"
+	multierror "github.com/hashicorp/go-multierror"
 	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/dynamicclient"
 	utilstemplates "github.com/redhat-cop/operator-utils/pkg/util/templates"
 	"github.com/scylladb/go-set/strset"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
+	"k8s.io/kubectl/pkg/validation"
+	"k8s.io/utils/lru"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/yaml"
 )
 
 var innerlog = ctrl.Log.WithName("lockedresource")
@@ -25,6 +34,14 @@ type LockedResource struct {
 	unstructured.Unstructured `json:"usntructured,omitempty"`
 	// ExcludedPaths are the jsonPaths to be excluded when consider whether the resource has changed
 	ExcludedPaths []string `json:"excludedPaths,omitempty"`
+	// Mode determines whether drift is corrected, only reported or ignored. The empty value means Enforce.
+	Mode utilsapi.EnforcementMode `json:"mode,omitempty"`
+	// DependsOn are the locked resources that must be reconciled successfully before this one is enforced
+	DependsOn []utilsapi.LockedResourceReference `json:"dependsOn,omitempty"`
+	// DeletionPolicy determines what is done to the resource when it is no longer enforced. The empty value means Delete.
+	DeletionPolicy utilsapi.DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// PropagationPolicy determines how the dependents of the resource are deleted. The empty value means the default of the resource type.
+	PropagationPolicy utilsapi.PropagationPolicy `json:"propagationPolicy,omitempty"`
 }
 
 // AsListOfUnstructured given a list of LockedResource, returns a list of unstructured.Unstructured
@@ -36,40 +53,88 @@ func AsListOfUnstructured(lockedResources []LockedResource) []unstructured.Unstr
 	return unstructuredList
 }
 
-// GetKey returns the marshalled resource
+// GetKey returns the marshalled resource, followed by the mode and the deletion and propagation policies when they are not the default ones and by the dependencies, if any.
+// A change in the key restarts the reconciler of the resource, which stamps the new policies on the object.
 func (lr *LockedResource) GetKey() string {
 	bb, err := lr.Unstructured.MarshalJSON()
 	if err != nil {
 		innerlog.Error(err, "unable to marshall", "unstructured", lr.Unstructured)
 		panic(err)
 	}
-	return string(bb)
+	key := string(bb)
+	if lr.GetMode() != utilsapi.EnforcementModeEnforce {
+		key += "#" + string(lr.Mode)
+	}
+	if lr.GetDeletionPolicy() != utilsapi.DeletionPolicyDelete {
+		key += "#deletionPolicy=" + string(lr.DeletionPolicy)
+	}
+	if lr.PropagationPolicy != "" {
+		key += "#propagationPolicy=" + string(lr.PropagationPolicy)
+	}
+	if len(lr.DependsOn) > 0 {
+		references := []string{}
+		for _, reference := range lr.DependsOn {
+			references = append(references, reference.APIVersion+"/"+reference.Kind+"/"+reference.Namespace+"/"+reference.Name)
+		}
+		sort.Strings(references)
+		key += "#dependsOn=" + strings.Join(references, ",")
+	}
+	return key
 }
 
-// GetLockedResources turns an array of Resources as read from an API into an array of LockedResources, usable by the LockedResourceManager
+// GetMode returns the enforcement mode of this resource, defaulting to Enforce
+func (lr *LockedResource) GetMode() utilsapi.EnforcementMode {
+	if lr.Mode == "" {
+		return utilsapi.EnforcementModeEnforce
+	}
+	return lr.Mode
+}
+
+// GetDeletionPolicy returns the deletion policy of this resource, defaulting to Delete
+func (lr *LockedResource) GetDeletionPolicy() utilsapi.DeletionPolicy {
+	if lr.DeletionPolicy == "" {
+		return utilsapi.DeletionPolicyDelete
+	}
+	return lr.DeletionPolicy
+}
+
+// GetLockedResources turns an array of Resources as read from an API into an array of LockedResources, usable by the LockedResourceManager.
+// The object of a Resource can also be a stream of YAML documents separated by --- or a List, every object it contains becomes a LockedResource with the settings of the Resource, see templates.ParseManifests.
 func GetLockedResources(resources []utilsapi.LockedResource) ([]LockedResource, error) {
 	lockedResources := []LockedResource{}
 	for _, resource := range resources {
-		bb, err := yaml.YAMLToJSON(resource.Object.Raw)
+		objs, err := utilstemplates.ParseManifests(resource.Object.Raw)
 		if err != nil {
-			innerlog.Error(err, "Error transforming yaml to json", "raw", resource.Object.Raw)
+			innerlog.Error(err, "Error parsing manifests", "raw", string(resource.Object.Raw))
 			return []LockedResource{}, err
 		}
-		obj := &unstructured.Unstructured{}
-		err = json.Unmarshal(bb, obj)
-		if err != nil {
-			innerlog.Error(err, "Error unmarshalling json manifest", "manifest", string(bb))
-			return []LockedResource{}, err
+		for _, obj := range objs {
+			lockedResources = append(lockedResources, LockedResource{
+				Unstructured:      obj,
+				ExcludedPaths:     resource.ExcludedPaths,
+				Mode:              resource.Mode,
+				DependsOn:         resource.DependsOn,
+				DeletionPolicy:    resource.DeletionPolicy,
+				PropagationPolicy: resource.PropagationPolicy,
+			})
 		}
-		lockedResources = append(lockedResources, LockedResource{
-			Unstructured:  *obj,
-			ExcludedPaths: resource.ExcludedPaths,
-		})
 	}
 	return lockedResources, nil
 }
 
-var templates = map[string]*template.Template{}
+// templateKey identifies a parsed template, by its text and by the library it has been parsed with
+type templateKey struct {
+	text    string
+	library string
+}
+
+// maxCachedTemplates is the maximum number of parsed templates that are cached, beyond it the least recently used ones are dropped, such as the ones parsed with older versions of a library
+const maxCachedTemplates = 256
+
+var (
+	templates     = lru.New(maxCachedTemplates)
+	templatesLock sync.Mutex
+)
 
 // GetLockedResourcesFromTemplates Keep backwards compatability with existing consumers
 func GetLockedResourcesFromTemplates(resources []utilsapi.LockedResourceTemplate, params interface{}) ([]LockedResource, error) {
@@ -79,43 +144,179 @@ func GetLockedResourcesFromTemplates(resources []utilsapi.LockedResourceTemplate
 
 // GetLockedResourcesFromTemplatesWithRestConfig turns an array of ResourceTemplates as read from an API into an array of LockedResources using a params to process the templates
 func GetLockedResourcesFromTemplatesWithRestConfig(resources []utilsapi.LockedResourceTemplate, config *rest.Config, params interface{}) ([]LockedResource, error) {
+	return GetLockedResourcesFromTemplatesWithLookupRecorder(resources, config, params, nil)
+}
+
+// GetLockedResourcesFromTemplatesWithLookupRecorder works as GetLockedResourcesFromTemplatesWithRestConfig and records in recorder the objects looked up by the templates, a nil recorder records nothing.
+// The records can be passed to EnforcingReconciler.WatchLookups so that the templates are processed again when the looked up objects change.
+func GetLockedResourcesFromTemplatesWithLookupRecorder(resources []utilsapi.LockedResourceTemplate, config *rest.Config, params interface{}, recorder *utilstemplates.LookupRecorder) ([]LockedResource, error) {
+	return GetLockedResourcesFromTemplatesWithLibrary(resources, config, nil, params, recorder)
+}
+
+// GetLockedResourcesFromTemplatesWithLibrary works as GetLockedResourcesFromTemplatesWithLookupRecorder, the templates can also invoke the named templates of library with include and template. library can be nil.
+// The first template that cannot be processed stops the processing, its error locates the problem in the template or in the rendered YAML when possible, see templates.TemplateError.
+func GetLockedResourcesFromTemplatesWithLibrary(resources []utilsapi.LockedResourceTemplate, config *rest.Config, library *utilstemplates.Library, params interface{}, recorder *utilstemplates.LookupRecorder) ([]LockedResource, error) {
 	lockedResources := []LockedResource{}
+	renderer := newTemplateRenderer(config, library, recorder)
+	for i := range resources {
+		rendered, err := renderer.render(&resources[i], params)
+		if err != nil {
+			innerlog.Error(err, "unable to process template for", "resource", resources[i], "params", params)
+			return []LockedResource{}, fmt.Errorf("template %d: %w", i, err)
+		}
+		lockedResources = append(lockedResources, rendered...)
+	}
+	return lockedResources, nil
+}
+
+// RenderLockedResourceTemplates parses and processes the passed templates with params, as GetLockedResourcesFromTemplatesWithLibrary does, but it goes through all of them and returns the errors aggregated.
+// It is meant to dry-run the templates, for example at admission. library can be nil.
+func RenderLockedResourceTemplates(resources []utilsapi.LockedResourceTemplate, config *rest.Config, library *utilstemplates.Library, params interface{}) ([]LockedResource, error) {
+	lockedResources := []LockedResource{}
+	renderer := newTemplateRenderer(config, library, nil)
+	result := &multierror.Error{}
+	for i := range resources {
+		rendered, err := renderer.render(&resources[i], params)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("template %d: %w", i, err))
+			continue
+		}
+		lockedResources = append(lockedResources, rendered...)
+	}
+	return lockedResources, result.ErrorOrNil()
+}
+
+// templateRenderer processes LockedResourceTemplates, the validation schema of strict templates is retrieved the first time one is processed
+type templateRenderer struct {
+	ctx      context.Context
+	config   *rest.Config
+	library  *utilstemplates.Library
+	recorder *utilstemplates.LookupRecorder
+	schema   validation.Schema
+}
+
+func newTemplateRenderer(config *rest.Config, library *utilstemplates.Library, recorder *utilstemplates.LookupRecorder) *templateRenderer {
 	ctx := context.TODO()
 	ctx = context.WithValue(ctx, "restConfig", config)
 	ctx = log.IntoContext(ctx, innerlog)
-	for _, resource := range resources {
-		template, err := getTemplate(&resource, config, innerlog)
-		if err != nil {
-			innerlog.Error(err, "unable to retrieve template for", "resource", resource)
-			return []LockedResource{}, nil
-		}
-		objs, err := utilstemplates.ProcessTemplateArray(ctx, params, template)
+	return &templateRenderer{
+		ctx:      ctx,
+		config:   config,
+		library:  library,
+		recorder: recorder,
+	}
+}
+
+// render processes resource with params. Strict templates are validated against the schema of the cluster, unless there is no rest config.
+func (tr *templateRenderer) render(resource *utilsapi.LockedResourceTemplate, params interface{}) ([]LockedResource, error) {
+	template, err := getTemplate(resource, tr.config, tr.library, innerlog)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse: %w", err)
+	}
+	if tr.recorder != nil {
+		template, err = utilstemplates.WithLookupRecorder(template, tr.config, innerlog, tr.recorder)
 		if err != nil {
-			innerlog.Error(err, "unable to process template for", "resource", resource, "params", params)
-			return []LockedResource{}, nil
+			return nil, fmt.Errorf("unable to attach lookup recorder: %w", err)
 		}
-		for _, obj := range objs {
-			lockedResources = append(lockedResources, LockedResource{
-				Unstructured:  obj,
-				ExcludedPaths: resource.ExcludedPaths,
-			})
+	}
+	var objs []unstructured.Unstructured
+	if resource.Strict {
+		if tr.schema == nil && tr.config != nil {
+			tr.schema, err = utilstemplates.GetValidationSchema(tr.config)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get validation schema: %w", err)
+			}
 		}
+		objs, err = utilstemplates.ProcessTemplateArrayStrict(tr.ctx, params, template, tr.schema)
+	} else {
+		objs, err = utilstemplates.ProcessTemplateArray(tr.ctx, params, template)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to process: %w", err)
+	}
+	lockedResources := []LockedResource{}
+	for _, obj := range objs {
+		lockedResources = append(lockedResources, LockedResource{
+			Unstructured:      obj,
+			ExcludedPaths:     resource.ExcludedPaths,
+			Mode:              resource.Mode,
+			DependsOn:         resource.DependsOn,
+			DeletionPolicy:    resource.DeletionPolicy,
+			PropagationPolicy: resource.PropagationPolicy,
+		})
 	}
 	return lockedResources, nil
 }
 
-func getTemplate(resource *utilsapi.LockedResourceTemplate, config *rest.Config, logger logr.Logger) (*template.Template, error) {
-	tmpl, ok := templates[resource.ObjectTemplate]
-	var err error
-	if !ok {
-		tmpl, err = template.New(resource.ObjectTemplate).Funcs(utilstemplates.AdvancedTemplateFuncMap(config, logger)).Parse(resource.ObjectTemplate)
+func getTemplate(resource *utilsapi.LockedResourceTemplate, config *rest.Config, library *utilstemplates.Library, logger logr.Logger) (*template.Template, error) {
+	key := templateKey{
+		text:    resource.ObjectTemplate,
+		library: library.Key(),
+	}
+	templatesLock.Lock()
+	defer templatesLock.Unlock()
+	if tmpl, ok := templates.Get(key); ok {
+		return tmpl.(*template.Template), nil
+	}
+	tmpl, err := utilstemplates.NewTemplate("objectTemplate", resource.ObjectTemplate, config, logger, library)
+	if err != nil {
+		innerlog.Error(err, "unable to parse", "template", resource.ObjectTemplate)
+		return nil, err
+	}
+	templates.Add(key, tmpl)
+	return tmpl, nil
+}
+
+// GetTemplateLibrary loads the passed TemplateLibrary, reading its ConfigMaps from the cluster. namespace is the namespace of the ConfigMaps that do not specify one, usually the one of the object referencing the library.
+// A nil TemplateLibrary returns a nil Library, which templates can be created with.
+func GetTemplateLibrary(ctx context.Context, config *rest.Config, library *utilsapi.TemplateLibrary, namespace string) (*utilstemplates.Library, error) {
+	if library == nil {
+		return nil, nil
+	}
+	result := utilstemplates.NewLibrary()
+	if len(library.ConfigMapRefs) > 0 {
+		ctx = context.WithValue(ctx, "restConfig", config)
+		ctx = log.IntoContext(ctx, innerlog)
+		client, _, err := dynamicclient.GetDynamicClientForGVK(ctx, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
 		if err != nil {
-			innerlog.Error(err, "unable to parse", "template", resource.ObjectTemplate)
 			return nil, err
 		}
-		templates[resource.ObjectTemplate] = tmpl
+		for _, ref := range library.ConfigMapRefs {
+			obj, err := client.Namespace(GetConfigMapNamespace(ref, namespace)).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("unable to get template library ConfigMap %s: %w", GetConfigMapNamespace(ref, namespace)+"/"+ref.Name, err)
+			}
+			configMap := &corev1.ConfigMap{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), configMap)
+			if err != nil {
+				return nil, err
+			}
+			err = result.AddConfigMap(configMap)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
-	return tmpl, nil
+	names := make([]string, 0, len(library.Templates))
+	for name := range library.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := result.Add(name, library.Templates[name])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// GetConfigMapNamespace returns the namespace of the referenced ConfigMap, namespace if the reference does not specify one
+func GetConfigMapNamespace(ref utilsapi.ConfigMapReference, namespace string) string {
+	if ref.Namespace != "" {
+		return ref.Namespace
+	}
+	return namespace
 }
 
 // DefaultExcludedPaths represents paths that are exlcuded by default in all resources
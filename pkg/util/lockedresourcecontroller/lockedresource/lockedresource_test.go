@@ -0,0 +1,126 @@
+package lockedresource
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/go-logr/logr"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	utilstemplates "github.com/redhat-cop/operator-utils/pkg/util/templates"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newConfigMapResource() LockedResource {
+	return LockedResource{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      "config",
+			"namespace": "ns",
+		},
+	}}}
+}
+
+func TestGetKey(t *testing.T) {
+	secret := utilsapi.LockedResourceReference{APIVersion: "v1", Kind: "Secret", Namespace: "ns", Name: "secret"}
+	namespace := utilsapi.LockedResourceReference{APIVersion: "v1", Kind: "Namespace", Name: "ns"}
+	tests := []struct {
+		name   string
+		modify func(resource *LockedResource)
+		same   bool
+	}{
+		{
+			name:   "unchanged",
+			modify: func(resource *LockedResource) {},
+			same:   true,
+		},
+		{
+			name: "explicit default mode",
+			modify: func(resource *LockedResource) {
+				resource.Mode = utilsapi.EnforcementModeEnforce
+			},
+			same: true,
+		},
+		{
+			name: "mode",
+			modify: func(resource *LockedResource) {
+				resource.Mode = utilsapi.EnforcementModeAudit
+			},
+		},
+		{
+			name: "explicit default deletion policy",
+			modify: func(resource *LockedResource) {
+				resource.DeletionPolicy = utilsapi.DeletionPolicyDelete
+			},
+			same: true,
+		},
+		{
+			name: "deletion policy",
+			modify: func(resource *LockedResource) {
+				resource.DeletionPolicy = utilsapi.DeletionPolicyOrphan
+			},
+		},
+		{
+			name: "propagation policy",
+			modify: func(resource *LockedResource) {
+				resource.PropagationPolicy = utilsapi.PropagationPolicyForeground
+			},
+		},
+		{
+			name: "dependencies",
+			modify: func(resource *LockedResource) {
+				resource.DependsOn = []utilsapi.LockedResourceReference{secret}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			original := newConfigMapResource()
+			modified := newConfigMapResource()
+			test.modify(&modified)
+			if same := original.GetKey() == modified.GetKey(); same != test.same {
+				t.Errorf("expected same key to be %v, got %v", test.same, same)
+			}
+		})
+	}
+
+	first := newConfigMapResource()
+	first.DependsOn = []utilsapi.LockedResourceReference{secret, namespace}
+	second := newConfigMapResource()
+	second.DependsOn = []utilsapi.LockedResourceReference{namespace, secret}
+	if first.GetKey() != second.GetKey() {
+		t.Error("expected the key not to depend on the order of the dependencies")
+	}
+}
+
+func TestGetTemplate(t *testing.T) {
+	resource := &utilsapi.LockedResourceTemplate{ObjectTemplate: `{{ include "name" . }}`}
+	library := utilstemplates.NewLibrary()
+	err := library.Add("lib", `{{ define "name" }}a{{ end }}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tmpl, err := getTemplate(resource, nil, library, logr.Discard())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cached, _ := getTemplate(resource, nil, library, logr.Discard()); cached != tmpl {
+		t.Error("expected the parsed template to be cached")
+	}
+	err = library.Add("lib", `{{ define "name" }}b{{ end }}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reparsed, _ := getTemplate(resource, nil, library, logr.Discard()); reparsed == tmpl {
+		t.Error("expected the template to be parsed again when the library changes")
+	}
+	for i := 0; i <= maxCachedTemplates; i++ {
+		_, err := getTemplate(&utilsapi.LockedResourceTemplate{ObjectTemplate: strconv.Itoa(i)}, nil, nil, logr.Discard())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if templates.Len() != maxCachedTemplates {
+		t.Errorf("expected %d cached templates, got %d", maxCachedTemplates, templates.Len())
+	}
+}
@@ -2,7 +2,6 @@ package lockedresource
 
 import (
 	"encoding/json"
-	"strings"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -15,26 +14,27 @@ func FilterOutPaths(obj *unstructured.Unstructured, jsonPaths []string) (*unstru
 		return &unstructured.Unstructured{}, err
 	}
 
-	patches, err := createPatchesFromJSONPaths(jsonPaths)
+	patch, err := createPatchFromJSONPaths(obj.Object, jsonPaths)
 	if err != nil {
-		innerlog.Error(err, "unable to create patches from", "jsonPaths", jsonPaths)
+		innerlog.Error(err, "unable to create patch from", "jsonPaths", jsonPaths)
 		return &unstructured.Unstructured{}, err
 	}
-	for _, patch := range patches {
-		decodedPatch, err := jsonpatch.DecodePatch(patch)
+	if len(patch) > 0 {
+		mpatch, err := json.Marshal(patch)
+		if err != nil {
+			innerlog.Error(err, "unable to marshal", "patch", patch)
+			return &unstructured.Unstructured{}, err
+		}
+		decodedPatch, err := jsonpatch.DecodePatch(mpatch)
 		if err != nil {
-			innerlog.Error(err, "unable to decode", "patch", string(patch))
+			innerlog.Error(err, "unable to decode", "patch", string(mpatch))
 			return &unstructured.Unstructured{}, err
 		}
-		doc1, err := decodedPatch.Apply(doc)
+		doc, err = decodedPatch.Apply(doc)
 		if err != nil {
-			if strings.Contains(err.Error(), "Unable to remove nonexistent key") || strings.Contains(err.Error(), "remove operation does not apply: doc is missing path") {
-				continue
-			}
-			innerlog.Error(err, "unable to apply", "patch", patch, "to json", string(doc))
+			innerlog.Error(err, "unable to apply", "patch", string(mpatch), "to json", string(doc))
 			return &unstructured.Unstructured{}, err
 		}
-		doc = doc1
 	}
 
 	var result = &unstructured.Unstructured{}
@@ -55,35 +55,23 @@ type Patch struct {
 	Path      string `json:"path"`
 }
 
-func createPatchesFromJSONPaths(jsonPaths []string) ([][]byte, error) {
-	result := [][]byte{}
+// createPatchFromJSONPaths evaluates the JSONPath expressions against the document and returns a patch removing all of the matched values.
+func createPatchFromJSONPaths(doc map[string]interface{}, jsonPaths []string) ([]Patch, error) {
+	paths := [][]string{}
 	for _, jsonPath := range jsonPaths {
-		patch := []Patch{
-			{
-				Operation: "remove",
-				Path:      getMergePathFromJSONPath(jsonPath),
-			},
-		}
-		mpatch, err := json.Marshal(patch)
+		found, err := findPaths(doc, jsonPath)
 		if err != nil {
-			innerlog.Error(err, "unable to marshal", "patch", patch)
-			return [][]byte{}, err
+			return []Patch{}, err
 		}
-		result = append(result, mpatch)
+		paths = append(paths, found...)
 	}
-	return result, nil
-}
-
-func getMergePathFromJSONPath(jsonPath string) string {
-	//remove "$" if present
-	jsonPath = strings.TrimPrefix(jsonPath, "$")
-	// convert "[" and "]" to "."
-	if strings.HasSuffix(jsonPath, "]") {
-		jsonPath = jsonPath[:len(jsonPath)-2]
+	// the pointers are computed together, so that removals of array elements selected by different paths don't shift each other's indexes
+	result := []Patch{}
+	for _, pointer := range toJSONPointers(paths) {
+		result = append(result, Patch{
+			Operation: "remove",
+			Path:      pointer,
+		})
 	}
-	jsonPath = strings.ReplaceAll(jsonPath, "[", ".")
-	jsonPath = strings.ReplaceAll(jsonPath, "]", ".")
-	// convert "." to "/"
-	jsonPath = strings.ReplaceAll(jsonPath, ".", "/")
-	return jsonPath
+	return result, nil
 }
@@ -0,0 +1,106 @@
+package lockedresource
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newPod() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name": "test",
+			"annotations": map[string]interface{}{
+				"example.com/owner": "me",
+				"other":             "value",
+			},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "main", "image": "main:1"},
+				map[string]interface{}{"name": "sidecar", "image": "sidecar:1"},
+				map[string]interface{}{"name": "other", "image": "other:1"},
+			},
+		},
+	}}
+}
+
+func TestFilterOutPaths(t *testing.T) {
+	tests := []struct {
+		name      string
+		jsonPaths []string
+		expected  func(obj map[string]interface{})
+	}{
+		{
+			name:      "dotted path",
+			jsonPaths: []string{".metadata.annotations"},
+			expected: func(obj map[string]interface{}) {
+				delete(obj["metadata"].(map[string]interface{}), "annotations")
+			},
+		},
+		{
+			name:      "escaped key",
+			jsonPaths: []string{`$.metadata.annotations.example\.com/owner`},
+			expected: func(obj map[string]interface{}) {
+				delete(obj["metadata"].(map[string]interface{})["annotations"].(map[string]interface{}), "example.com/owner")
+			},
+		},
+		{
+			name:      "filter",
+			jsonPaths: []string{`.spec.containers[?(@.name=="sidecar")].image`},
+			expected: func(obj map[string]interface{}) {
+				delete(obj["spec"].(map[string]interface{})["containers"].([]interface{})[1].(map[string]interface{}), "image")
+			},
+		},
+		{
+			name:      "wildcard",
+			jsonPaths: []string{".spec.containers[*].image"},
+			expected: func(obj map[string]interface{}) {
+				for _, container := range obj["spec"].(map[string]interface{})["containers"].([]interface{}) {
+					delete(container.(map[string]interface{}), "image")
+				}
+			},
+		},
+		{
+			name:      "array elements removed by several paths",
+			jsonPaths: []string{".spec.containers[0]", ".spec.containers[2]"},
+			expected: func(obj map[string]interface{}) {
+				spec := obj["spec"].(map[string]interface{})
+				spec["containers"] = spec["containers"].([]interface{})[1:2]
+			},
+		},
+		{
+			name:      "missing path",
+			jsonPaths: []string{".spec.volumes", ".spec.containers[5]"},
+			expected:  func(obj map[string]interface{}) {},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := FilterOutPaths(newPod(), test.jsonPaths)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := newPod()
+			test.expected(expected.Object)
+			if !reflect.DeepEqual(expected.Object, result.Object) {
+				t.Errorf("expected %v, got %v", expected.Object, result.Object)
+			}
+		})
+	}
+}
+
+func TestValidateJSONPaths(t *testing.T) {
+	err := ValidateJSONPaths([]string{".spec.replicas", "$.spec.containers[?(@.name==\"sidecar\")].image", "{.metadata.labels}"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, jsonPath := range []string{".spec.containers[?(@.name==", "{range .items[*]}{.name}{end}"} {
+		if ValidateJSONPaths([]string{jsonPath}) == nil {
+			t.Errorf("expected an error for %q", jsonPath)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/go-logr/logr"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/redhat-cop/operator-utils/pkg/util/discoveryclient"
+	"github.com/redhat-cop/operator-utils/pkg/util/templates"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// lookupWatcher watches the objects looked up by the templates of the parents of an EnforcingReconciler and notifies a parent when one of its looked up objects is created, changed or deleted.
+// The watches are served by the shared cache of the EnforcingReconciler, so only one watch per GVK is opened to the API server, regardless of the number of parents.
+type lookupWatcher struct {
+	sharedCache *sharedCache
+	config      *rest.Config
+	notify      chan<- event.GenericEvent
+	parents     map[string]*parentLookups
+	lock        sync.Mutex
+	log         logr.Logger
+}
+
+// parentLookups are the lookups watched for a parent
+type parentLookups struct {
+	records []templates.LookupRecord
+	cancel  context.CancelFunc
+}
+
+func newLookupWatcher(sharedCache *sharedCache, config *rest.Config, notify chan<- event.GenericEvent, log logr.Logger) *lookupWatcher {
+	return &lookupWatcher{
+		sharedCache: sharedCache,
+		config:      config,
+		notify:      notify,
+		parents:     map[string]*parentLookups{},
+		log:         log,
+	}
+}
+
+// set replaces the lookups watched for the parent identified by parentKey, the watches are renewed only if the lookups have changed.
+// Lookups whose type cannot be resolved are not watched, their errors are aggregated in the returned error.
+func (lw *lookupWatcher) set(parentKey string, parent client.Object, records []templates.LookupRecord) error {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	previous, ok := lw.parents[parentKey]
+	if ok && reflect.DeepEqual(previous.records, records) {
+		return nil
+	}
+	if ok {
+		previous.cancel()
+		delete(lw.parents, parentKey)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	lw.sharedCache.start(context.TODO())
+	ctx, cancel := context.WithCancel(context.Background())
+	result := &multierror.Error{}
+	for _, record := range records {
+		object, key, namespace, err := lw.getSubscription(record)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("lookup of %s %s: %w", record.GroupVersionKind(), record.Namespace+"/"+record.Name, err))
+			continue
+		}
+		handler := &lookupEventHandler{
+			parent:    parent,
+			notify:    lw.notify,
+			namespace: namespace,
+		}
+		go func() {
+			err := lw.sharedCache.router.subscribe(ctx, object, key, handler)
+			if err != nil && ctx.Err() == nil {
+				lw.log.Error(err, "unable to watch looked up objects", "gvk", object.GroupVersionKind(), "key", key, "parent", parentKey)
+			}
+		}()
+	}
+	lw.parents[parentKey] = &parentLookups{
+		records: records,
+		cancel:  cancel,
+	}
+	return result.ErrorOrNil()
+}
+
+// remove stops the watches of the parent identified by parentKey
+func (lw *lookupWatcher) remove(parentKey string) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	previous, ok := lw.parents[parentKey]
+	if !ok {
+		return
+	}
+	previous.cancel()
+	delete(lw.parents, parentKey)
+}
+
+// getSubscription returns the object type and the router key to subscribe to for the passed lookup, along with the namespace the events must be filtered by, if any.
+// A lookup of a single object subscribes to its key, a lookup of a list subscribes to all the objects of the type.
+// The namespace of a lookup of a cluster level type is ignored, as the lookup function does.
+func (lw *lookupWatcher) getSubscription(record templates.LookupRecord) (*unstructured.Unstructured, string, string, error) {
+	discoveryCache, err := discoveryclient.GetDiscoveryCache(lw.config)
+	if err != nil {
+		return nil, "", "", err
+	}
+	mapping, err := discoveryCache.RESTMapping(record.GroupVersionKind())
+	if err != nil {
+		return nil, "", "", err
+	}
+	namespace := record.Namespace
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		namespace = ""
+	}
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(record.GroupVersionKind())
+	if record.Name != "" {
+		return object, namespace + "/" + record.Name, "", nil
+	}
+	return object, "", namespace, nil
+}
+
+// lookupEventHandler notifies a parent of the changes of its looked up objects, the objects already present when the watch is established are not notified, as the parent has just been rendered with them
+type lookupEventHandler struct {
+	parent client.Object
+	notify chan<- event.GenericEvent
+	// namespace, if not empty, filters the events of the objects of other namespaces
+	namespace string
+}
+
+func (h *lookupEventHandler) notifyParent(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, ok := obj.(client.Object)
+	if !ok || (h.namespace != "" && object.GetNamespace() != h.namespace) {
+		return
+	}
+	notify, parent := h.notify, h.parent
+	go func() {
+		notify <- event.GenericEvent{Object: parent}
+	}()
+}
+
+// OnAdd implements toolscache.ResourceEventHandler
+func (h *lookupEventHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	if isInInitialList {
+		return
+	}
+	h.notifyParent(obj)
+}
+
+// OnUpdate implements toolscache.ResourceEventHandler, resyncs are ignored
+func (h *lookupEventHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldObject, ok := oldObj.(client.Object)
+	if !ok {
+		return
+	}
+	newObject, ok := newObj.(client.Object)
+	if !ok || oldObject.GetResourceVersion() == newObject.GetResourceVersion() {
+		return
+	}
+	h.notifyParent(newObj)
+}
+
+// OnDelete implements toolscache.ResourceEventHandler
+func (h *lookupEventHandler) OnDelete(obj interface{}) {
+	h.notifyParent(obj)
+}
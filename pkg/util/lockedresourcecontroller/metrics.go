@@ -0,0 +1,88 @@
+package lockedresourcecontroller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// metrics are registered on the controller-runtime registry, so they are served by the metrics endpoint of the operator manager.
+// Reconciler level metrics are labeled with the key of the parent object, in the <group>/<kind>/<namespace>/<name> format, the GVK of the enforced resource or patch target and the patch name, which is empty for resources.
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lockedresourcecontroller_reconcile_total",
+		Help: "Total number of reconciliations of locked resources and patches",
+	}, []string{"parent", "gvk", "patch"})
+
+	reconcileErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lockedresourcecontroller_reconcile_errors_total",
+		Help: "Total number of failed reconciliations of locked resources and patches",
+	}, []string{"parent", "gvk", "patch"})
+
+	driftCorrectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lockedresourcecontroller_drift_corrections_total",
+		Help: "Total number of times a locked resource or patch target was restored to its desired state",
+	}, []string{"parent", "gvk", "patch"})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lockedresourcecontroller_reconcile_duration_seconds",
+		Help:    "Duration of the reconciliations of locked resources and patches",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"parent", "gvk", "patch"})
+
+	activeLockedResourceManagers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lockedresourcecontroller_active_managers",
+		Help: "Number of started LockedResourceManagers",
+	})
+
+	activeResourceReconcilers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lockedresourcecontroller_active_resource_reconcilers",
+		Help: "Number of running locked resource reconcilers",
+	}, []string{"parent"})
+
+	activePatchReconcilers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lockedresourcecontroller_active_patch_reconcilers",
+		Help: "Number of running locked patch reconcilers",
+	}, []string{"parent"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileTotal,
+		reconcileErrorsTotal,
+		driftCorrectionsTotal,
+		reconcileDuration,
+		activeLockedResourceManagers,
+		activeResourceReconcilers,
+		activePatchReconcilers,
+	)
+}
+
+// observeReconcile records the outcome and the duration of a reconciliation
+func observeReconcile(labels prometheus.Labels, start time.Time, err error) {
+	reconcileTotal.With(labels).Inc()
+	reconcileDuration.With(labels).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconcileErrorsTotal.With(labels).Inc()
+	}
+}
+
+func metricLabels(parent string, gvk string, patch string) prometheus.Labels {
+	return prometheus.Labels{
+		"parent": parent,
+		"gvk":    gvk,
+		"patch":  patch,
+	}
+}
+
+// deleteParentMetrics drops all the series of the parent identified by parent, so that the series of the stopped LockedResourceManagers do not accumulate
+func deleteParentMetrics(parent string) {
+	labels := prometheus.Labels{"parent": parent}
+	reconcileTotal.DeletePartialMatch(labels)
+	reconcileErrorsTotal.DeletePartialMatch(labels)
+	driftCorrectionsTotal.DeletePartialMatch(labels)
+	reconcileDuration.DeletePartialMatch(labels)
+	activeResourceReconcilers.DeletePartialMatch(labels)
+	activePatchReconcilers.DeletePartialMatch(labels)
+}
@@ -0,0 +1,158 @@
+package lockedresourcecontroller
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// defaultDriftHistorySize is the default number of drift corrections kept for each locked resource
+const defaultDriftHistorySize = 10
+
+// Option configures optional behaviours of the EnforcingReconciler, of the LockedResourceManagers it creates and of their reconcilers.
+// The zero set of options preserves the default behaviour.
+type Option func(*options)
+
+type options struct {
+	serverSideApply  bool
+	fieldManager     string
+	forceConflicts   bool
+	useSharedCache   bool
+	sharedCache      *sharedCache
+	driftHistorySize int
+	parentRecorder   record.EventRecorder
+	newRateLimiter   func() ratelimiter.RateLimiter
+	fightThreshold   int
+	fightWindow      time.Duration
+	fightMaxBackoff  time.Duration
+	kindOrdering     bool
+	healthChecks     bool
+	prunableKinds    []schema.GroupVersionKind
+	ownerKey         string
+	claimPriority    func(parent client.Object) int
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		driftHistorySize: defaultDriftHistorySize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithServerSideApply enforces LockedResources with server-side apply under the passed field manager, instead of comparing and merge-patching them.
+// Only the fields declared in the LockedResource are owned and enforced, so ExcludedPaths are not considered in this mode.
+// If forceConflicts is true, ownership of conflicting fields is taken away from the other field managers, otherwise conflicts are reported as a Conflict condition.
+func WithServerSideApply(fieldManager string, forceConflicts bool) Option {
+	return func(o *options) {
+		o.serverSideApply = true
+		o.fieldManager = fieldManager
+		o.forceConflicts = forceConflicts
+	}
+}
+
+// WithSharedCache makes all the LockedResourceManagers of an EnforcingReconciler share a single cluster level cache, instead of creating one manager each.
+// Only one watch per GVK is opened to the API server and events are routed to the reconcilers of each parent by key.
+// Because watches are at the cluster level, the clusterWatchers parameter is ignored and cluster level permissions are needed.
+func WithSharedCache() Option {
+	return func(o *options) {
+		o.useSharedCache = true
+	}
+}
+
+// withSharedCache passes the shared cache created by the EnforcingReconciler down to the LockedResourceManagers and reconcilers
+func withSharedCache(sharedCache *sharedCache) Option {
+	return func(o *options) {
+		o.sharedCache = sharedCache
+	}
+}
+
+// WithDriftHistorySize sets how many of the most recent drift corrections are kept, and reported in the status, for each locked resource. Defaults to 10, 0 disables the history.
+func WithDriftHistorySize(size int) Option {
+	return func(o *options) {
+		o.driftHistorySize = size
+	}
+}
+
+// withParentRecorder passes the event recorder of the EnforcingReconciler down to the reconcilers, so that events can be recorded on the parent objects
+func withParentRecorder(recorder record.EventRecorder) Option {
+	return func(o *options) {
+		o.parentRecorder = recorder
+	}
+}
+
+// WithRateLimiter sets the rate limiter of the reconcilers of locked resources and patches. newRateLimiter is called once per reconciler, so that each of them has its own rate limiter.
+// By default the controller-runtime default rate limiter is used.
+func WithRateLimiter(newRateLimiter func() ratelimiter.RateLimiter) Option {
+	return func(o *options) {
+		o.newRateLimiter = newRateLimiter
+	}
+}
+
+// WithFightDetection detects fights with other controllers over the enforced fields. When a resource or a patch target is corrected threshold times inside window,
+// it is marked with a Contested condition, naming the field manager that last changed it, and further corrections are delayed with an exponential backoff, capped at maxBackoff.
+func WithFightDetection(threshold int, window time.Duration, maxBackoff time.Duration) Option {
+	return func(o *options) {
+		o.fightThreshold = threshold
+		o.fightWindow = window
+		o.fightMaxBackoff = maxBackoff
+	}
+}
+
+// WithKindOrdering enforces the locked resources by kind, in the same order helm installs resources: Namespaces first, then CustomResourceDefinitions, RBAC and finally workloads.
+// The reconciler of a resource waits until the resources of the previous kinds have been reconciled successfully, the resources are deleted in the reverse order.
+func WithKindOrdering() Option {
+	return func(o *options) {
+		o.kindOrdering = true
+	}
+}
+
+// WithHealthChecks evaluates the health of the enforced resources with the checks registered in the health package, the result is reported as a Healthy condition for each locked resource.
+// The EnforcingReconciler also reports a ResourcesReady condition on the parent, which is true when all of the locked resources are healthy.
+func WithHealthChecks() Option {
+	return func(o *options) {
+		o.healthChecks = true
+	}
+}
+
+// WithPruning stamps the enforced resources with the OwnerLabel label and with the OwnerAnnotation annotation, and prunes the objects of the passed kinds
+// that are stamped as owned by a parent but are no longer among its locked resources. See LockedResourceManager.Prune.
+// The EnforcingReconciler only prunes when the enforced resources or patches change, including the first time they are set after a restart.
+// Stale objects that appear while the set does not change, for example restored from a backup, are pruned at the next change.
+func WithPruning(prunableKinds ...schema.GroupVersionKind) Option {
+	return func(o *options) {
+		o.prunableKinds = prunableKinds
+	}
+}
+
+// WithClaimPriority sets how the priority of the claims of a parent is determined. When two parents claim the same object, or the same fields of an object with patches,
+// only the claim with the higher priority is enforced and both parents get a Conflict condition. Between equal priorities, the older claim wins.
+// By default the priority is read from the PriorityAnnotation of the parent.
+func WithClaimPriority(priority func(parent client.Object) int) Option {
+	return func(o *options) {
+		o.claimPriority = priority
+	}
+}
+
+// withOwnerKey passes the owner key of the parent computed by the EnforcingReconciler, which knows the parent type even when the parent object does not carry it
+func withOwnerKey(ownerKey string) Option {
+	return func(o *options) {
+		o.ownerKey = ownerKey
+	}
+}
+
+// controllerOptions returns the options of the controller of a reconciler
+func (o options) controllerOptions(reconciler reconcile.Reconciler) controller.Options {
+	controllerOptions := controller.Options{Reconciler: reconciler}
+	if o.newRateLimiter != nil {
+		controllerOptions.RateLimiter = o.newRateLimiter()
+	}
+	return controllerOptions
+}
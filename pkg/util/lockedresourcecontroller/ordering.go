@@ -0,0 +1,256 @@
+package lockedresourcecontroller
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/apis"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"github.com/scylladb/go-set/strset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// dependencyRequeueInterval is how often a reconciler checks again whether its dependencies have been reconciled
+const dependencyRequeueInterval = 5 * time.Second
+
+// kindOrder is the order in which kinds are enforced when kind ordering is on, it is the order in which helm installs resources.
+// Kinds that are not in the list are enforced last.
+var kindOrder = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+func getKindPriority(kind string) int {
+	for i := range kindOrder {
+		if kindOrder[i] == kind {
+			return i
+		}
+	}
+	return len(kindOrder)
+}
+
+func getReferenceKey(reference utilsapi.LockedResourceReference) string {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(reference.APIVersion)
+	obj.SetKind(reference.Kind)
+	obj.SetNamespace(reference.Namespace)
+	obj.SetName(reference.Name)
+	return apis.GetKeyLong(obj)
+}
+
+// dependency is a resource that must be reconciled successfully before another one is enforced
+type dependency struct {
+	key string
+	// implicit is true if the dependency comes from kind ordering rather than from DependsOn
+	implicit bool
+}
+
+// getDependencies returns, for each enforced resource, the resources that must be reconciled successfully before it is enforced.
+// Dependencies are the resources referenced in DependsOn and, with kind ordering, all the resources of kinds that come earlier in kindOrder.
+// Audited and disabled resources are never written, so they are neither waiting for dependencies nor considered as dependencies.
+// References to the keys in external, resources that exist but are not enforced along with the passed ones, are left out.
+// An error is returned if another reference does not match any of the resources or if the dependencies are circular.
+func getDependencies(resources []lockedresource.LockedResource, kindOrdering bool, external *strset.Set) (map[string][]dependency, error) {
+	modes := map[string]utilsapi.EnforcementMode{}
+	for i := range resources {
+		modes[apis.GetKeyLong(&resources[i].Unstructured)] = resources[i].GetMode()
+	}
+	dependencies := map[string][]dependency{}
+	for i := range resources {
+		if !isWritingMode(resources[i].GetMode()) {
+			continue
+		}
+		key := apis.GetKeyLong(&resources[i].Unstructured)
+		keyDependencies := []dependency{}
+		for _, reference := range resources[i].DependsOn {
+			referenceKey := getReferenceKey(reference)
+			mode, ok := modes[referenceKey]
+			if !ok {
+				if external.Has(referenceKey) {
+					continue
+				}
+				return nil, errors.New("resource " + key + " depends on " + referenceKey + ", which is not a locked resource")
+			}
+			if isWritingMode(mode) {
+				keyDependencies = append(keyDependencies, dependency{key: referenceKey})
+			}
+		}
+		if kindOrdering {
+			priority := getKindPriority(resources[i].GetKind())
+			for j := range resources {
+				if isWritingMode(resources[j].GetMode()) && getKindPriority(resources[j].GetKind()) < priority {
+					keyDependencies = append(keyDependencies, dependency{key: apis.GetKeyLong(&resources[j].Unstructured), implicit: true})
+				}
+			}
+		}
+		dependencies[key] = keyDependencies
+	}
+	_, err := sortByDependencies(resources, dependencies)
+	if err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
+
+// getLostDependencies returns, for each enforced resource, the messages describing its references to lostResources, the resources claimed by other parents keyed to the winning parent
+func getLostDependencies(resources []lockedresource.LockedResource, lostResources map[string]string) map[string][]string {
+	lostDependencies := map[string][]string{}
+	for i := range resources {
+		if !isWritingMode(resources[i].GetMode()) {
+			continue
+		}
+		for _, reference := range resources[i].DependsOn {
+			referenceKey := getReferenceKey(reference)
+			if otherParent, ok := lostResources[referenceKey]; ok {
+				key := apis.GetKeyLong(&resources[i].Unstructured)
+				lostDependencies[key] = append(lostDependencies[key], referenceKey+" is claimed by "+otherParent)
+			}
+		}
+	}
+	return lostDependencies
+}
+
+// sortByDependencies returns the resources in an order in which every resource comes after its dependencies, resources with no relationship keep their relative order.
+func sortByDependencies(resources []lockedresource.LockedResource, dependencies map[string][]dependency) ([]lockedresource.LockedResource, error) {
+	sorted := []lockedresource.LockedResource{}
+	done := map[string]bool{}
+	remaining := resources
+	for len(remaining) > 0 {
+		next := []lockedresource.LockedResource{}
+		for i := range remaining {
+			ready := true
+			for _, dependency := range dependencies[apis.GetKeyLong(&remaining[i].Unstructured)] {
+				if !done[dependency.key] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, remaining[i])
+			} else {
+				next = append(next, remaining[i])
+			}
+		}
+		if len(next) == len(remaining) {
+			keys := []string{}
+			for i := range remaining {
+				keys = append(keys, apis.GetKeyLong(&remaining[i].Unstructured))
+			}
+			return nil, errors.New("circular dependencies between resources: " + strings.Join(keys, ", "))
+		}
+		for i := range sorted {
+			done[apis.GetKeyLong(&sorted[i].Unstructured)] = true
+		}
+		remaining = next
+	}
+	return sorted, nil
+}
+
+// getDeletionOrder returns the passed resources in the reverse order of their dependencies, so that a resource is deleted before the ones it depends on.
+// References to resources that are not being deleted are ignored.
+func getDeletionOrder(resources []lockedresource.LockedResource, kindOrdering bool) ([]lockedresource.LockedResource, error) {
+	external := strset.New()
+	for i := range resources {
+		for _, reference := range resources[i].DependsOn {
+			external.Add(getReferenceKey(reference))
+		}
+	}
+	for i := range resources {
+		external.Remove(apis.GetKeyLong(&resources[i].Unstructured))
+	}
+	dependencies, err := getDependencies(resources, kindOrdering, external)
+	if err != nil {
+		return nil, err
+	}
+	sorted, err := sortByDependencies(resources, dependencies)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+	}
+	return sorted, nil
+}
+
+// setReconcilerDependencies sets on each of the reconcilers the reconcilers of the resources it depends on, and the references to the resources claimed by other parents, see getLostDependencies
+func setReconcilerDependencies(resources []lockedresource.LockedResource, reconcilers []*LockedResourceReconciler, kindOrdering bool, lostResources map[string]string) error {
+	dependencies, err := getDependencies(resources, kindOrdering, getKeySet(lostResources))
+	if err != nil {
+		return err
+	}
+	lostDependencies := getLostDependencies(resources, lostResources)
+	reconcilerMap := map[string]*LockedResourceReconciler{}
+	for _, reconciler := range reconcilers {
+		reconcilerMap[apis.GetKeyLong(&reconciler.Resource)] = reconciler
+	}
+	for _, reconciler := range reconcilers {
+		explicit := []*LockedResourceReconciler{}
+		implicit := []*LockedResourceReconciler{}
+		for _, dependency := range dependencies[apis.GetKeyLong(&reconciler.Resource)] {
+			if dependencyReconciler, ok := reconcilerMap[dependency.key]; ok {
+				if dependency.implicit {
+					implicit = append(implicit, dependencyReconciler)
+				} else {
+					explicit = append(explicit, dependencyReconciler)
+				}
+			}
+		}
+		reconciler.setDependencies(explicit, implicit, lostDependencies[apis.GetKeyLong(&reconciler.Resource)])
+	}
+	return nil
+}
+
+// getKeySet returns the set of the keys of the passed map
+func getKeySet(m map[string]string) *strset.Set {
+	set := strset.New()
+	for key := range m {
+		set.Add(key)
+	}
+	return set
+}
+
+// isReconciled returns whether the passed conditions report a successful reconcile, more recent than any failed one
+func isReconciled(conditions []metav1.Condition) bool {
+	success, ok := apis.GetCondition(apis.ReconcileSuccess, conditions)
+	if !ok {
+		return false
+	}
+	failure, ok := apis.GetCondition(apis.ReconcileError, conditions)
+	return !ok || !success.LastTransitionTime.Before(&failure.LastTransitionTime)
+}
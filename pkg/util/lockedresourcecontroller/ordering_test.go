@@ -0,0 +1,238 @@
+package lockedresourcecontroller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/apis"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"github.com/scylladb/go-set/strset"
+)
+
+const (
+	namespaceKey  = "v1/Namespace//ns"
+	configMapKey  = "v1/ConfigMap/ns/config"
+	deploymentKey = "apps/v1/Deployment/ns/app"
+)
+
+func newOrderedResource(apiVersion string, kind string, namespace string, name string, mode utilsapi.EnforcementMode, dependsOn ...string) lockedresource.LockedResource {
+	resource := lockedresource.LockedResource{Mode: mode}
+	resource.SetAPIVersion(apiVersion)
+	resource.SetKind(kind)
+	resource.SetNamespace(namespace)
+	resource.SetName(name)
+	for _, key := range dependsOn {
+		// keys are <group/version>/<kind>/<namespace>/<name>, the group version may contain a slash
+		segments := strings.Split(key, "/")
+		n := len(segments)
+		resource.DependsOn = append(resource.DependsOn, utilsapi.LockedResourceReference{
+			APIVersion: strings.Join(segments[:n-3], "/"),
+			Kind:       segments[n-3],
+			Namespace:  segments[n-2],
+			Name:       segments[n-1],
+		})
+	}
+	return resource
+}
+
+func TestGetDependencies(t *testing.T) {
+	tests := []struct {
+		name          string
+		resources     []lockedresource.LockedResource
+		kindOrdering  bool
+		external      []string
+		expected      map[string][]dependency
+		expectedError string
+	}{
+		{
+			name: "depends on",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", "", configMapKey),
+				newOrderedResource("v1", "ConfigMap", "ns", "config", ""),
+			},
+			expected: map[string][]dependency{
+				deploymentKey: {{key: configMapKey}},
+				configMapKey:  {},
+			},
+		},
+		{
+			name: "kind ordering",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", "", configMapKey),
+				newOrderedResource("v1", "ConfigMap", "ns", "config", ""),
+				newOrderedResource("v1", "Namespace", "", "ns", ""),
+			},
+			kindOrdering: true,
+			expected: map[string][]dependency{
+				deploymentKey: {{key: configMapKey}, {key: configMapKey, implicit: true}, {key: namespaceKey, implicit: true}},
+				configMapKey:  {{key: namespaceKey, implicit: true}},
+				namespaceKey:  {},
+			},
+		},
+		{
+			name: "audited resources are not dependencies",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", "", configMapKey),
+				newOrderedResource("v1", "ConfigMap", "ns", "config", utilsapi.EnforcementModeAudit),
+				newOrderedResource("v1", "Namespace", "", "ns", utilsapi.EnforcementModeDisabled),
+			},
+			kindOrdering: true,
+			expected: map[string][]dependency{
+				deploymentKey: {},
+			},
+		},
+		{
+			name: "audited resources do not wait",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", utilsapi.EnforcementModeAudit, configMapKey),
+				newOrderedResource("v1", "ConfigMap", "ns", "config", ""),
+			},
+			expected: map[string][]dependency{
+				configMapKey: {},
+			},
+		},
+		{
+			name: "external reference",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", "", configMapKey),
+			},
+			external: []string{configMapKey},
+			expected: map[string][]dependency{
+				deploymentKey: {},
+			},
+		},
+		{
+			name: "unknown reference",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", "", configMapKey),
+			},
+			expectedError: "resource " + deploymentKey + " depends on " + configMapKey + ", which is not a locked resource",
+		},
+		{
+			name: "circular dependencies",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", "", configMapKey),
+				newOrderedResource("v1", "ConfigMap", "ns", "config", "", deploymentKey),
+			},
+			expectedError: "circular dependencies between resources: " + deploymentKey + ", " + configMapKey,
+		},
+		{
+			name: "circular with kind ordering",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("v1", "Namespace", "", "ns", "", configMapKey),
+				newOrderedResource("v1", "ConfigMap", "ns", "config", ""),
+			},
+			kindOrdering:  true,
+			expectedError: "circular dependencies between resources: " + namespaceKey + ", " + configMapKey,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dependencies, err := getDependencies(test.resources, test.kindOrdering, strset.New(test.external...))
+			if test.expectedError != "" {
+				if err == nil || err.Error() != test.expectedError {
+					t.Fatalf("expected error %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(dependencies, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, dependencies)
+			}
+		})
+	}
+}
+
+func TestSortByDependencies(t *testing.T) {
+	tests := []struct {
+		name         string
+		resources    []lockedresource.LockedResource
+		kindOrdering bool
+		expected     []string
+	}{
+		{
+			name: "unrelated resources keep their order",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", ""),
+				newOrderedResource("v1", "ConfigMap", "ns", "config", ""),
+			},
+			expected: []string{deploymentKey, configMapKey},
+		},
+		{
+			name: "depends on",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", "", configMapKey),
+				newOrderedResource("v1", "ConfigMap", "ns", "config", ""),
+				newOrderedResource("v1", "Namespace", "", "ns", ""),
+			},
+			expected: []string{configMapKey, namespaceKey, deploymentKey},
+		},
+		{
+			name: "kind ordering",
+			resources: []lockedresource.LockedResource{
+				newOrderedResource("apps/v1", "Deployment", "ns", "app", ""),
+				newOrderedResource("v1", "ConfigMap", "ns", "config", ""),
+				newOrderedResource("v1", "Namespace", "", "ns", ""),
+			},
+			kindOrdering: true,
+			expected:     []string{namespaceKey, configMapKey, deploymentKey},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dependencies, err := getDependencies(test.resources, test.kindOrdering, strset.New())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			sorted, err := sortByDependencies(test.resources, dependencies)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			actual := []string{}
+			for i := range sorted {
+				actual = append(actual, apis.GetKeyLong(&sorted[i].Unstructured))
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetDeletionOrder(t *testing.T) {
+	resources := []lockedresource.LockedResource{
+		newOrderedResource("v1", "ConfigMap", "ns", "config", "", namespaceKey),
+		newOrderedResource("apps/v1", "Deployment", "ns", "app", "", configMapKey),
+		// the namespace is still enforced, so it is not part of the deleted resources
+		newOrderedResource("v1", "Secret", "ns", "secret", "", namespaceKey),
+	}
+	sorted, err := getDeletionOrder(resources, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual := []string{}
+	for i := range sorted {
+		actual = append(actual, apis.GetKeyLong(&sorted[i].Unstructured))
+	}
+	if expected := []string{deploymentKey, "v1/Secret/ns/secret", configMapKey}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestGetLostDependencies(t *testing.T) {
+	resources := []lockedresource.LockedResource{
+		newOrderedResource("apps/v1", "Deployment", "ns", "app", "", configMapKey, namespaceKey),
+		newOrderedResource("v1", "Secret", "ns", "secret", utilsapi.EnforcementModeAudit, configMapKey),
+	}
+	lostDependencies := getLostDependencies(resources, map[string]string{configMapKey: "example.com/Parent/ns/other"})
+	expected := map[string][]string{
+		deploymentKey: {configMapKey + " is claimed by example.com/Parent/ns/other"},
+	}
+	if !reflect.DeepEqual(lostDependencies, expected) {
+		t.Errorf("expected %v, got %v", expected, lostDependencies)
+	}
+}
@@ -8,12 +8,15 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
 	"github.com/redhat-cop/operator-utils/pkg/util"
 	"github.com/redhat-cop/operator-utils/pkg/util/apis"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -31,23 +34,27 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-	"sigs.k8s.io/controller-runtime/pkg/source"
 	"sigs.k8s.io/yaml"
 )
 
 // LockedPatchReconciler is a reconciler that can enforce a LockedPatch
 type LockedPatchReconciler struct {
 	util.ReconcilerBase
-	patch        lockedpatch.LockedPatch
-	status       map[string][]metav1.Condition
-	statusChange chan<- event.GenericEvent
-	parentObject client.Object
-	statusLock   sync.Mutex
-	log          logr.Logger
+	patch         lockedpatch.LockedPatch
+	status        map[string][]metav1.Condition
+	statusChange  chan<- event.GenericEvent
+	parentObject  client.Object
+	statusLock    sync.Mutex
+	options       options
+	metricLabels  prometheus.Labels
+	fightDetector *fightDetector
+	log           logr.Logger
+	stoppableController
 }
 
 // NewLockedPatchReconciler returns a new reconcile.Reconciler
-func NewLockedPatchReconciler(mgr manager.Manager, patch lockedpatch.LockedPatch, statusChange chan<- event.GenericEvent, parentObject client.Object) (*LockedPatchReconciler, error) {
+// The returned reconciler is not started, the LockedResourceManager starts and stops it independently of the manager passed as parameter.
+func NewLockedPatchReconciler(mgr manager.Manager, patch lockedpatch.LockedPatch, statusChange chan<- event.GenericEvent, parentObject client.Object, opts ...Option) (*LockedPatchReconciler, error) {
 
 	// TODO create the object is it does not exists
 	controllername := "patch-reconciler"
@@ -59,6 +66,8 @@ func NewLockedPatchReconciler(mgr manager.Manager, patch lockedpatch.LockedPatch
 		statusChange:   statusChange,
 		parentObject:   parentObject,
 		statusLock:     sync.Mutex{},
+		options:        newOptions(opts...),
+		metricLabels:   metricLabels(getOwnerKey(newOptions(opts...), parentObject), schema.FromAPIVersionAndKind(patch.TargetObjectRef.APIVersion, patch.TargetObjectRef.Kind).String(), patch.GetKey()),
 		status: map[string][]metav1.Condition{
 			"reconciler": []metav1.Condition([]metav1.Condition{{
 				Type:               "Initializing",
@@ -70,16 +79,23 @@ func NewLockedPatchReconciler(mgr manager.Manager, patch lockedpatch.LockedPatch
 		},
 	}
 
-	controller, err := controller.New(controllername+"_"+patch.GetKey(), mgr, controller.Options{Reconciler: reconciler})
+	reconciler.fightDetector = newFightDetector(reconciler.options.fightThreshold, reconciler.options.fightWindow, reconciler.options.fightMaxBackoff)
+
+	controller, err := controller.NewUnmanaged(controllername+"_"+patch.GetKey(), mgr, reconciler.options.controllerOptions(reconciler))
 	if err != nil {
 		return &LockedPatchReconciler{}, err
 	}
+	reconciler.stoppableController.controller = controller
 
 	//create watcher for target
 	obj := targetObjectRefToRuntimeType(&patch.TargetObjectRef)
 	mgr.GetScheme().AddKnownTypes(schema.FromAPIVersionAndKind(patch.TargetObjectRef.APIVersion, patch.TargetObjectRef.Kind).GroupVersion(), obj)
 
-	err = controller.Watch(source.Kind(mgr.GetCache(), obj), &handler.EnqueueRequestForObject{}, &targetReferenceModifiedPredicate{
+	targetKey := ""
+	if patch.TargetObjectRef.Name != "" {
+		targetKey = patch.TargetObjectRef.Namespace + "/" + patch.TargetObjectRef.Name
+	}
+	err = controller.Watch(newSource(mgr.GetCache(), obj, targetKey, reconciler.options), &handler.EnqueueRequestForObject{}, &targetReferenceModifiedPredicate{
 		TargetObjectReference: patch.TargetObjectRef,
 		log:                   reconciler.log.WithName("target-watcher"),
 		restConfig:            mgr.GetConfig(),
@@ -94,7 +110,11 @@ func NewLockedPatchReconciler(mgr manager.Manager, patch lockedpatch.LockedPatch
 	for _, sourceRef := range patch.SourceObjectRefs {
 		obj := sourceObjectRefToRuntimeType(&sourceRef)
 		mgr.GetScheme().AddKnownTypes(schema.FromAPIVersionAndKind(sourceRef.APIVersion, sourceRef.Kind).GroupVersion(), obj)
-		err = controller.Watch(source.Kind(mgr.GetCache(), obj), &enqueueRequestForPatch{
+		sourceKey := ""
+		if !strings.Contains(sourceRef.Name, "{{") && !strings.Contains(sourceRef.Namespace, "{{") {
+			sourceKey = sourceRef.Namespace + "/" + sourceRef.Name
+		}
+		err = controller.Watch(newSource(mgr.GetCache(), obj, sourceKey, reconciler.options), &enqueueRequestForPatch{
 			source:          &sourceRef,
 			target:          &patch.TargetObjectRef,
 			discoveryClient: discoveryClient,
@@ -114,6 +134,11 @@ func NewLockedPatchReconciler(mgr manager.Manager, patch lockedpatch.LockedPatch
 	return reconciler, nil
 }
 
+// start starts the controller of this reconciler
+func (lpr *LockedPatchReconciler) start(ctx context.Context) {
+	lpr.stoppableController.start(ctx, lpr.log)
+}
+
 func sourceObjectRefToRuntimeType(objref *utilsapi.SourceObjectReference) client.Object {
 	obj := &unstructured.Unstructured{}
 	obj.SetKind(objref.Kind)
@@ -137,13 +162,40 @@ type enqueueRequestForPatch struct {
 }
 
 func (e *enqueueRequestForPatch) Create(ctx context.Context, evt event.CreateEvent, q workqueue.RateLimitingInterface) {
-	//to see if this event is relevant and we have to do the following:
-	// 1. see if the target is single or multiple
-	// 2. if single just see if it matches, the pass the event.
-	// 3. if multiple see which macth and then pass the event
 	e.log.V(1).Info("enqueue create", "for", evt.Object)
 	ctx = context.WithValue(ctx, "restConfig", e.restConfig)
 	ctx = log.IntoContext(ctx, e.log)
+	e.enqueueTargets(ctx, evt.Object, q)
+}
+
+// Update implements EventHandler
+func (e *enqueueRequestForPatch) Update(ctx context.Context, evt event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	// TODO  this could be optmized to see if the change affected the needed jsonpath
+	e.log.V(1).Info("enqueue update", "for", evt.ObjectNew)
+	ctx = context.WithValue(ctx, "discoveryClient", e.discoveryClient)
+	ctx = context.WithValue(ctx, "restConfig", e.restConfig)
+	ctx = log.IntoContext(ctx, e.log)
+	e.enqueueTargets(ctx, evt.ObjectNew, q)
+}
+
+// Delete implements EventHandler
+// The targets of a deleted source are enqueued, so that they can be moved to the SourceMissing condition.
+func (e *enqueueRequestForPatch) Delete(ctx context.Context, evt event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	e.log.V(1).Info("enqueue delete", "for", evt.Object)
+	ctx = context.WithValue(ctx, "restConfig", e.restConfig)
+	ctx = log.IntoContext(ctx, e.log)
+	e.enqueueTargets(ctx, evt.Object, q)
+}
+
+// Generic implements EventHandler
+func (e *enqueueRequestForPatch) Generic(ctx context.Context, evt event.GenericEvent, q workqueue.RateLimitingInterface) {
+}
+
+// enqueueTargets enqueues the targets for which the passed object is the source. To see which targets are relevant we have to do the following:
+// 1. see if the target is single or multiple
+// 2. if single just see if it matches, the pass the event.
+// 3. if multiple see which macth and then pass the event
+func (e *enqueueRequestForPatch) enqueueTargets(ctx context.Context, source client.Object, q workqueue.RateLimitingInterface) {
 	multiple, _, err := e.target.IsSelectingMultipleInstances(ctx)
 	if err != nil {
 		e.log.Error(err, "Unable to determine if target resolves to multiple instance", "target", e.target)
@@ -160,7 +212,7 @@ func (e *enqueueRequestForPatch) Create(ctx context.Context, evt event.CreateEve
 			e.log.Error(err, "Unable to process name and namespace templates", "source", e.source, "param", obj)
 			return
 		}
-		if sourceName == evt.Object.GetName() && sourceNamespace == evt.Object.GetNamespace() {
+		if sourceName == source.GetName() && sourceNamespace == source.GetNamespace() {
 			e.log.V(1).Info("enqueing", "request", reconcile.Request{
 				NamespacedName: types.NamespacedName{
 					Name:      e.target.Name,
@@ -177,108 +229,34 @@ func (e *enqueueRequestForPatch) Create(ctx context.Context, evt event.CreateEve
 		return
 	}
 
-	if multiple {
-		objs, err := e.target.GetReferencedObjects(ctx)
-		if err != nil {
-			e.log.Error(err, "Unable to get referenced objects", "target", e.target)
-			return
-		}
-		for i := range objs {
-			sourceName, sourceNamespace, err := e.source.GetNameAndNamespace(ctx, &objs[i])
-			if err != nil {
-				e.log.Error(err, "Unable to process name and namespace templates", "source", e.source, "param", objs[i])
-				return
-			}
-			if sourceName == evt.Object.GetName() && sourceNamespace == evt.Object.GetNamespace() {
-				e.log.V(1).Info("enqueing", "request", reconcile.Request{
-					NamespacedName: types.NamespacedName{
-						Name:      objs[i].GetName(),
-						Namespace: objs[i].GetNamespace(),
-					},
-				})
-				q.Add(reconcile.Request{
-					NamespacedName: types.NamespacedName{
-						Name:      objs[i].GetName(),
-						Namespace: objs[i].GetNamespace(),
-					},
-				})
-			}
-		}
-	}
-
-}
-
-// Update implements EventHandler
-func (e *enqueueRequestForPatch) Update(ctx context.Context, evt event.UpdateEvent, q workqueue.RateLimitingInterface) {
-	//to see if this event is relevant and we have to do the following:
-	// 1. see if the target is single or multiple
-	// 2. if single just see if it matches, the pass the event.
-	// 3. if multiple see which macth and then pass the event
-	// TODO  this could be optmized to see if the change affected the needed jsonpath
-	e.log.V(1).Info("enqueue update", "for", evt.ObjectNew)
-	ctx = context.WithValue(ctx, "discoveryClient", e.discoveryClient)
-	ctx = context.WithValue(ctx, "restConfig", e.restConfig)
-	ctx = log.IntoContext(ctx, e.log)
-	multiple, _, err := e.target.IsSelectingMultipleInstances(ctx)
+	objs, err := e.target.GetReferencedObjects(ctx)
 	if err != nil {
-		e.log.Error(err, "Unable to determine if target resolves to multiple instance", "target", e.target)
+		e.log.Error(err, "Unable to get referenced objects", "target", e.target)
 		return
 	}
-
-	if !multiple {
-		obj, err := e.target.GetReferencedObject(ctx)
-		if err != nil {
-			e.log.Error(err, "Unable to get referenced object", "target", e.target)
-			return
-		}
-		sourceName, sourceNamespace, err := e.source.GetNameAndNamespace(ctx, obj)
+	for i := range objs {
+		sourceName, sourceNamespace, err := e.source.GetNameAndNamespace(ctx, &objs[i])
 		if err != nil {
-			e.log.Error(err, "Unable to process name and namespace templates", "source", e.source, "param", obj)
+			e.log.Error(err, "Unable to process name and namespace templates", "source", e.source, "param", objs[i])
 			return
 		}
-		if sourceName == evt.ObjectNew.GetName() && sourceNamespace == evt.ObjectNew.GetNamespace() {
+		if sourceName == source.GetName() && sourceNamespace == source.GetNamespace() {
+			e.log.V(1).Info("enqueing", "request", reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      objs[i].GetName(),
+					Namespace: objs[i].GetNamespace(),
+				},
+			})
 			q.Add(reconcile.Request{
 				NamespacedName: types.NamespacedName{
-					Name:      e.target.Name,
-					Namespace: e.target.Namespace,
+					Name:      objs[i].GetName(),
+					Namespace: objs[i].GetNamespace(),
 				},
 			})
 		}
-		return
-	}
-
-	if multiple {
-		objs, err := e.target.GetReferencedObjects(ctx)
-		if err != nil {
-			e.log.Error(err, "Unable to get referenced objects", "target", e.target)
-			return
-		}
-		for i := range objs {
-			sourceName, sourceNamespace, err := e.source.GetNameAndNamespace(ctx, &objs[i])
-			if err != nil {
-				e.log.Error(err, "Unable to process name and namespace templates", "source", e.source, "param", objs[i])
-				return
-			}
-			if sourceName == evt.ObjectNew.GetName() && sourceNamespace == evt.ObjectNew.GetNamespace() {
-				q.Add(reconcile.Request{
-					NamespacedName: types.NamespacedName{
-						Name:      objs[i].GetName(),
-						Namespace: objs[i].GetNamespace(),
-					},
-				})
-			}
-		}
 	}
 }
 
-// Delete implements EventHandler
-func (e *enqueueRequestForPatch) Delete(ctx context.Context, evt event.DeleteEvent, q workqueue.RateLimitingInterface) {
-}
-
-// Generic implements EventHandler
-func (e *enqueueRequestForPatch) Generic(ctx context.Context, evt event.GenericEvent, q workqueue.RateLimitingInterface) {
-}
-
 type sourceReferenceModifiedPredicate struct {
 	source     *utilsapi.SourceObjectReference
 	target     *utilsapi.TargetObjectReference
@@ -333,8 +311,9 @@ func (p *sourceReferenceModifiedPredicate) isRelevant(obj client.Object) bool {
 }
 
 func (p *sourceReferenceModifiedPredicate) Delete(e event.DeleteEvent) bool {
-	// we ignore Delete events because if we loosed references there is no point in trying to recompute the patch
-	return false
+	// the patch cannot be recomputed without the source, but the targets must be told that they lost it
+	p.log.V(1).Info("filter delete", "for", e.Object)
+	return p.isRelevant(e.Object)
 }
 
 func (p *sourceReferenceModifiedPredicate) Generic(e event.GenericEvent) bool {
@@ -380,8 +359,17 @@ func (p *targetReferenceModifiedPredicate) Create(e event.CreateEvent) bool {
 }
 
 func (p *targetReferenceModifiedPredicate) Delete(e event.DeleteEvent) bool {
-	// we ignore Delete events because if we loosed references there is no point in trying to recompute the patch
-	return false
+	// deleted targets are reconciled to remove their status
+	p.log.V(1).Info("filter delete", "for", e.Object)
+	ctx := context.TODO()
+	ctrl.LoggerInto(ctx, p.log)
+	ctx = context.WithValue(ctx, "restConfig", p.restConfig)
+	selected, err := p.TargetObjectReference.Selects(ctx, e.Object)
+	if err != nil {
+		p.log.Error(err, "unable to determine if current object is selected", "object", e.Object, "target", p.TargetObjectReference)
+		return false
+	}
+	return selected
 }
 
 func (p *targetReferenceModifiedPredicate) Generic(e event.GenericEvent) bool {
@@ -436,58 +424,144 @@ func compareSourceObjects(ctx context.Context, sourceObjectReference *utilsapi.S
 
 // Reconcile method
 func (lpr *LockedPatchReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	start := time.Now()
+	result, err := lpr.reconcile(ctx, request)
+	observeReconcile(lpr.metricLabels, start, err)
+	return result, err
+}
+
+func (lpr *LockedPatchReconciler) reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	//gather all needed the objects
 	lpr.log.V(1).Info("reconcile", "for", request)
 	ctx = context.WithValue(ctx, "restConfig", lpr.GetRestConfig())
 	ctx = log.IntoContext(ctx, lpr.log)
 	targetObj, err := lpr.patch.TargetObjectRef.GetReferencedObjectWithName(ctx, request.NamespacedName)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// the target is gone, there is nothing to patch and nothing to report
+			lpr.deleteStatus(request.NamespacedName.String())
+			lpr.fightDetector.forget(request.NamespacedName.String())
+			return reconcile.Result{}, nil
+		}
 		lpr.log.Error(err, "unable to retrieve", "target", lpr.patch.TargetObjectRef)
 		return lpr.manageErrorNoTarget(err)
 	}
-	// the first object is always the target object
-	sourceMaps := []interface{}{targetObj.UnstructuredContent()}
-	for i := range lpr.patch.SourceObjectRefs {
-		sourceObj, err := lpr.patch.SourceObjectRefs[i].GetReferencedObject(ctx, targetObj)
-		if err != nil {
-			lpr.log.Error(err, "unable to retrieve", "sourceObjectRef", lpr.patch.SourceObjectRefs[i])
-			return lpr.manageError(targetObj, err)
+	patch, err := renderPatch(ctx, &lpr.patch, targetObj)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return lpr.manageSourceMissing(ctx, targetObj, err)
 		}
-		sourceMap, err := getSubMapFromObject(ctx, sourceObj, lpr.patch.SourceObjectRefs[i].FieldPath)
+		return lpr.manageError(targetObj, err)
+	}
+
+	if lpr.patch.GetMode() == utilsapi.EnforcementModeAudit {
+		return lpr.audit(ctx, targetObj, patch)
+	}
+
+	if backoff := lpr.fightDetector.getBackoff(apis.GetKeyShort(targetObj), time.Now()); backoff > 0 {
+		lpr.log.V(1).Info("backing off from contested", "target", apis.GetKeyShort(targetObj), "backoff", backoff)
+		return reconcile.Result{RequeueAfter: backoff}, nil
+	}
+
+	original := targetObj.DeepCopy()
+	appliedPatch := patch
+	if recordsOriginalValues(&lpr.patch) {
+		appliedPatch, err = recordOriginalValues(targetObj, lpr.patch.GetKey(), patch)
 		if err != nil {
-			lpr.log.Error(err, "unable to retrieve", "field", lpr.patch.SourceObjectRefs[i].FieldPath, "from object", sourceObj)
+			lpr.log.Error(err, "unable to record original values of fields patched by", "patch", patch, "on target", targetObj)
 			return lpr.manageError(targetObj, err)
 		}
-		sourceMaps = append(sourceMaps, sourceMap)
 	}
+	err = lpr.GetClient().Patch(ctx, targetObj, appliedPatch)
 
-	//compute the template
-	var b bytes.Buffer
-	err = lpr.patch.Template.Execute(&b, sourceMaps)
 	if err != nil {
-		lpr.log.Error(err, "unable to process ", "template ", lpr.patch.Template, "parameters", sourceMaps)
+		lpr.log.Error(err, "unable to apply ", "patch", patch, "on target", targetObj)
 		return lpr.manageError(targetObj, err)
 	}
 
-	bb, err := yaml.YAMLToJSON(b.Bytes())
-
+	equal, err := isEqualIgnoringPaths(original, targetObj, serverManagedPaths)
 	if err != nil {
-		lpr.log.Error(err, "unable to convert to json", "processed template", b.String())
+		lpr.log.Error(err, "unable to determine if", "object", original, "is equal to object", targetObj)
 		return lpr.manageError(targetObj, err)
 	}
+	if !equal {
+		lpr.recordCorrection(original, targetObj)
+	}
 
-	patch := client.RawPatch(lpr.patch.PatchType, bb)
+	return lpr.manageSuccess(targetObj)
+}
 
-	err = lpr.GetClient().Patch(ctx, targetObj, patch)
+// recordCorrection records that the target has been restored to its patched state, original is the state of the target before the correction.
+// The first successful reconcile of a target only applies the patch, so it is not counted as a correction.
+func (lpr *LockedPatchReconciler) recordCorrection(original *unstructured.Unstructured, target client.Object) {
+	if _, ok := apis.GetCondition(apis.ReconcileSuccess, lpr.GetStatus()[apis.GetKeyShort(target)]); !ok {
+		return
+	}
+	driftCorrectionsTotal.With(lpr.metricLabels).Inc()
+	lpr.fightDetector.recordCorrection(apis.GetKeyShort(target), getLastFieldManager(original, getFieldManager("", lpr.GetRestConfig())), time.Now())
+}
 
+// audit verifies with a server-side dry-run whether the patch would change the target and reports drift, without ever writing to the cluster
+func (lpr *LockedPatchReconciler) audit(ctx context.Context, targetObj *unstructured.Unstructured, patch client.Patch) (reconcile.Result, error) {
+	patched := targetObj.DeepCopy()
+	err := lpr.GetClient().Patch(ctx, patched, patch, client.DryRunAll)
 	if err != nil {
-		lpr.log.Error(err, "unable to apply ", "patch", patch, "on target", targetObj)
+		lpr.log.Error(err, "unable to dry-run ", "patch", patch, "on target", targetObj)
 		return lpr.manageError(targetObj, err)
 	}
-
+	equal, err := isEqualIgnoringPaths(targetObj, patched, serverManagedPaths)
+	if err != nil {
+		lpr.log.Error(err, "unable to determine if", "object", targetObj, "is equal to object", patched)
+		return lpr.manageError(targetObj, err)
+	}
+	if !equal {
+		summary, err := summarizeDrift(targetObj, patched, serverManagedPaths)
+		if err != nil {
+			lpr.log.Error(err, "unable to summarize drift of", "object", targetObj)
+			return lpr.manageError(targetObj, err)
+		}
+		return lpr.manageDrift(targetObj, summary)
+	}
 	return lpr.manageSuccess(targetObj)
 }
 
+// renderPatch computes the patch to be applied to the passed target object, resolving the source objects and processing the patch template
+func renderPatch(ctx context.Context, lockedPatch *lockedpatch.LockedPatch, targetObj *unstructured.Unstructured) (client.Patch, error) {
+	mlog := log.FromContext(ctx)
+	// the first object is always the target object
+	sourceMaps := []interface{}{targetObj.UnstructuredContent()}
+	for i := range lockedPatch.SourceObjectRefs {
+		sourceObj, err := lockedPatch.SourceObjectRefs[i].GetReferencedObject(ctx, targetObj)
+		if err != nil {
+			mlog.Error(err, "unable to retrieve", "sourceObjectRef", lockedPatch.SourceObjectRefs[i])
+			return nil, err
+		}
+		sourceMap, err := getSubMapFromObject(ctx, sourceObj, lockedPatch.SourceObjectRefs[i].FieldPath)
+		if err != nil {
+			mlog.Error(err, "unable to retrieve", "field", lockedPatch.SourceObjectRefs[i].FieldPath, "from object", sourceObj)
+			return nil, err
+		}
+		sourceMaps = append(sourceMaps, sourceMap)
+	}
+
+	//compute the template
+	var b bytes.Buffer
+	err := lockedPatch.Template.Execute(&b, sourceMaps)
+	if err != nil {
+		mlog.Error(err, "unable to process ", "template ", lockedPatch.Template, "parameters", sourceMaps)
+		return nil, err
+	}
+
+	bb, err := yaml.YAMLToJSON(b.Bytes())
+
+	if err != nil {
+		mlog.Error(err, "unable to convert to json", "processed template", b.String())
+		return nil, err
+	}
+
+	return client.RawPatch(lockedPatch.PatchType, bb), nil
+}
+
 // GetKey return the patch no so unique identifier
 func (lpr *LockedPatchReconciler) GetKey() string {
 	return lpr.patch.GetKey()
@@ -553,10 +627,81 @@ func (lpr *LockedPatchReconciler) manageSuccess(target client.Object) (reconcile
 		Status:             metav1.ConditionTrue,
 		ObservedGeneration: target.GetGeneration(),
 	}
+	conditions := apis.RemoveCondition(apis.SourceMissing, apis.RemoveCondition(apis.Drifted, lpr.GetStatus()[apis.GetKeyShort(target)]))
+	conditions, contested := lpr.manageContest(target, apis.AddOrReplaceCondition(condition, conditions))
+	lpr.setStatus(apis.GetKeyShort(target), conditions)
+	if contested {
+		// the Contested condition is removed by a later reconcile, once the corrections stop
+		return reconcile.Result{RequeueAfter: lpr.options.fightWindow}, nil
+	}
+	return reconcile.Result{}, nil
+}
+
+// manageContest adds a Contested condition to the passed conditions of the target if it is contested, otherwise it removes it
+func (lpr *LockedPatchReconciler) manageContest(target client.Object, conditions []metav1.Condition) ([]metav1.Condition, bool) {
+	contested, changedBy := lpr.fightDetector.getContest(apis.GetKeyShort(target), time.Now())
+	if !contested {
+		return apis.RemoveCondition(apis.Contested, conditions), false
+	}
+	if _, ok := apis.GetCondition(apis.Contested, conditions); ok {
+		return conditions, true
+	}
+	message := contestedMessage(changedBy)
+	lpr.log.Info("detected fight", "target", apis.GetKeyShort(target), "message", message)
+	condition := metav1.Condition{
+		Type:               apis.Contested,
+		LastTransitionTime: metav1.Now(),
+		Message:            message,
+		Reason:             apis.ContestedReason,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: target.GetGeneration(),
+	}
+	return apis.AddOrReplaceCondition(condition, conditions), true
+}
+
+// manageSourceMissing records a SourceMissing condition for the target and, if the policy says so, restores the original values of the patched fields.
+// No error is returned, the target is reconciled again when the source is recreated.
+func (lpr *LockedPatchReconciler) manageSourceMissing(ctx context.Context, target *unstructured.Unstructured, err error) (reconcile.Result, error) {
+	if lpr.patch.GetSourceDeletionPolicy() == utilsapi.SourceDeletionPolicyRevert && lpr.patch.GetMode() == utilsapi.EnforcementModeEnforce {
+		reverted, rerr := restoreOriginalValues(ctx, lpr.GetClient(), target, lpr.patch.GetKey())
+		if rerr != nil {
+			return lpr.manageError(target, rerr)
+		}
+		if reverted {
+			lpr.GetRecorder().Event(target, "Normal", apis.SourceMissingReason, "reverted fields patched by "+lpr.patch.GetKey())
+		}
+	}
+	condition := metav1.Condition{
+		Type:               apis.SourceMissing,
+		LastTransitionTime: metav1.Now(),
+		Message:            err.Error(),
+		Reason:             apis.SourceMissingReason,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: target.GetGeneration(),
+	}
 	lpr.setStatus(apis.GetKeyShort(target), apis.AddOrReplaceCondition(condition, lpr.GetStatus()[apis.GetKeyShort(target)]))
 	return reconcile.Result{}, nil
 }
 
+// manageDrift records a Drifted condition for the target and emits an event on it, only when the drift changes
+func (lpr *LockedPatchReconciler) manageDrift(target client.Object, summary string) (reconcile.Result, error) {
+	if current, ok := apis.GetCondition(apis.Drifted, lpr.GetStatus()[apis.GetKeyShort(target)]); ok && current.Message == summary {
+		return reconcile.Result{}, nil
+	}
+	lpr.log.Info("detected drift", "target", apis.GetKeyShort(target), "summary", summary)
+	lpr.GetRecorder().Event(target, "Warning", apis.DriftedReason, "patch "+lpr.patch.GetKey()+" "+summary)
+	condition := metav1.Condition{
+		Type:               apis.Drifted,
+		LastTransitionTime: metav1.Now(),
+		Message:            summary,
+		Reason:             apis.DriftedReason,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: target.GetGeneration(),
+	}
+	lpr.setStatus(apis.GetKeyShort(target), apis.AddOrReplaceCondition(condition, apis.RemoveCondition(apis.ReconcileSuccess, lpr.GetStatus()[apis.GetKeyShort(target)])))
+	return reconcile.Result{}, nil
+}
+
 func (lpr *LockedPatchReconciler) setStatus(key string, conditions []metav1.Condition) {
 	lpr.statusLock.Lock()
 	defer lpr.statusLock.Unlock()
@@ -568,6 +713,21 @@ func (lpr *LockedPatchReconciler) setStatus(key string, conditions []metav1.Cond
 	}
 }
 
+// deleteStatus removes the status of a target that no longer exists
+func (lpr *LockedPatchReconciler) deleteStatus(key string) {
+	lpr.statusLock.Lock()
+	defer lpr.statusLock.Unlock()
+	if _, ok := lpr.status[key]; !ok {
+		return
+	}
+	delete(lpr.status, key)
+	if lpr.statusChange != nil {
+		lpr.statusChange <- event.GenericEvent{
+			Object: lpr.parentObject,
+		}
+	}
+}
+
 // GetStatus returns the status for this reconciler
 func (lpr *LockedPatchReconciler) GetStatus() map[string][]metav1.Condition {
 	lpr.statusLock.Lock()
@@ -0,0 +1,270 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// OriginalValuesAnnotation is the annotation in which the values the fields of a target had before being patched are recorded, for the patches with the Revert removal or source deletion policy.
+// The annotation holds a json object keyed by patch name.
+const OriginalValuesAnnotation = "redhat-cop.io/lockedpatch-original-values"
+
+// originalValues are the values the fields of a target had before a patch was applied, keyed by JSON Pointer. Fields that did not exist are listed in Absent.
+type originalValues struct {
+	Values map[string]interface{} `json:"values,omitempty"`
+	Absent []string               `json:"absent,omitempty"`
+}
+
+func getOriginalValues(obj *unstructured.Unstructured) (map[string]originalValues, error) {
+	result := map[string]originalValues{}
+	value, ok := obj.GetAnnotations()[OriginalValuesAnnotation]
+	if !ok {
+		return result, nil
+	}
+	err := json.Unmarshal([]byte(value), &result)
+	if err != nil {
+		return map[string]originalValues{}, err
+	}
+	return result, nil
+}
+
+// recordOriginalValues returns the passed patch, modified so that it also records in the target annotation the current values of the fields it touches, unless they are already recorded.
+// Lists are treated as a single field.
+func recordOriginalValues(target *unstructured.Unstructured, patchKey string, patch client.Patch) (client.Patch, error) {
+	data, err := patch.Data(target)
+	if err != nil {
+		return nil, err
+	}
+	patchMap := map[string]interface{}{}
+	err = json.Unmarshal(data, &patchMap)
+	if err != nil {
+		return nil, err
+	}
+	allOriginalValues, err := getOriginalValues(target)
+	if err != nil {
+		return nil, err
+	}
+	recorded := allOriginalValues[patchKey]
+	if recorded.Values == nil {
+		recorded.Values = map[string]interface{}{}
+	}
+	absent := map[string]bool{}
+	for _, pointer := range recorded.Absent {
+		absent[pointer] = true
+	}
+	changed := false
+	for _, path := range getPatchedPaths(patchMap, []string{}) {
+		pointer := toJSONPointer(path)
+		if _, ok := recorded.Values[pointer]; ok || absent[pointer] {
+			continue
+		}
+		value, found, err := unstructured.NestedFieldCopy(target.Object, path...)
+		if err != nil || !found {
+			recorded.Absent = append(recorded.Absent, pointer)
+		} else {
+			recorded.Values[pointer] = value
+		}
+		changed = true
+	}
+	if !changed {
+		return patch, nil
+	}
+	allOriginalValues[patchKey] = recorded
+	annotation, err := json.Marshal(allOriginalValues)
+	if err != nil {
+		return nil, err
+	}
+	err = unstructured.SetNestedField(patchMap, string(annotation), "metadata", "annotations", OriginalValuesAnnotation)
+	if err != nil {
+		return nil, err
+	}
+	newData, err := json.Marshal(patchMap)
+	if err != nil {
+		return nil, err
+	}
+	return client.RawPatch(patch.Type(), newData), nil
+}
+
+// getPatchedPaths returns the paths of the fields set by a merge or strategic merge patch, lists and directives are not traversed
+func getPatchedPaths(patch map[string]interface{}, prefix []string) [][]string {
+	result := [][]string{}
+	for key, value := range patch {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		path := append(append([]string{}, prefix...), key)
+		if typed, ok := value.(map[string]interface{}); ok && len(typed) > 0 {
+			result = append(result, getPatchedPaths(typed, path)...)
+			continue
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
+// getRestorePatch returns a merge patch restoring the original values recorded for the patch and removing them from the annotation. It returns false if no values are recorded.
+func getRestorePatch(target *unstructured.Unstructured, patchKey string) (client.Patch, bool, error) {
+	allOriginalValues, err := getOriginalValues(target)
+	if err != nil {
+		return nil, false, err
+	}
+	recorded, ok := allOriginalValues[patchKey]
+	if !ok {
+		return nil, false, nil
+	}
+	patchMap := map[string]interface{}{}
+	for pointer, value := range recorded.Values {
+		setNestedValue(patchMap, fromJSONPointer(pointer), value)
+	}
+	for _, pointer := range recorded.Absent {
+		setNestedValue(patchMap, fromJSONPointer(pointer), nil)
+	}
+	delete(allOriginalValues, patchKey)
+	var annotation interface{}
+	if len(allOriginalValues) > 0 {
+		value, err := json.Marshal(allOriginalValues)
+		if err != nil {
+			return nil, false, err
+		}
+		annotation = string(value)
+	}
+	setNestedValue(patchMap, []string{"metadata", "annotations", OriginalValuesAnnotation}, annotation)
+	data, err := json.Marshal(patchMap)
+	if err != nil {
+		return nil, false, err
+	}
+	return client.RawPatch(types.MergePatchType, data), true, nil
+}
+
+// setNestedValue sets a value in a map, creating the intermediate maps. Unlike unstructured.SetNestedField, it accepts nil and any json value.
+func setNestedValue(obj map[string]interface{}, path []string, value interface{}) {
+	current := obj
+	for _, key := range path[:len(path)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+		current = next
+	}
+	current[path[len(path)-1]] = value
+}
+
+func toJSONPointer(path []string) string {
+	segments := make([]string, len(path))
+	for i := range path {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(path[i], "~", "~0"), "/", "~1")
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func fromJSONPointer(pointer string) []string {
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(segments[i], "~1", "/"), "~0", "~")
+	}
+	return segments
+}
+
+// RevertPatches restores on all of the targets of the passed patches the values the patched fields had before being patched.
+// Only patches with the Revert removal policy are considered. It should be called after the reconcilers of the patches have been stopped.
+func (lrm *LockedResourceManager) RevertPatches(ctx context.Context, patches []lockedpatch.LockedPatch) error {
+	ctx = context.WithValue(ctx, "restConfig", lrm.config)
+	ctx = log.IntoContext(ctx, lrm.log)
+	var c client.Client
+	result := &multierror.Error{}
+	for i := range patches {
+		if patches[i].GetRemovalPolicy() != utilsapi.PatchRemovalPolicyRevert {
+			continue
+		}
+		if c == nil {
+			var err error
+			c, err = client.New(lrm.config, client.Options{})
+			if err != nil {
+				lrm.log.Error(err, "unable to create client")
+				return err
+			}
+		}
+		err := revertPatch(ctx, c, &patches[i])
+		if err != nil {
+			lrm.log.Error(err, "unable to revert", "patch", patches[i].GetKey())
+			result = multierror.Append(result, err)
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+func revertPatch(ctx context.Context, c client.Client, lockedPatch *lockedpatch.LockedPatch) error {
+	targets, err := getPatchTargets(ctx, lockedPatch)
+	if err != nil {
+		return err
+	}
+	for i := range targets {
+		_, err := restoreOriginalValues(ctx, c, &targets[i], lockedPatch.GetKey())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreOriginalValues restores on the target the values recorded for the patch identified by patchKey, it returns false if no values are recorded
+func restoreOriginalValues(ctx context.Context, c client.Client, target *unstructured.Unstructured, patchKey string) (bool, error) {
+	mlog := log.FromContext(ctx)
+	restorePatch, found, err := getRestorePatch(target, patchKey)
+	if err != nil {
+		mlog.Error(err, "unable to compute restore patch for", "target", target)
+		return false, err
+	}
+	if !found {
+		return false, nil
+	}
+	err = c.Patch(ctx, target, restorePatch)
+	if err != nil && !apierrors.IsNotFound(err) {
+		mlog.Error(err, "unable to apply ", "restore patch", restorePatch, "on target", target)
+		return false, err
+	}
+	return true, nil
+}
+
+// recordsOriginalValues returns whether the original values of the fields touched by the patch need to be recorded, because one of its policies reverts them
+func recordsOriginalValues(lockedPatch *lockedpatch.LockedPatch) bool {
+	return lockedPatch.GetRemovalPolicy() == utilsapi.PatchRemovalPolicyRevert || lockedPatch.GetSourceDeletionPolicy() == utilsapi.SourceDeletionPolicyRevert
+}
+
+// getPatchTargets returns the current targets of the passed patch, a target referenced by name that does not exist is not an error
+func getPatchTargets(ctx context.Context, lockedPatch *lockedpatch.LockedPatch) ([]unstructured.Unstructured, error) {
+	mlog := log.FromContext(ctx)
+	multiple, _, err := lockedPatch.TargetObjectRef.IsSelectingMultipleInstances(ctx)
+	if err != nil {
+		mlog.Error(err, "Unable to determine if target resolves to multiple instance", "target", lockedPatch.TargetObjectRef)
+		return nil, err
+	}
+	if multiple {
+		targets, err := lockedPatch.TargetObjectRef.GetReferencedObjects(ctx)
+		if err != nil {
+			mlog.Error(err, "Unable to get referenced objects", "target", lockedPatch.TargetObjectRef)
+			return nil, err
+		}
+		return targets, nil
+	}
+	target, err := lockedPatch.TargetObjectRef.GetReferencedObject(ctx)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return []unstructured.Unstructured{}, nil
+		}
+		mlog.Error(err, "Unable to get referenced object", "target", lockedPatch.TargetObjectRef)
+		return nil, err
+	}
+	return []unstructured.Unstructured{*target}, nil
+}
@@ -0,0 +1,175 @@
+package lockedresourcecontroller
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newPatchTarget(annotations map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": "target", "namespace": "ns"}
+	if annotations != nil {
+		metadata["annotations"] = annotations
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   metadata,
+		"data": map[string]interface{}{
+			"a": "1",
+		},
+	}}
+}
+
+func getPatchMap(t *testing.T, target *unstructured.Unstructured, patch client.Patch) map[string]interface{} {
+	data, err := patch.Data(target)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := map[string]interface{}{}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return result
+}
+
+func TestRecordOriginalValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]interface{}
+		patch       string
+		expected    map[string]originalValues
+		unchanged   bool
+	}{
+		{
+			name:  "existing and absent fields",
+			patch: `{"data":{"a":"2","b":"3"}}`,
+			expected: map[string]originalValues{
+				"patch": {Values: map[string]interface{}{"/data/a": "1"}, Absent: []string{"/data/b"}},
+			},
+		},
+		{
+			name:  "escaped keys",
+			patch: `{"metadata":{"labels":{"example.com/x~y":"v"}}}`,
+			expected: map[string]originalValues{
+				"patch": {Absent: []string{"/metadata/labels/example.com~1x~0y"}},
+			},
+		},
+		{
+			name:  "lists and directives",
+			patch: `{"spec":{"ports":[{"port":80}],"$retainKeys":["ports"]}}`,
+			expected: map[string]originalValues{
+				"patch": {Absent: []string{"/spec/ports"}},
+			},
+		},
+		{
+			name:        "already recorded",
+			annotations: map[string]interface{}{OriginalValuesAnnotation: `{"patch":{"values":{"/data/a":"0"}}}`},
+			patch:       `{"data":{"a":"2"}}`,
+			unchanged:   true,
+		},
+		{
+			name:        "other patches kept",
+			annotations: map[string]interface{}{OriginalValuesAnnotation: `{"other":{"absent":["/data/c"]}}`},
+			patch:       `{"data":{"a":"2"}}`,
+			expected: map[string]originalValues{
+				"other": {Absent: []string{"/data/c"}},
+				"patch": {Values: map[string]interface{}{"/data/a": "1"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := newPatchTarget(test.annotations)
+			patch, err := recordOriginalValues(target, "patch", client.RawPatch(types.MergePatchType, []byte(test.patch)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			patchMap := getPatchMap(t, target, patch)
+			annotation, found, err := unstructured.NestedString(patchMap, "metadata", "annotations", OriginalValuesAnnotation)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.unchanged {
+				if found {
+					t.Errorf("expected the recorded values to be left alone, got %s", annotation)
+				}
+				return
+			}
+			recorded := map[string]originalValues{}
+			err = json.Unmarshal([]byte(annotation), &recorded)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(recorded, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, recorded)
+			}
+		})
+	}
+}
+
+func TestGetRestorePatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]interface{}
+		found       bool
+		expected    map[string]interface{}
+	}{
+		{
+			name: "nothing recorded",
+		},
+		{
+			name:        "other patch recorded",
+			annotations: map[string]interface{}{OriginalValuesAnnotation: `{"other":{"absent":["/data/c"]}}`},
+		},
+		{
+			name:        "last recorded patch",
+			annotations: map[string]interface{}{OriginalValuesAnnotation: `{"patch":{"values":{"/data/a":"0"},"absent":["/metadata/labels/example.com~1x~0y"]}}`},
+			found:       true,
+			expected: map[string]interface{}{
+				"data": map[string]interface{}{"a": "0"},
+				"metadata": map[string]interface{}{
+					"labels":      map[string]interface{}{"example.com/x~y": nil},
+					"annotations": map[string]interface{}{OriginalValuesAnnotation: nil},
+				},
+			},
+		},
+		{
+			name:        "other patches kept",
+			annotations: map[string]interface{}{OriginalValuesAnnotation: `{"other":{"absent":["/data/c"]},"patch":{"absent":["/data/b"]}}`},
+			found:       true,
+			expected: map[string]interface{}{
+				"data": map[string]interface{}{"b": nil},
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{OriginalValuesAnnotation: `{"other":{"absent":["/data/c"]}}`},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := newPatchTarget(test.annotations)
+			patch, found, err := getRestorePatch(target, "patch")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if found != test.found {
+				t.Fatalf("expected found to be %v, got %v", test.found, found)
+			}
+			if !found {
+				return
+			}
+			if patch.Type() != types.MergePatchType {
+				t.Errorf("expected a merge patch, got %s", patch.Type())
+			}
+			if patchMap := getPatchMap(t, target, patch); !reflect.DeepEqual(patchMap, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, patchMap)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/apis"
+	"github.com/redhat-cop/operator-utils/pkg/util/dynamicclient"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"github.com/scylladb/go-set/strset"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// PlanAction is the action that enforcement would take on an object
+type PlanAction string
+
+const (
+	// PlanActionCreate means that the object does not exist and would be created
+	PlanActionCreate PlanAction = "Create"
+	// PlanActionUpdate means that the object exists and would be modified
+	PlanActionUpdate PlanAction = "Update"
+	// PlanActionDelete means that the object is no longer enforced, or has been orphaned and is pruned, and would be deleted
+	PlanActionDelete PlanAction = "Delete"
+	// PlanActionRevert means that the patch is no longer enforced and the fields it patched would be restored to their original values
+	PlanActionRevert PlanAction = "Revert"
+	// PlanActionSkip means that the object would not be written, because it is claimed by another parent or because it exists and is not owned, see Reason
+	PlanActionSkip PlanAction = "Skip"
+	// PlanActionNone means that the object is already in the desired state, or that it is audited and would not be written
+	PlanActionNone PlanAction = "None"
+)
+
+// ResourcePlan describes what enforcement would do to a locked resource
+type ResourcePlan struct {
+	// Key identifies the resource in the <group/version>/<kind>/<namespace>/<name> format
+	Key    string
+	Action PlanAction
+	// Diff is the difference between the current and the desired state of the resource, ignoring the excluded paths. It is empty when nothing would change.
+	Diff string
+	// Reason explains why the resource would be skipped
+	Reason string
+}
+
+// PatchPlan describes what enforcement of a locked patch would do to one of its targets
+type PatchPlan struct {
+	PatchName string
+	// Target identifies the target object in the <group/version>/<kind>/<namespace>/<name> format, it is empty when the patch is skipped
+	Target string
+	Action PlanAction
+	// Diff is the difference between the current state of the target and its state after the patch, or after the revert, as computed by a server-side dry-run. It is empty when nothing would change.
+	Diff string
+	// Reason explains why the patch would be skipped
+	Reason string
+}
+
+// Plan is the set of actions that enforcement would take for a set of locked resources and patches
+type Plan struct {
+	Resources []ResourcePlan
+	Patches   []PatchPlan
+}
+
+// HasChanges returns whether enforcement would modify at least one object
+func (p *Plan) HasChanges() bool {
+	for _, resourcePlan := range p.Resources {
+		if resourcePlan.Action != PlanActionNone && resourcePlan.Action != PlanActionSkip {
+			return true
+		}
+	}
+	for _, patchPlan := range p.Patches {
+		if patchPlan.Action != PlanActionNone && patchPlan.Action != PlanActionSkip {
+			return true
+		}
+	}
+	return false
+}
+
+// serverManagedPaths are the paths set by the API server on every write, they are ignored when comparing objects with the result of a dry-run
+var serverManagedPaths = []string{".metadata.managedFields", ".metadata.resourceVersion", ".metadata.generation"}
+
+// Plan computes what enforcing the passed resources and patches would do, without modifying anything in the cluster.
+// Resources are compared with the current objects as their reconcilers would write them, stamped with the ownership of the parent when pruning is enabled or in AdoptExisting mode.
+// Resources and patches whose claims would be lost to another parent, see WithClaimPriority, and existing objects in AdoptExisting mode that are not owned by the parent are reported with the Skip action.
+// Resources that are currently enforced and would no longer be are reported with the Delete action, unless their deletion policy orphans them, as are the owned objects that would be pruned.
+// Patches that are currently enforced and would no longer be are reported with the Revert action on the targets where their removal policy would restore the original values.
+// Patches are evaluated with a server-side dry-run against each of the current targets.
+// Disabled resources and patches are not reported, audited ones are reported with the None action, since they are never written, and with the diff of the drift.
+// Existing resources in CreateOnly mode are reported with the None action as well.
+func (lrm *LockedResourceManager) Plan(ctx context.Context, resources []lockedresource.LockedResource, patches []lockedpatch.LockedPatch, config *rest.Config) (Plan, error) {
+	ctx = context.WithValue(ctx, "restConfig", config)
+	ctx = log.IntoContext(ctx, lrm.log)
+	o := newOptions(lrm.opts...)
+	ownerKey := lrm.getOwnerKey()
+	plan := Plan{
+		Resources: []ResourcePlan{},
+		Patches:   []PatchPlan{},
+	}
+	conflicts := globalClaimIndex.getConflictsFor(ownerKey, getClaimPriority(o, lrm.parent), getClaims(resources, patches))
+	lostResources, lostPatches := getLostClaims(conflicts)
+	enforcedResources, enforcedPatches := filterLostClaims(resources, patches, conflicts)
+	for i := range resources {
+		if otherParent, ok := lostResources[apis.GetKeyLong(&resources[i].Unstructured)]; ok {
+			plan.Resources = append(plan.Resources, ResourcePlan{
+				Key:    apis.GetKeyLong(&resources[i].Unstructured),
+				Action: PlanActionSkip,
+				Reason: "claimed by " + otherParent + ", which takes precedence",
+			})
+		}
+	}
+	for i := range enforcedResources {
+		if enforcedResources[i].GetMode() == utilsapi.EnforcementModeDisabled {
+			continue
+		}
+		resourcePlan, err := lrm.planResource(ctx, &enforcedResources[i], o)
+		if err != nil {
+			lrm.log.Error(err, "unable to plan", "resource", enforcedResources[i].Unstructured)
+			return Plan{}, err
+		}
+		if enforcedResources[i].GetMode() == utilsapi.EnforcementModeAudit || (enforcedResources[i].GetMode() == utilsapi.EnforcementModeCreateOnly && resourcePlan.Action == PlanActionUpdate) {
+			resourcePlan.Action = PlanActionNone
+		}
+		plan.Resources = append(plan.Resources, resourcePlan)
+	}
+	deletionPlans, err := lrm.planDeletions(ctx, resources, enforcedResources)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan.Resources = append(plan.Resources, deletionPlans...)
+	for i := range patches {
+		if _, ok := lostPatches[patches[i].Name]; ok {
+			plan.Patches = append(plan.Patches, PatchPlan{
+				PatchName: patches[i].GetKey(),
+				Action:    PlanActionSkip,
+				Reason:    "claimed by " + lostPatches[patches[i].Name] + ", which takes precedence",
+			})
+		}
+	}
+	_, removedPatches, _, _ := lrm.IsSamePatches(enforcedPatches)
+	if len(enforcedPatches)+len(removedPatches) == 0 {
+		return plan, nil
+	}
+	c, err := client.New(config, client.Options{})
+	if err != nil {
+		lrm.log.Error(err, "unable to create client")
+		return Plan{}, err
+	}
+	for i := range enforcedPatches {
+		if enforcedPatches[i].GetMode() == utilsapi.EnforcementModeDisabled {
+			continue
+		}
+		patchPlans, err := planPatch(ctx, c, &enforcedPatches[i])
+		if err != nil {
+			lrm.log.Error(err, "unable to plan", "patch", enforcedPatches[i].Name)
+			return Plan{}, err
+		}
+		if enforcedPatches[i].GetMode() == utilsapi.EnforcementModeAudit {
+			for j := range patchPlans {
+				patchPlans[j].Action = PlanActionNone
+			}
+		}
+		plan.Patches = append(plan.Patches, patchPlans...)
+	}
+	for i := range removedPatches {
+		if removedPatches[i].GetRemovalPolicy() != utilsapi.PatchRemovalPolicyRevert {
+			continue
+		}
+		patchPlans, err := planRevert(ctx, c, &removedPatches[i])
+		if err != nil {
+			lrm.log.Error(err, "unable to plan revert of", "patch", removedPatches[i].Name)
+			return Plan{}, err
+		}
+		plan.Patches = append(plan.Patches, patchPlans...)
+	}
+	return plan, nil
+}
+
+// planResource compares the resource, as its reconciler would write it, with the current object
+func (lrm *LockedResourceManager) planResource(ctx context.Context, resource *lockedresource.LockedResource, o options) (ResourcePlan, error) {
+	mlog := log.FromContext(ctx)
+	resourcePlan := ResourcePlan{
+		Key:    apis.GetKeyLong(&resource.Unstructured),
+		Action: PlanActionNone,
+	}
+	stamped := lrm.getStampedResource(*resource)
+	dclient, err := dynamicclient.GetDynamicClientOnUnstructured(ctx, &stamped)
+	if err != nil {
+		mlog.Error(err, "unable to get dynamicClient", "on object", resource.Unstructured)
+		return ResourcePlan{}, err
+	}
+	instance, err := dclient.Get(ctx, resource.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resourcePlan.Action = PlanActionCreate
+			resourcePlan.Diff = diffObjects(&unstructured.Unstructured{Object: map[string]interface{}{}}, &stamped, resource.ExcludedPaths)
+			return resourcePlan, nil
+		}
+		mlog.Error(err, "unable to lookup", "object", resource.Unstructured)
+		return ResourcePlan{}, err
+	}
+	if resource.GetMode() == utilsapi.EnforcementModeAdoptExisting && !isOwnedBy(instance, lrm.getOwnerKey()) {
+		resourcePlan.Action = PlanActionSkip
+		resourcePlan.Reason = "the object exists and is not owned by this parent"
+		return resourcePlan, nil
+	}
+	desired := &stamped
+	excludedPaths := resource.ExcludedPaths
+	if o.serverSideApply {
+		desired, err = applyResource(ctx, dclient, &stamped, o, true)
+		if err != nil {
+			mlog.Error(err, "unable to dry-run apply ", "object", resource.Unstructured, "with field manager", o.fieldManager)
+			return ResourcePlan{}, err
+		}
+		excludedPaths = serverManagedPaths
+	}
+	equal, err := isEqualIgnoringPaths(desired, instance, excludedPaths)
+	if err != nil {
+		mlog.Error(err, "unable to determine if", "object", resource.Unstructured, "is equal to object", instance)
+		return ResourcePlan{}, err
+	}
+	if !equal {
+		resourcePlan.Action = PlanActionUpdate
+		resourcePlan.Diff = diffObjects(instance, desired, excludedPaths)
+	}
+	return resourcePlan, nil
+}
+
+// planDeletions returns the deletions of the resources that are currently enforced and would no longer be, and of the owned objects that would be pruned.
+// resources are all of the passed resources, objects claimed by another parent are still needed, enforcedResources are the ones that would be enforced.
+func (lrm *LockedResourceManager) planDeletions(ctx context.Context, resources []lockedresource.LockedResource, enforcedResources []lockedresource.LockedResource) ([]ResourcePlan, error) {
+	deletionPlans := []ResourcePlan{}
+	deleted := strset.New()
+	_, leftDifference, _, _ := lrm.IsSameResources(enforcedResources)
+	toBeDeleted := getToBeDeletdResources(resources, leftDifference)
+	for i := range toBeDeleted {
+		if !isWritingMode(toBeDeleted[i].GetMode()) || toBeDeleted[i].GetDeletionPolicy() != utilsapi.DeletionPolicyDelete {
+			continue
+		}
+		resourcePlan, found, err := lrm.planDeletion(ctx, &toBeDeleted[i])
+		if err != nil {
+			lrm.log.Error(err, "unable to plan deletion", "resource", toBeDeleted[i].Unstructured)
+			return nil, err
+		}
+		if found {
+			deletionPlans = append(deletionPlans, resourcePlan)
+			deleted.Add(getObjectKey(toBeDeleted[i].GroupVersionKind().GroupKind(), toBeDeleted[i].GetNamespace(), toBeDeleted[i].GetName()))
+		}
+	}
+	prunableObjects, err := lrm.getPrunableObjects(ctx, resources)
+	if err != nil {
+		return nil, err
+	}
+	for i := range prunableObjects {
+		obj := &prunableObjects[i]
+		policies := getStampedPolicies(obj)
+		if policies.GetDeletionPolicy() != utilsapi.DeletionPolicyDelete || deleted.Has(getObjectKey(obj.GroupVersionKind().GroupKind(), obj.GetNamespace(), obj.GetName())) {
+			continue
+		}
+		deletionPlans = append(deletionPlans, ResourcePlan{
+			Key:    apis.GetKeyLong(obj),
+			Action: PlanActionDelete,
+		})
+	}
+	return deletionPlans, nil
+}
+
+// planDeletion returns the deletion of the passed resource, it returns false if the object does not exist or, in AdoptExisting mode, if it has not been adopted, as it would not be deleted
+func (lrm *LockedResourceManager) planDeletion(ctx context.Context, resource *lockedresource.LockedResource) (ResourcePlan, bool, error) {
+	mlog := log.FromContext(ctx)
+	dclient, err := dynamicclient.GetDynamicClientOnUnstructured(ctx, &resource.Unstructured)
+	if err != nil {
+		mlog.Error(err, "unable to get dynamicClient", "on object", resource.Unstructured)
+		return ResourcePlan{}, false, err
+	}
+	instance, err := dclient.Get(ctx, resource.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return ResourcePlan{}, false, nil
+		}
+		mlog.Error(err, "unable to lookup", "object", resource.Unstructured)
+		return ResourcePlan{}, false, err
+	}
+	if resource.GetMode() == utilsapi.EnforcementModeAdoptExisting && instance.GetAnnotations()[OwnerAnnotation] != lrm.getOwnerKey() {
+		return ResourcePlan{}, false, nil
+	}
+	return ResourcePlan{
+		Key:    apis.GetKeyLong(&resource.Unstructured),
+		Action: PlanActionDelete,
+	}, true, nil
+}
+
+// planRevert evaluates with a server-side dry-run the restoration of the original values on the targets of a removed patch, the targets with no recorded values are not reported
+func planRevert(ctx context.Context, c client.Client, lockedPatch *lockedpatch.LockedPatch) ([]PatchPlan, error) {
+	mlog := log.FromContext(ctx)
+	targets, err := getPatchTargets(ctx, lockedPatch)
+	if err != nil {
+		return nil, err
+	}
+	patchPlans := []PatchPlan{}
+	for i := range targets {
+		target := &targets[i]
+		restorePatch, found, err := getRestorePatch(target, lockedPatch.GetKey())
+		if err != nil {
+			mlog.Error(err, "unable to compute restore patch for", "target", target)
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		reverted := target.DeepCopy()
+		err = c.Patch(ctx, reverted, restorePatch, client.DryRunAll)
+		if err != nil {
+			mlog.Error(err, "unable to dry-run ", "restore patch", restorePatch, "on target", target)
+			return nil, err
+		}
+		patchPlans = append(patchPlans, PatchPlan{
+			PatchName: lockedPatch.GetKey(),
+			Target:    apis.GetKeyLong(target),
+			Action:    PlanActionRevert,
+			Diff:      diffObjects(target, reverted, serverManagedPaths),
+		})
+	}
+	return patchPlans, nil
+}
+
+func planPatch(ctx context.Context, c client.Client, lockedPatch *lockedpatch.LockedPatch) ([]PatchPlan, error) {
+	mlog := log.FromContext(ctx)
+	targets, err := getPatchTargets(ctx, lockedPatch)
+	if err != nil {
+		return nil, err
+	}
+	patchPlans := []PatchPlan{}
+	for i := range targets {
+		target := &targets[i]
+		patch, err := renderPatch(ctx, lockedPatch, target)
+		if err != nil {
+			return nil, err
+		}
+		patched := target.DeepCopy()
+		err = c.Patch(ctx, patched, patch, client.DryRunAll)
+		if err != nil {
+			mlog.Error(err, "unable to dry-run ", "patch", patch, "on target", target)
+			return nil, err
+		}
+		patchPlan := PatchPlan{
+			PatchName: lockedPatch.GetKey(),
+			Target:    apis.GetKeyLong(target),
+			Action:    PlanActionNone,
+		}
+		equal, err := isEqualIgnoringPaths(target, patched, serverManagedPaths)
+		if err != nil {
+			mlog.Error(err, "unable to determine if", "object", target, "is equal to object", patched)
+			return nil, err
+		}
+		if !equal {
+			patchPlan.Action = PlanActionUpdate
+			patchPlan.Diff = diffObjects(target, patched, serverManagedPaths)
+		}
+		patchPlans = append(patchPlans, patchPlan)
+	}
+	return patchPlans, nil
+}
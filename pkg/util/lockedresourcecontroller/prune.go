@@ -0,0 +1,157 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	multierror "github.com/hashicorp/go-multierror"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util"
+	"github.com/redhat-cop/operator-utils/pkg/util/dynamicclient"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"github.com/scylladb/go-set/strset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// OwnerLabel is the label with which enforced resources are stamped when pruning is enabled. Its value is a hash of the owner key, as label values are limited in length.
+const OwnerLabel = "redhat-cop.io/locked-resource-owner"
+
+// OwnerAnnotation is the annotation holding the owner key of an enforced resource, in the form <group>/<kind>/<namespace>/<name> of the parent.
+const OwnerAnnotation = "redhat-cop.io/locked-resource-owner"
+
+// AdoptAnnotation is the annotation that, set to "true" on an existing object, allows a locked resource in AdoptExisting mode to take it over
+const AdoptAnnotation = "redhat-cop.io/adopt"
+
+// getObjectKey returns a key identifying an object, such as a parent in the ownership stamps, the version is left out so that the key survives version changes of the object type
+func getObjectKey(groupKind schema.GroupKind, namespace string, name string) string {
+	return groupKind.Group + "/" + groupKind.Kind + "/" + namespace + "/" + name
+}
+
+func getOwnerLabelValue(ownerKey string) string {
+	hash := sha256.Sum224([]byte(ownerKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// getOwnerKey returns the owner key of the parent of this LockedResourceManager
+func (lrm *LockedResourceManager) getOwnerKey() string {
+	return getOwnerKey(newOptions(lrm.opts...), lrm.parent)
+}
+
+// getOwnerKey returns the owner key passed in the options, or the one computed from the type of the parent, which is only known for unstructured parents
+func getOwnerKey(o options, parent client.Object) string {
+	if o.ownerKey != "" {
+		return o.ownerKey
+	}
+	return getObjectKey(parent.GetObjectKind().GroupVersionKind().GroupKind(), parent.GetNamespace(), parent.GetName())
+}
+
+// stampOwnership returns a copy of the passed object with the ownership label and annotations
+func stampOwnership(obj *unstructured.Unstructured, ownerKey string) *unstructured.Unstructured {
+	stamped := obj.DeepCopy()
+	labels := stamped.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[OwnerLabel] = getOwnerLabelValue(ownerKey)
+	stamped.SetLabels(labels)
+	annotations := stamped.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[OwnerAnnotation] = ownerKey
+	stamped.SetAnnotations(annotations)
+	return stamped
+}
+
+// Prune deletes the objects of the prunable kinds that are stamped as owned by the parent of this LockedResourceManager and that are not among the passed resources.
+// The objects are deleted according to the deletion and propagation policies stamped on them.
+// Unlike the deletion of the resources removed from the enforced set, it does not depend on what is known in memory, so it also catches the resources removed while the operator was not running.
+// It does nothing if pruning is not enabled. Objects are looked up in all namespaces, so cluster level list permissions are needed on the prunable kinds.
+func (lrm *LockedResourceManager) Prune(ctx context.Context, resources []lockedresource.LockedResource, config *rest.Config) error {
+	o := newOptions(lrm.opts...)
+	if len(o.prunableKinds) == 0 {
+		return nil
+	}
+	ctx = context.WithValue(ctx, "restConfig", config)
+	ctx = log.IntoContext(ctx, lrm.log)
+	c, err := client.New(config, client.Options{})
+	if err != nil {
+		lrm.log.Error(err, "unable to create client")
+		return err
+	}
+	result := &multierror.Error{}
+	prunableObjects, err := lrm.getPrunableObjects(ctx, resources)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	for i := range prunableObjects {
+		obj := &prunableObjects[i]
+		lrm.log.Info("pruning", "object", obj.GetNamespace()+"/"+obj.GetName(), "gvk", obj.GroupVersionKind())
+		resource := getStampedPolicies(obj)
+		err = deleteResource(ctx, c, &resource, lrm.getOwnerKey())
+		if err != nil {
+			lrm.log.Error(err, "unable to prune", "object", obj)
+			result = multierror.Append(result, err)
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// getPrunableObjects returns the objects of the prunable kinds that are stamped as owned by the parent of this LockedResourceManager and that are not among the passed resources.
+// The objects of the kinds that can be listed are returned even if listing some other kind fails, along with the error.
+func (lrm *LockedResourceManager) getPrunableObjects(ctx context.Context, resources []lockedresource.LockedResource) ([]unstructured.Unstructured, error) {
+	ownerKey := lrm.getOwnerKey()
+	desired := strset.New()
+	for i := range resources {
+		desired.Add(getObjectKey(resources[i].GroupVersionKind().GroupKind(), resources[i].GetNamespace(), resources[i].GetName()))
+	}
+	result := &multierror.Error{}
+	prunableObjects := []unstructured.Unstructured{}
+	for _, gvk := range newOptions(lrm.opts...).prunableKinds {
+		dynamicClient, _, err := dynamicclient.GetDynamicClientForGVK(ctx, gvk)
+		if err != nil {
+			lrm.log.Error(err, "unable to get dynamic client for", "gvk", gvk)
+			result = multierror.Append(result, err)
+			continue
+		}
+		list, err := dynamicClient.List(ctx, metav1.ListOptions{LabelSelector: OwnerLabel + "=" + getOwnerLabelValue(ownerKey)})
+		if err != nil {
+			lrm.log.Error(err, "unable to list owned objects of", "gvk", gvk)
+			result = multierror.Append(result, err)
+			continue
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			// the annotation protects from collisions of the label hash
+			if obj.GetAnnotations()[OwnerAnnotation] != ownerKey || util.IsBeingDeleted(obj) ||
+				desired.Has(getObjectKey(gvk.GroupKind(), obj.GetNamespace(), obj.GetName())) {
+				continue
+			}
+			prunableObjects = append(prunableObjects, *obj)
+		}
+	}
+	return prunableObjects, result.ErrorOrNil()
+}
+
+// getStampedResource returns the resource as it should be written by its reconciler: stamped with the ownership of the parent and with its deletion policies if pruning is enabled,
+// or if the resource is in AdoptExisting mode, which relies on the ownership to recognize the objects it can overwrite.
+// Audited resources are never written, so they are not stamped.
+func (lrm *LockedResourceManager) getStampedResource(resource lockedresource.LockedResource) unstructured.Unstructured {
+	if !isWritingMode(resource.GetMode()) || (len(newOptions(lrm.opts...).prunableKinds) == 0 && resource.GetMode() != utilsapi.EnforcementModeAdoptExisting) {
+		return resource.Unstructured
+	}
+	stamped := stampOwnership(&resource.Unstructured, lrm.getOwnerKey())
+	annotations := stamped.GetAnnotations()
+	// the policies are always stamped, so that the merge patch of the reconciler overwrites the previous ones when they are reset to the default.
+	// An empty propagation policy means the default of the resource type.
+	annotations[DeletionPolicyAnnotation] = string(resource.GetDeletionPolicy())
+	annotations[PropagationPolicyAnnotation] = string(resource.PropagationPolicy)
+	stamped.SetAnnotations(annotations)
+	return *stamped
+}
@@ -3,16 +3,21 @@ package lockedresourcecontroller
 import (
 	"context"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"encoding/json"
 
 	"github.com/go-logr/logr"
 
 	"github.com/nsf/jsondiff"
+	"github.com/prometheus/client_golang/prometheus"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
 	"github.com/redhat-cop/operator-utils/pkg/util"
 	"github.com/redhat-cop/operator-utils/pkg/util/apis"
 	"github.com/redhat-cop/operator-utils/pkg/util/dynamicclient"
+	"github.com/redhat-cop/operator-utils/pkg/util/health"
 	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -21,6 +26,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -38,17 +44,34 @@ import (
 type LockedResourceReconciler struct {
 	Resource     unstructured.Unstructured
 	ExcludePaths []string
+	// Mode determines whether drift is corrected or only reported. Disabled resources should not have a reconciler.
+	Mode utilsapi.EnforcementMode
 	util.ReconcilerBase
 	status         []metav1.Condition
 	statusChange   chan<- event.GenericEvent
 	statusLock     sync.Mutex
 	parentObject   client.Object
 	firstReconcile chan event.GenericEvent
-	log            logr.Logger
+	options        options
+	metricLabels   prometheus.Labels
+	driftHistory   utilsapi.DriftHistory
+	fightDetector  *fightDetector
+	// dependencies are the reconcilers of the resources that must be reconciled successfully before this resource is enforced
+	// lockedResource is the locked resource this reconciler was created for, Resource may additionally carry the ownership stamps
+	lockedResource lockedresource.LockedResource
+	dependencies   []*LockedResourceReconciler
+	// implicitDependencies come from kind ordering, they do not hold back this resource when they are not owned
+	implicitDependencies []*LockedResourceReconciler
+	// lostDependencies describe the references to resources claimed by other parents, this resource is not enforced as long as there are any
+	lostDependencies []string
+	dependenciesLock sync.Mutex
+	log              logr.Logger
+	stoppableController
 }
 
 // NewLockedObjectReconciler returns a new reconcile.Reconciler
-func NewLockedObjectReconciler(mgr manager.Manager, object unstructured.Unstructured, excludePaths []string, statusChange chan<- event.GenericEvent, parentObject client.Object) (*LockedResourceReconciler, error) {
+// The returned reconciler is not started, the LockedResourceManager starts and stops it independently of the manager passed as parameter.
+func NewLockedObjectReconciler(mgr manager.Manager, object unstructured.Unstructured, excludePaths []string, statusChange chan<- event.GenericEvent, parentObject client.Object, opts ...Option) (*LockedResourceReconciler, error) {
 
 	controllername := "resource-reconciler"
 
@@ -61,6 +84,8 @@ func NewLockedObjectReconciler(mgr manager.Manager, object unstructured.Unstruct
 		parentObject:   parentObject,
 		statusLock:     sync.Mutex{},
 		firstReconcile: make(chan event.GenericEvent),
+		options:        newOptions(opts...),
+		metricLabels:   metricLabels(getOwnerKey(newOptions(opts...), parentObject), object.GroupVersionKind().String(), ""),
 		status: []metav1.Condition([]metav1.Condition{{
 			Type:               "Initializing",
 			LastTransitionTime: metav1.Now(),
@@ -70,24 +95,21 @@ func NewLockedObjectReconciler(mgr manager.Manager, object unstructured.Unstruct
 		}}),
 	}
 
-	go func() {
-		reconciler.firstReconcile <- event.GenericEvent{
-			Object: &object,
-		}
-	}()
+	reconciler.fightDetector = newFightDetector(reconciler.options.fightThreshold, reconciler.options.fightWindow, reconciler.options.fightMaxBackoff)
 
-	controller, err := controller.New("controller_locked_object_"+apis.GetKeyLong(&object), mgr, controller.Options{Reconciler: reconciler})
+	controller, err := controller.NewUnmanaged("controller_locked_object_"+apis.GetKeyLong(&object), mgr, reconciler.options.controllerOptions(reconciler))
 	if err != nil {
 		reconciler.log.Error(err, "unable to create new controller", "with reconciler", reconciler)
 		return &LockedResourceReconciler{}, err
 	}
+	reconciler.stoppableController.controller = controller
 
 	gvk := object.GetObjectKind().GroupVersionKind()
 	groupVersion := schema.GroupVersion{Group: gvk.Group, Version: gvk.Version}
 
 	mgr.GetScheme().AddKnownTypes(groupVersion, &object)
 
-	err = controller.Watch(source.Kind(mgr.GetCache(), &object), &handler.EnqueueRequestForObject{}, &resourceModifiedPredicate{
+	err = controller.Watch(newSource(mgr.GetCache(), &object, apis.GetKeyShort(&object), reconciler.options), &handler.EnqueueRequestForObject{}, &resourceModifiedPredicate{
 		name:      object.GetName(),
 		namespace: object.GetNamespace(),
 		lrr:       reconciler,
@@ -108,8 +130,28 @@ func NewLockedObjectReconciler(mgr manager.Manager, object unstructured.Unstruct
 	return reconciler, nil
 }
 
+// start starts the controller of this reconciler and triggers the first reconcile cycle
+func (lor *LockedResourceReconciler) start(ctx context.Context) {
+	ctx = lor.stoppableController.start(ctx, lor.log)
+	go func() {
+		select {
+		case lor.firstReconcile <- event.GenericEvent{
+			Object: &lor.Resource,
+		}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
 // Reconcile contains the reconcile logic for LockedResourceReconciler
 func (lor *LockedResourceReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	start := time.Now()
+	result, err := lor.reconcile(ctx, request)
+	observeReconcile(lor.metricLabels, start, err)
+	return result, err
+}
+
+func (lor *LockedResourceReconciler) reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	lor.log.Info("reconcile called for", "object", apis.GetKeyLong(&lor.Resource), "request", request)
 	ctx = context.WithValue(ctx, "restConfig", lor.GetRestConfig())
 	ctx = log.IntoContext(ctx, lor.log)
@@ -118,6 +160,39 @@ func (lor *LockedResourceReconciler) Reconcile(ctx context.Context, request reco
 		lor.log.Error(err, "unable to get dynamicClient", "on object", lor.Resource)
 		return lor.manageErrorNoInstance(err)
 	}
+	if lor.Mode == utilsapi.EnforcementModeAudit {
+		return lor.audit(ctx, client)
+	}
+	if lost := lor.getLostDependencies(); len(lost) > 0 {
+		lor.log.V(1).Info("depends on resources claimed by other parents", "dependencies", lost)
+		return lor.manageLostDependencies(lost)
+	}
+	if pending := lor.getPendingDependencies(); len(pending) > 0 {
+		lor.log.V(1).Info("waiting for", "dependencies", pending)
+		return lor.manageWaitingForDependencies(pending)
+	}
+	if backoff := lor.fightDetector.getBackoff(apis.GetKeyLong(&lor.Resource), time.Now()); backoff > 0 {
+		lor.log.V(1).Info("backing off from contested", "object", apis.GetKeyLong(&lor.Resource), "backoff", backoff)
+		return reconcile.Result{RequeueAfter: backoff}, nil
+	}
+	if lor.Mode == utilsapi.EnforcementModeCreateOnly || lor.Mode == utilsapi.EnforcementModeAdoptExisting {
+		instance, err := client.Get(ctx, lor.Resource.GetName(), v1.GetOptions{})
+		if err == nil {
+			if lor.Mode == utilsapi.EnforcementModeCreateOnly {
+				lor.log.V(1).Info("create only resource exists, not enforcing it")
+				return lor.manageSuccess(instance)
+			}
+			if !lor.isOwned(instance) {
+				return lor.manageNotOwned(instance)
+			}
+		} else if !apierrors.IsNotFound(err) {
+			lor.log.Error(err, "unable to lookup", "object", lor.Resource)
+			return lor.manageErrorNoInstance(err)
+		}
+	}
+	if lor.options.serverSideApply {
+		return lor.serverSideApply(ctx, client)
+	}
 	instance, err := client.Get(ctx, lor.Resource.GetName(), v1.GetOptions{})
 	if err != nil {
 		if apierrors.IsNotFound(err) {
@@ -127,6 +202,7 @@ func (lor *LockedResourceReconciler) Reconcile(ctx context.Context, request reco
 				lor.log.Error(err, "unable to create or update", "object", lor.Resource)
 				return lor.manageErrorNoInstance(err)
 			}
+			lor.recordCorrection(nil, &lor.Resource, lor.ExcludePaths)
 			return lor.manageSuccessNoInstance()
 		}
 		// Error reading the object - requeue the request.
@@ -159,30 +235,252 @@ func (lor *LockedResourceReconciler) Reconcile(ctx context.Context, request reco
 			lor.log.Error(err, "unable to patch ", "object", instance, "with patch", string(patchBytes))
 			return lor.manageError(instance, err)
 		}
+		lor.recordCorrection(instance, &lor.Resource, lor.ExcludePaths)
 		return lor.manageSuccess(instance)
 	}
 	lor.log.V(1).Info("determined that resources are equal")
 	return lor.manageSuccess(instance)
 }
 
+// serverSideApply applies the locked resource under the configured field manager. The apply creates the resource if it does not exist.
+func (lor *LockedResourceReconciler) serverSideApply(ctx context.Context, client dynamic.ResourceInterface) (reconcile.Result, error) {
+	// the current state is needed to tell whether the apply corrected anything
+	current, err := client.Get(ctx, lor.Resource.GetName(), v1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			lor.log.Error(err, "unable to lookup", "object", lor.Resource)
+			return lor.manageErrorNoInstance(err)
+		}
+		current = nil
+	}
+	instance, err := applyResource(ctx, client, &lor.Resource, lor.options, false)
+	if err != nil {
+		if apierrors.IsConflict(err) {
+			lor.log.Info("server side apply conflicts with other field managers", "object", apis.GetKeyLong(&lor.Resource), "conflicts", err.Error())
+			return lor.manageConflict(err)
+		}
+		lor.log.Error(err, "unable to apply ", "object", lor.Resource, "with field manager", lor.options.fieldManager)
+		return lor.manageErrorNoInstance(err)
+	}
+	if current == nil {
+		lor.recordCorrection(nil, instance, serverManagedPaths)
+	} else {
+		equal, err := isEqualIgnoringPaths(current, instance, serverManagedPaths)
+		if err != nil {
+			lor.log.Error(err, "unable to determine if", "object", current, "is equal to object", instance)
+			return lor.manageError(instance, err)
+		}
+		if !equal {
+			lor.recordCorrection(current, instance, serverManagedPaths)
+		}
+	}
+	return lor.manageSuccess(instance)
+}
+
+// audit compares the resource with its desired state and reports drift, without ever writing to the cluster
+func (lor *LockedResourceReconciler) audit(ctx context.Context, client dynamic.ResourceInterface) (reconcile.Result, error) {
+	instance, err := client.Get(ctx, lor.Resource.GetName(), v1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return lor.manageDrift(nil, "resource does not exist")
+		}
+		lor.log.Error(err, "unable to lookup", "object", lor.Resource)
+		return lor.manageErrorNoInstance(err)
+	}
+	desired := &lor.Resource
+	excludePaths := lor.ExcludePaths
+	if lor.options.serverSideApply {
+		desired, err = applyResource(ctx, client, &lor.Resource, lor.options, true)
+		if err != nil {
+			if apierrors.IsConflict(err) {
+				return lor.manageConflict(err)
+			}
+			lor.log.Error(err, "unable to dry-run apply ", "object", lor.Resource, "with field manager", lor.options.fieldManager)
+			return lor.manageError(instance, err)
+		}
+		excludePaths = serverManagedPaths
+	}
+	equal, err := isEqualIgnoringPaths(desired, instance, excludePaths)
+	if err != nil {
+		lor.log.Error(err, "unable to determine if", "object", lor.Resource, "is equal to object", instance)
+		return lor.manageError(instance, err)
+	}
+	if !equal {
+		summary, err := summarizeDrift(instance, desired, excludePaths)
+		if err != nil {
+			lor.log.Error(err, "unable to summarize drift of", "object", instance)
+			return lor.manageError(instance, err)
+		}
+		return lor.manageDrift(instance, summary)
+	}
+	return lor.manageSuccess(instance)
+}
+
+// isOwned returns whether the passed object can be overwritten by this reconciler, because it is stamped as owned by the same parent or because it carries the AdoptAnnotation
+func (lor *LockedResourceReconciler) isOwned(instance *unstructured.Unstructured) bool {
+	return isOwnedBy(instance, lor.Resource.GetAnnotations()[OwnerAnnotation])
+}
+
+// isOwnedBy returns whether the passed object can be overwritten on behalf of the owner identified by ownerKey, because it is stamped as owned by it or because it carries the AdoptAnnotation
+func isOwnedBy(instance *unstructured.Unstructured, ownerKey string) bool {
+	if instance.GetAnnotations()[AdoptAnnotation] == "true" {
+		return true
+	}
+	return ownerKey != "" && instance.GetAnnotations()[OwnerAnnotation] == ownerKey
+}
+
+// isWritingMode returns whether resources in the passed mode are written to the cluster
+func isWritingMode(mode utilsapi.EnforcementMode) bool {
+	return mode == utilsapi.EnforcementModeEnforce || mode == utilsapi.EnforcementModeCreateOnly || mode == utilsapi.EnforcementModeAdoptExisting
+}
+
+// recordCorrection records that the resource has been restored to its desired state, with a metric, an event on the parent object and an entry in the drift history.
+// current is the state of the resource before the correction, nil if the resource had been deleted.
+// The first successful reconcile only creates or aligns the resource, so it is not counted as a correction.
+func (lor *LockedResourceReconciler) recordCorrection(current *unstructured.Unstructured, desired *unstructured.Unstructured, excludePaths []string) {
+	if _, ok := apis.GetCondition(apis.ReconcileSuccess, lor.GetStatus()); !ok {
+		return
+	}
+	driftCorrectionsTotal.With(lor.metricLabels).Inc()
+	record := utilsapi.DriftRecord{
+		RevertedAt: metav1.Now(),
+		Summary:    "resource was deleted",
+	}
+	if current != nil {
+		summary, err := summarizeDrift(current, desired, excludePaths)
+		if err != nil {
+			lor.log.Error(err, "unable to summarize drift of", "object", current)
+			summary = "unable to summarize drift"
+		}
+		record.Summary = summary
+		record.ChangedBy = getLastFieldManager(current, getFieldManager(lor.options.fieldManager, lor.GetRestConfig()))
+	}
+	message := apis.GetKeyLong(&lor.Resource) + " corrected, " + record.Summary
+	if record.ChangedBy != "" {
+		message += ", last changed by " + record.ChangedBy
+	}
+	recorder := lor.options.parentRecorder
+	if recorder == nil {
+		recorder = lor.GetRecorder()
+	}
+	recorder.Event(lor.parentObject, "Normal", apis.DriftCorrectedReason, message)
+	lor.addDriftRecord(record)
+	lor.fightDetector.recordCorrection(apis.GetKeyLong(&lor.Resource), record.ChangedBy, time.Now())
+}
+
+// setDependencies sets the reconcilers of the resources that must be reconciled successfully before this resource is enforced, and the references to resources claimed by other parents
+func (lor *LockedResourceReconciler) setDependencies(dependencies []*LockedResourceReconciler, implicitDependencies []*LockedResourceReconciler, lostDependencies []string) {
+	lor.dependenciesLock.Lock()
+	defer lor.dependenciesLock.Unlock()
+	lor.dependencies = dependencies
+	lor.implicitDependencies = implicitDependencies
+	lor.lostDependencies = lostDependencies
+}
+
+// getPendingDependencies returns the keys of the dependencies that have not been reconciled successfully yet.
+// Implicit dependencies whose object exists and is not owned by the parent are never reconciled, so they are not waited for.
+func (lor *LockedResourceReconciler) getPendingDependencies() []string {
+	lor.dependenciesLock.Lock()
+	defer lor.dependenciesLock.Unlock()
+	pending := []string{}
+	for _, dependency := range lor.dependencies {
+		if !isReconciled(dependency.GetStatus()) {
+			pending = append(pending, apis.GetKeyLong(&dependency.Resource))
+		}
+	}
+	for _, dependency := range lor.implicitDependencies {
+		if !isReconciled(dependency.GetStatus()) && !isNotOwned(dependency.GetStatus()) {
+			pending = append(pending, apis.GetKeyLong(&dependency.Resource))
+		}
+	}
+	return pending
+}
+
+func (lor *LockedResourceReconciler) getLostDependencies() []string {
+	lor.dependenciesLock.Lock()
+	defer lor.dependenciesLock.Unlock()
+	return lor.lostDependencies
+}
+
+func (lor *LockedResourceReconciler) addDriftRecord(record utilsapi.DriftRecord) {
+	if lor.options.driftHistorySize <= 0 {
+		return
+	}
+	lor.statusLock.Lock()
+	defer lor.statusLock.Unlock()
+	lor.driftHistory = append(lor.driftHistory, record)
+	if len(lor.driftHistory) > lor.options.driftHistorySize {
+		lor.driftHistory = lor.driftHistory[len(lor.driftHistory)-lor.options.driftHistorySize:]
+	}
+}
+
+// GetDriftHistory returns the most recent drift corrections of the resource, oldest first
+func (lor *LockedResourceReconciler) GetDriftHistory() utilsapi.DriftHistory {
+	lor.statusLock.Lock()
+	defer lor.statusLock.Unlock()
+	driftHistory := make(utilsapi.DriftHistory, len(lor.driftHistory))
+	copy(driftHistory, lor.driftHistory)
+	return driftHistory
+}
+
+// applyResource applies the passed object with server-side apply under the configured field manager, optionally as a dry-run
+func applyResource(ctx context.Context, client dynamic.ResourceInterface, obj *unstructured.Unstructured, o options, dryRun bool) (*unstructured.Unstructured, error) {
+	patchBytes, err := json.Marshal(getApplyObject(obj))
+	if err != nil {
+		return nil, err
+	}
+	force := o.forceConflicts
+	patchOptions := metav1.PatchOptions{
+		FieldManager: o.fieldManager,
+		Force:        &force,
+	}
+	if dryRun {
+		patchOptions.DryRun = []string{metav1.DryRunAll}
+	}
+	return client.Patch(ctx, obj.GetName(), types.ApplyPatchType, patchBytes, patchOptions)
+}
+
+// getApplyObject returns a copy of the passed object stripped of the fields that are set by the server and cannot be part of an apply request
+func getApplyObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	applyObj := obj.DeepCopy()
+	unstructured.RemoveNestedField(applyObj.Object, "status")
+	applyObj.SetResourceVersion("")
+	applyObj.SetUID("")
+	applyObj.SetGeneration(0)
+	applyObj.SetSelfLink("")
+	applyObj.SetCreationTimestamp(metav1.Time{})
+	applyObj.SetManagedFields(nil)
+	return applyObj
+}
+
 func (lor *LockedResourceReconciler) isEqual(instance *unstructured.Unstructured) (bool, error) {
-	left, err := lockedresource.FilterOutPaths(&lor.Resource, lor.ExcludePaths)
+	return isEqualIgnoringPaths(&lor.Resource, instance, lor.ExcludePaths)
+}
+
+// isEqualIgnoringPaths compares two objects, ignoring the excluded paths
+func isEqualIgnoringPaths(left *unstructured.Unstructured, right *unstructured.Unstructured, excludePaths []string) (bool, error) {
+	filteredLeft, err := lockedresource.FilterOutPaths(left, excludePaths)
 	if err != nil {
 		return false, err
 	}
-	right, err := lockedresource.FilterOutPaths(instance, lor.ExcludePaths)
+	filteredRight, err := lockedresource.FilterOutPaths(right, excludePaths)
 	if err != nil {
 		return false, err
 	}
-	return reflect.DeepEqual(left, right), nil
+	return reflect.DeepEqual(filteredLeft, filteredRight), nil
 }
 
 func (lor *LockedResourceReconciler) logDiff(instance *unstructured.Unstructured) string {
-	fi, err := lockedresource.FilterOutPaths(instance, lor.ExcludePaths)
+	return diffObjects(instance, &lor.Resource, lor.ExcludePaths)
+}
+
+// diffObjects returns a human readable diff between the current and the desired state of an object, ignoring the excluded paths
+func diffObjects(current *unstructured.Unstructured, desired *unstructured.Unstructured, excludePaths []string) string {
+	fi, err := lockedresource.FilterOutPaths(current, excludePaths)
 	if err != nil {
 		return "unable to log differences"
 	}
-	fr, err := lockedresource.FilterOutPaths(&lor.Resource, lor.ExcludePaths)
+	fr, err := lockedresource.FilterOutPaths(desired, excludePaths)
 	if err != nil {
 		return "unable to log differences"
 	}
@@ -279,6 +577,60 @@ func (lor *LockedResourceReconciler) manageErrorNoInstance(err error) (reconcile
 	return reconcile.Result{}, err
 }
 
+// manageConflict records a Conflict condition for a server side apply that conflicts with other field managers, the resource is no longer reported as reconciled successfully
+func (lor *LockedResourceReconciler) manageConflict(err error) (reconcile.Result, error) {
+	condition := metav1.Condition{
+		Type:               apis.Conflict,
+		LastTransitionTime: metav1.Now(),
+		Message:            err.Error(),
+		Reason:             apis.ConflictReason,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: 0,
+	}
+	lor.setStatus(apis.AddOrReplaceCondition(condition, apis.RemoveCondition(apis.ReconcileSuccess, lor.GetStatus())))
+	return reconcile.Result{}, err
+}
+
+// manageNotOwned records a Conflict condition for an object that exists, but that this reconciler refuses to adopt. A later change to the object, such as the addition of the AdoptAnnotation, triggers a new reconcile.
+func (lor *LockedResourceReconciler) manageNotOwned(instance *unstructured.Unstructured) (reconcile.Result, error) {
+	lor.log.Info("refusing to adopt", "object", apis.GetKeyLong(instance))
+	condition := metav1.Condition{
+		Type:               apis.Conflict,
+		LastTransitionTime: metav1.Now(),
+		Message:            "the object exists and is not owned by this parent, annotate it with " + AdoptAnnotation + "=true to allow its adoption",
+		Reason:             apis.NotOwnedReason,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: instance.GetGeneration(),
+	}
+	if current, ok := apis.GetCondition(apis.Conflict, lor.GetStatus()); !ok || current.Reason != condition.Reason {
+		lor.setStatus(apis.AddOrReplaceCondition(condition, apis.RemoveCondition(apis.ReconcileSuccess, lor.GetStatus())))
+	}
+	return reconcile.Result{}, nil
+}
+
+// manageLostDependencies records a Conflict condition for a resource that depends on resources claimed by other parents.
+// The dependencies are checked again later, they change when the claims are re-evaluated.
+func (lor *LockedResourceReconciler) manageLostDependencies(lost []string) (reconcile.Result, error) {
+	condition := metav1.Condition{
+		Type:               apis.Conflict,
+		LastTransitionTime: metav1.Now(),
+		Message:            "depends on resources claimed by other parents, which take precedence: " + strings.Join(lost, ", "),
+		Reason:             apis.DependencyConflictReason,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: 0,
+	}
+	if current, ok := apis.GetCondition(apis.Conflict, lor.GetStatus()); !ok || current.Reason != condition.Reason || current.Message != condition.Message {
+		lor.setStatus(apis.AddOrReplaceCondition(condition, apis.RemoveCondition(apis.ReconcileSuccess, lor.GetStatus())))
+	}
+	return reconcile.Result{RequeueAfter: dependencyRequeueInterval}, nil
+}
+
+// isNotOwned returns whether the passed conditions report an object that exists and that the reconciler refuses to adopt
+func isNotOwned(conditions []metav1.Condition) bool {
+	conflict, ok := apis.GetCondition(apis.Conflict, conditions)
+	return ok && conflict.Reason == apis.NotOwnedReason
+}
+
 func (lor *LockedResourceReconciler) manageSuccess(instance *unstructured.Unstructured) (reconcile.Result, error) {
 	condition := metav1.Condition{
 		Type:               apis.ReconcileSuccess,
@@ -287,10 +639,124 @@ func (lor *LockedResourceReconciler) manageSuccess(instance *unstructured.Unstru
 		Status:             metav1.ConditionTrue,
 		ObservedGeneration: instance.GetGeneration(),
 	}
-	lor.setStatus(apis.AddOrReplaceCondition(condition, lor.GetStatus()))
+	conditions := apis.AddOrReplaceCondition(condition, apis.RemoveCondition(apis.WaitingForDependencies, apis.RemoveCondition(apis.Drifted, removeResolvedConflicts(lor.GetStatus()))))
+	conditions, contested := lor.manageContest(conditions, instance.GetGeneration())
+	conditions = lor.manageHealth(conditions, instance)
+	lor.setStatus(conditions)
+	if contested {
+		// the Contested condition is removed by a later reconcile, once the corrections stop
+		return reconcile.Result{RequeueAfter: lor.options.fightWindow}, nil
+	}
 	return reconcile.Result{}, nil
 }
 
+// removeResolvedConflicts removes from the passed conditions the Conflict conditions that a successful reconcile resolves: field manager conflicts, objects that were not owned and dependencies that were claimed by other parents
+func removeResolvedConflicts(conditions []metav1.Condition) []metav1.Condition {
+	conditions = apis.RemoveConditionWithReason(apis.Conflict, apis.DependencyConflictReason, conditions)
+	return apis.RemoveConditionWithReason(apis.Conflict, apis.NotOwnedReason, apis.RemoveConditionWithReason(apis.Conflict, apis.ConflictReason, conditions))
+}
+
+// manageContest adds a Contested condition to the passed conditions if the resource is contested, otherwise it removes it
+func (lor *LockedResourceReconciler) manageContest(conditions []metav1.Condition, generation int64) ([]metav1.Condition, bool) {
+	contested, changedBy := lor.fightDetector.getContest(apis.GetKeyLong(&lor.Resource), time.Now())
+	if !contested {
+		return apis.RemoveCondition(apis.Contested, conditions), false
+	}
+	if _, ok := apis.GetCondition(apis.Contested, conditions); ok {
+		return conditions, true
+	}
+	message := contestedMessage(changedBy)
+	lor.log.Info("detected fight", "object", apis.GetKeyLong(&lor.Resource), "message", message)
+	condition := metav1.Condition{
+		Type:               apis.Contested,
+		LastTransitionTime: metav1.Now(),
+		Message:            message,
+		Reason:             apis.ContestedReason,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+	}
+	return apis.AddOrReplaceCondition(condition, conditions), true
+}
+
+// manageHealth adds a Healthy condition with the health of the passed instance to the passed conditions, if health checks are enabled.
+// The transition time is kept as long as the health does not change.
+func (lor *LockedResourceReconciler) manageHealth(conditions []metav1.Condition, instance *unstructured.Unstructured) []metav1.Condition {
+	if !lor.options.healthChecks {
+		return conditions
+	}
+	condition := metav1.Condition{
+		Type:               apis.Healthy,
+		LastTransitionTime: metav1.Now(),
+		ObservedGeneration: instance.GetGeneration(),
+	}
+	result, err := health.GetHealth(instance)
+	if err != nil {
+		lor.log.Error(err, "unable to evaluate health of", "object", apis.GetKeyLong(instance))
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = apis.HealthCheckFailedReason
+		condition.Message = err.Error()
+	} else {
+		condition.Status = metav1.ConditionFalse
+		if result.Status == health.StatusHealthy {
+			condition.Status = metav1.ConditionTrue
+		}
+		condition.Reason = string(result.Status)
+		condition.Message = result.Message
+	}
+	if current, ok := apis.GetCondition(apis.Healthy, conditions); ok && current.Status == condition.Status && current.Reason == condition.Reason {
+		condition.LastTransitionTime = current.LastTransitionTime
+	}
+	return apis.AddOrReplaceCondition(condition, conditions)
+}
+
+func contestedMessage(changedBy string) string {
+	if changedBy == "" {
+		return "repeatedly changed by another actor, corrections are backing off"
+	}
+	return "repeatedly changed by field manager " + changedBy + ", corrections are backing off"
+}
+
+// manageDrift records a Drifted condition and emits an event on the drifted resource, only when the drift changes. instance is nil if the resource does not exist
+func (lor *LockedResourceReconciler) manageDrift(instance *unstructured.Unstructured, summary string) (reconcile.Result, error) {
+	if current, ok := apis.GetCondition(apis.Drifted, lor.GetStatus()); ok && current.Message == summary {
+		return reconcile.Result{}, nil
+	}
+	lor.log.Info("detected drift", "object", apis.GetKeyLong(&lor.Resource), "summary", summary)
+	eventObject := &lor.Resource
+	var generation int64
+	if instance != nil {
+		eventObject = instance
+		generation = instance.GetGeneration()
+	}
+	lor.GetRecorder().Event(eventObject, "Warning", apis.DriftedReason, summary)
+	condition := metav1.Condition{
+		Type:               apis.Drifted,
+		LastTransitionTime: metav1.Now(),
+		Message:            summary,
+		Reason:             apis.DriftedReason,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+	}
+	lor.setStatus(apis.AddOrReplaceCondition(condition, apis.RemoveCondition(apis.ReconcileSuccess, lor.GetStatus())))
+	return reconcile.Result{}, nil
+}
+
+// manageWaitingForDependencies records a WaitingForDependencies condition and checks the dependencies again later
+func (lor *LockedResourceReconciler) manageWaitingForDependencies(pending []string) (reconcile.Result, error) {
+	condition := metav1.Condition{
+		Type:               apis.WaitingForDependencies,
+		LastTransitionTime: metav1.Now(),
+		Message:            "waiting for " + strings.Join(pending, ", "),
+		Reason:             apis.WaitingForDependenciesReason,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: 0,
+	}
+	if current, ok := apis.GetCondition(apis.WaitingForDependencies, lor.GetStatus()); !ok || current.Message != condition.Message {
+		lor.setStatus(apis.AddOrReplaceCondition(condition, lor.GetStatus()))
+	}
+	return reconcile.Result{RequeueAfter: dependencyRequeueInterval}, nil
+}
+
 func (lor *LockedResourceReconciler) manageSuccessNoInstance() (reconcile.Result, error) {
 	condition := metav1.Condition{
 		Type:               apis.ReconcileSuccess,
@@ -299,7 +765,11 @@ func (lor *LockedResourceReconciler) manageSuccessNoInstance() (reconcile.Result
 		Status:             metav1.ConditionTrue,
 		ObservedGeneration: 0,
 	}
-	lor.setStatus(apis.AddOrReplaceCondition(condition, lor.GetStatus()))
+	conditions, contested := lor.manageContest(apis.AddOrReplaceCondition(condition, apis.RemoveCondition(apis.WaitingForDependencies, removeResolvedConflicts(lor.GetStatus()))), 0)
+	lor.setStatus(conditions)
+	if contested {
+		return reconcile.Result{RequeueAfter: lor.options.fightWindow}, nil
+	}
 	return reconcile.Result{}, nil
 }
 
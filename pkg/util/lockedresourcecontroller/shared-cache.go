@@ -0,0 +1,41 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redhat-cop/operator-utils/pkg/util/stoppablemanager"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// sharedCache is a cluster level manager whose cache is shared by all the LockedResourceManagers of an EnforcingReconciler.
+// Only one watch per GVK is opened to the API server, events are routed to the reconcilers by the router.
+type sharedCache struct {
+	stoppableManager *stoppablemanager.StoppableManager
+	router           *eventRouter
+	lock             sync.Mutex
+}
+
+func newSharedCache(config *rest.Config, options manager.Options) (*sharedCache, error) {
+	//diabling metrics
+	options.MetricsBindAddress = "0"
+	options.LeaderElection = false
+	stoppableManager, err := stoppablemanager.NewStoppableManager(config, options)
+	if err != nil {
+		return nil, err
+	}
+	return &sharedCache{
+		stoppableManager: &stoppableManager,
+		router:           newEventRouter(stoppableManager.GetCache()),
+	}, nil
+}
+
+// start starts the shared manager if it is not started yet. The shared manager is never stopped.
+func (sc *sharedCache) start(ctx context.Context) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if !sc.stoppableManager.IsStarted() {
+		sc.stoppableManager.Start(ctx)
+	}
+}
@@ -0,0 +1,46 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// stoppableController runs an unmanaged controller with its own lifecycle, so that it can be started and stopped independently of the manager hosting its cache.
+type stoppableController struct {
+	controller controller.Controller
+	cancel     context.CancelFunc
+	lock       sync.Mutex
+}
+
+// start starts the controller in the background. Starting a started controller is a noop.
+// The controller is stopped when either the passed context is done or stop is called.
+func (sc *stoppableController) start(ctx context.Context, log logr.Logger) context.Context {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if sc.cancel != nil {
+		return ctx
+	}
+	controllerCtx, cancel := context.WithCancel(ctx)
+	sc.cancel = cancel
+	go func() {
+		err := sc.controller.Start(controllerCtx)
+		if err != nil {
+			log.Error(err, "unable to start controller")
+		}
+	}()
+	return controllerCtx
+}
+
+// stop stops the controller. Stopping a stopped controller is a noop.
+func (sc *stoppableController) stop() {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if sc.cancel == nil {
+		return
+	}
+	sc.cancel()
+	sc.cancel = nil
+}
@@ -0,0 +1,120 @@
+package lockedresourcecontroller
+
+import (
+	"context"
+	"errors"
+
+	multierror "github.com/hashicorp/go-multierror"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/discoveryclient"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	"github.com/redhat-cop/operator-utils/pkg/util/templates"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ValidateLockedResources validates the passed locked resources against the cluster identified by config: the excluded paths must be valid json paths, the resource types must be defined,
+// the resources must conform to the openapi schema and namespaced resources must specify a namespace. All the errors are returned aggregated.
+func ValidateLockedResources(ctx context.Context, config *rest.Config, lockedResources []lockedresource.LockedResource) error {
+	mlog := log.FromContext(ctx)
+	ctx = context.WithValue(ctx, "restConfig", config)
+	// validate the unstructured object is conformant to the openapi
+	schemaValidation, err := templates.GetValidationSchema(config)
+	if err != nil {
+		mlog.Error(err, "unable to get openapi schema")
+		return err
+	}
+	result := &multierror.Error{}
+	for _, lockedResource := range lockedResources {
+		err := lockedresource.ValidateJSONPaths(lockedResource.ExcludedPaths)
+		if err != nil {
+			mlog.Error(err, "invalid excluded paths", "unstructured", lockedResource.Unstructured)
+			result = multierror.Append(result, err)
+			continue
+		}
+		defined, err := discoveryclient.IsUnstructuredDefined(ctx, &lockedResource.Unstructured)
+		if err != nil {
+			mlog.Error(err, "unable to validate", "unstructured", lockedResource.Unstructured)
+			result = multierror.Append(result, err)
+			continue
+		}
+		if !defined {
+			result = multierror.Append(result, errors.New("resource type:"+lockedResource.Unstructured.GroupVersionKind().String()+"not defined"))
+			continue
+		}
+		err = templates.ValidateUnstructured(ctx, &lockedResource.Unstructured, schemaValidation)
+		if err != nil {
+			mlog.Error(err, "unable to validate", "unstructured", lockedResource.Unstructured)
+			result = multierror.Append(result, err)
+			continue
+		}
+		namespaced, err := discoveryclient.IsUnstructuredNamespaced(ctx, &lockedResource.Unstructured)
+		if err != nil {
+			mlog.Error(err, "unable to determine if namespaced", "unstructured", lockedResource.Unstructured)
+			result = multierror.Append(result, err)
+			continue
+		}
+		if namespaced && lockedResource.Unstructured.GetNamespace() == "" {
+			err := errors.New("namespaced resources must specify a namespace")
+			mlog.Error(err, "unable to validate", "unstructured", lockedResource.Unstructured)
+			result = multierror.Append(result, err)
+			continue
+		}
+	}
+	if result.ErrorOrNil() != nil {
+		mlog.Error(result, "encountered errors during resources validation")
+		return result
+	}
+	return nil
+}
+
+// ValidateLockedPatches validates the passed locked patches against the cluster identified by config: the mode and the policies must be compatible with the patch type
+// and the types of the source and target objects must be defined. All the errors are returned aggregated.
+func ValidateLockedPatches(ctx context.Context, config *rest.Config, patches []lockedpatch.LockedPatch) error {
+	mlog := log.FromContext(ctx)
+	ctx = context.WithValue(ctx, "restConfig", config)
+	result := &multierror.Error{}
+	for _, lockedPatch := range patches {
+		if lockedPatch.GetMode() == utilsapi.EnforcementModeCreateOnly || lockedPatch.GetMode() == utilsapi.EnforcementModeAdoptExisting {
+			err := errors.New("patch " + lockedPatch.GetKey() + ": the " + string(lockedPatch.GetMode()) + " mode is valid for locked resources only")
+			mlog.Error(err, "unable to validate", "patch", lockedPatch.GetKey())
+			result = multierror.Append(result, err)
+		}
+		if lockedPatch.GetSourceDeletionPolicy() == utilsapi.SourceDeletionPolicyRevert && lockedPatch.PatchType != types.MergePatchType && lockedPatch.PatchType != types.StrategicMergePatchType {
+			err := errors.New("patch " + lockedPatch.GetKey() + ": the Revert source deletion policy requires a merge or strategic merge patch type")
+			mlog.Error(err, "unable to validate", "patch", lockedPatch.GetKey())
+			result = multierror.Append(result, err)
+		}
+		if lockedPatch.GetRemovalPolicy() == utilsapi.PatchRemovalPolicyRevert && lockedPatch.PatchType != types.MergePatchType && lockedPatch.PatchType != types.StrategicMergePatchType {
+			err := errors.New("patch " + lockedPatch.GetKey() + ": the Revert removal policy requires a merge or strategic merge patch type")
+			mlog.Error(err, "unable to validate", "patch", lockedPatch.GetKey())
+			result = multierror.Append(result, err)
+		}
+		targetGVK := schema.FromAPIVersionAndKind(lockedPatch.TargetObjectRef.APIVersion, lockedPatch.TargetObjectRef.Kind)
+		GVKs := []schema.GroupVersionKind{}
+		for i := range lockedPatch.SourceObjectRefs {
+			GVKs = append(GVKs, schema.FromAPIVersionAndKind(lockedPatch.SourceObjectRefs[i].APIVersion, lockedPatch.SourceObjectRefs[i].Kind))
+		}
+		GVKs = append(GVKs, targetGVK)
+		for i := range GVKs {
+			defined, err := discoveryclient.IsGVKDefined(ctx, GVKs[i])
+			if err != nil {
+				mlog.Error(err, "undefined resource in this cluster", "gvk", GVKs[i])
+				result = multierror.Append(result, err)
+				continue
+			}
+			if !defined {
+				result = multierror.Append(result, errors.New("resource type:"+GVKs[i].String()+"not defined"))
+				continue
+			}
+		}
+	}
+	if result.ErrorOrNil() != nil {
+		mlog.Error(result, "encountered errors during patch validation")
+		return result
+	}
+	return nil
+}
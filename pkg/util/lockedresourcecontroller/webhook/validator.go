@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	multierror "github.com/hashicorp/go-multierror"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedpatch"
+	"github.com/redhat-cop/operator-utils/pkg/util/lockedresourcecontroller/lockedresource"
+	utilstemplates "github.com/redhat-cop/operator-utils/pkg/util/templates"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Validator runs at admission the validations that the LockedResourceManager runs when it is asked to enforce a set of resources and patches, so that invalid specs are rejected instead of being discovered at reconcile time.
+// Validation errors are not returned one at a time, all of them are aggregated in the returned error.
+type Validator struct {
+	config *rest.Config
+	log    logr.Logger
+}
+
+// NewValidator returns a Validator that validates against the cluster identified by config
+func NewValidator(config *rest.Config, log logr.Logger) *Validator {
+	return &Validator{
+		config: config,
+		log:    log,
+	}
+}
+
+// ValidateResources validates the passed locked resources: the manifests must be parsable, conform to the openapi schema and specify a namespace if the resource is namespaced
+func (v *Validator) ValidateResources(ctx context.Context, resources []utilsapi.LockedResource) error {
+	result := &multierror.Error{}
+	lockedResources := []lockedresource.LockedResource{}
+	for i := range resources {
+		parsed, err := lockedresource.GetLockedResources(resources[i : i+1])
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("resource %d: %w", i, err))
+			continue
+		}
+		lockedResources = append(lockedResources, parsed...)
+	}
+	err := lockedresourcecontroller.ValidateLockedResources(log.IntoContext(ctx, v.log), v.config, lockedResources)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result.ErrorOrNil()
+}
+
+// ValidateResourceTemplates dry-runs the passed templates with params, which should be what the templates are processed with when they are enforced, and validates the resulting resources as ValidateResources does.
+// library, which can be nil, holds the named templates the templates can include, see lockedresource.GetTemplateLibrary.
+func (v *Validator) ValidateResourceTemplates(ctx context.Context, templates []utilsapi.LockedResourceTemplate, library *utilstemplates.Library, params interface{}) error {
+	result := &multierror.Error{}
+	lockedResources, err := lockedresource.RenderLockedResourceTemplates(templates, v.config, library, params)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	err = lockedresourcecontroller.ValidateLockedResources(log.IntoContext(ctx, v.log), v.config, lockedResources)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result.ErrorOrNil()
+}
+
+// ValidatePatches validates the passed patches: the templates must be parsable, the patch type must be compatible with the policies and with the target and the source and target types must be defined.
+// The templates are not processed, as their parameters are the source objects, which may legitimately not exist yet.
+func (v *Validator) ValidatePatches(ctx context.Context, patches map[string]utilsapi.PatchSpec) error {
+	result := &multierror.Error{}
+	keys := []string{}
+	for key := range patches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lockedPatches := []lockedpatch.LockedPatch{}
+	for _, key := range keys {
+		parsed, err := lockedpatch.GetLockedPatches(map[string]utilsapi.PatchSpec{key: patches[key]}, v.config, v.log)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("patch %s: %w", key, err))
+			continue
+		}
+		lockedPatches = append(lockedPatches, parsed...)
+	}
+	err := lockedresourcecontroller.ValidateLockedPatches(log.IntoContext(ctx, v.log), v.config, lockedPatches)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result.ErrorOrNil()
+}
+
+// ValidatorFunc turns a function validating an object into an admission.CustomValidator that validates the object on create and update, deletions are always allowed
+type ValidatorFunc func(ctx context.Context, obj runtime.Object) error
+
+var _ admission.CustomValidator = ValidatorFunc(nil)
+
+// ValidateCreate validates the object on create
+func (f ValidatorFunc) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, f(ctx, obj)
+}
+
+// ValidateUpdate validates the new object on update
+func (f ValidatorFunc) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, f(ctx, newObj)
+}
+
+// ValidateDelete allows the deletion
+func (f ValidatorFunc) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
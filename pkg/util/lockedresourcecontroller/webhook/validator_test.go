@@ -0,0 +1,291 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	openapi_v2 "github.com/google/gnostic-models/openapiv2"
+	utilsapi "github.com/redhat-cop/operator-utils/api/v1alpha1"
+	"google.golang.org/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// openAPISchema defines only the ConfigMap, the other types are not validated against the schema
+const openAPISchema = `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "v1"},
+  "paths": {},
+  "definitions": {
+    "io.k8s.api.core.v1.ConfigMap": {
+      "type": "object",
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"type": "object"},
+        "data": {"type": "object", "additionalProperties": {"type": "string"}}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "", "kind": "ConfigMap", "version": "v1"}]
+    }
+  }
+}`
+
+// newTestConfig returns the rest config of a fake apiserver serving the discovery of core/v1 ConfigMaps and Namespaces and of apps/v1 Deployments
+func newTestConfig(t *testing.T) *rest.Config {
+	document, err := openapi_v2.ParseDocument([]byte(openAPISchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	openAPIData, err := proto.Marshal(document)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	responses := map[string]interface{}{
+		"/api": &metav1.APIVersions{Versions: []string{"v1"}},
+		"/apis": &metav1.APIGroupList{Groups: []metav1.APIGroup{{
+			Name:             "apps",
+			Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "apps/v1", Version: "v1"}},
+			PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"},
+		}}},
+		"/api/v1": &metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+			{Name: "namespaces", Kind: "Namespace", Verbs: metav1.Verbs{"get", "list"}},
+		}},
+		"/apis/apps/v1": &metav1.APIResourceList{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/openapi/v2" {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(openAPIData)
+			return
+		}
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return &rest.Config{Host: server.URL}
+}
+
+func newResource(object string) utilsapi.LockedResource {
+	return utilsapi.LockedResource{Object: runtime.RawExtension{Raw: []byte(object)}}
+}
+
+// checkErrors checks that err contains all the expected messages, or that it is nil if none is expected
+func checkErrors(t *testing.T, err error, expected []string) {
+	if len(expected) == 0 {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("expected errors %v, got nil", expected)
+	}
+	for _, message := range expected {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("expected error %q to contain %q", err.Error(), message)
+		}
+	}
+}
+
+func TestValidateResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []utilsapi.LockedResource
+		expected  []string
+	}{
+		{
+			name: "valid",
+			resources: []utilsapi.LockedResource{
+				newResource(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"ns"}}`),
+				newResource(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"config","namespace":"ns"},"data":{"key":"value"}}`),
+			},
+		},
+		{
+			name:      "unparsable",
+			resources: []utilsapi.LockedResource{newResource(`{"apiVersion":`)},
+			expected:  []string{"resource 0:"},
+		},
+		{
+			name: "invalid excluded paths",
+			resources: []utilsapi.LockedResource{{
+				Object:        runtime.RawExtension{Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"config","namespace":"ns"}}`)},
+				ExcludedPaths: []string{".data["},
+			}},
+			expected: []string{".data["},
+		},
+		{
+			name:      "undefined type",
+			resources: []utilsapi.LockedResource{newResource(`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"widget"}}`)},
+			expected:  []string{"example.com/v1, Kind=Widget"},
+		},
+		{
+			name:      "schema violation",
+			resources: []utilsapi.LockedResource{newResource(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"config","namespace":"ns"},"spec":{"key":"value"}}`)},
+			expected:  []string{"unknown field \"spec\""},
+		},
+		{
+			name:      "missing namespace",
+			resources: []utilsapi.LockedResource{newResource(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"app"}}`)},
+			expected:  []string{"namespaced resources must specify a namespace"},
+		},
+		{
+			name: "aggregated errors",
+			resources: []utilsapi.LockedResource{
+				newResource(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"config","namespace":"ns"}}`),
+				newResource(`{"apiVersion":`),
+				newResource(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"app"}}`),
+			},
+			expected: []string{"resource 1:", "namespaced resources must specify a namespace"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			validator := NewValidator(newTestConfig(t), ctrl.Log)
+			err := validator.ValidateResources(context.TODO(), test.resources)
+			checkErrors(t, err, test.expected)
+		})
+	}
+}
+
+func TestValidateResourceTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates []utilsapi.LockedResourceTemplate
+		expected  []string
+	}{
+		{
+			name: "valid",
+			templates: []utilsapi.LockedResourceTemplate{
+				{ObjectTemplate: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"{{ .name }}","namespace":"ns"}}`},
+			},
+		},
+		{
+			name: "unparsable template",
+			templates: []utilsapi.LockedResourceTemplate{
+				{ObjectTemplate: `{{ .name `},
+			},
+			expected: []string{"template 0:"},
+		},
+		{
+			name: "invalid rendered resource",
+			templates: []utilsapi.LockedResourceTemplate{
+				{ObjectTemplate: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"{{ .name }}"}}`},
+			},
+			expected: []string{"namespaced resources must specify a namespace"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			validator := NewValidator(newTestConfig(t), ctrl.Log)
+			err := validator.ValidateResourceTemplates(context.TODO(), test.templates, nil, map[string]string{"name": "app"})
+			checkErrors(t, err, test.expected)
+		})
+	}
+}
+
+func newPatchSpec(patchType types.PatchType, targetAPIVersion string, targetKind string) utilsapi.PatchSpec {
+	return utilsapi.PatchSpec{
+		SourceObjectRefs: []utilsapi.SourceObjectReference{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "source"}},
+		TargetObjectRef:  utilsapi.TargetObjectReference{APIVersion: targetAPIVersion, Kind: targetKind, Namespace: "ns", Name: "target"},
+		PatchType:        patchType,
+		PatchTemplate:    `{"data":{"key":"{{ (index . 0).metadata.name }}"}}`,
+	}
+}
+
+func TestValidatePatches(t *testing.T) {
+	createOnly := newPatchSpec(types.MergePatchType, "v1", "ConfigMap")
+	createOnly.Mode = utilsapi.EnforcementModeCreateOnly
+	revertJSONPatch := newPatchSpec(types.JSONPatchType, "v1", "ConfigMap")
+	revertJSONPatch.PatchTemplate = `[{"op":"add","path":"/data/key","value":"value"}]`
+	revertJSONPatch.RemovalPolicy = utilsapi.PatchRemovalPolicyRevert
+	undefinedSource := newPatchSpec(types.MergePatchType, "v1", "ConfigMap")
+	undefinedSource.SourceObjectRefs[0].APIVersion = "example.com/v1"
+	undefinedSource.SourceObjectRefs[0].Kind = "Widget"
+	unparsable := newPatchSpec(types.MergePatchType, "v1", "ConfigMap")
+	unparsable.PatchTemplate = `{{ .name `
+	tests := []struct {
+		name     string
+		patches  map[string]utilsapi.PatchSpec
+		expected []string
+	}{
+		{
+			name: "valid",
+			patches: map[string]utilsapi.PatchSpec{
+				"merge":     newPatchSpec(types.MergePatchType, "v1", "ConfigMap"),
+				"strategic": newPatchSpec(types.StrategicMergePatchType, "apps/v1", "Deployment"),
+			},
+		},
+		{
+			name:     "create only mode",
+			patches:  map[string]utilsapi.PatchSpec{"p": createOnly},
+			expected: []string{"the CreateOnly mode is valid for locked resources only"},
+		},
+		{
+			name:     "revert json patch",
+			patches:  map[string]utilsapi.PatchSpec{"p": revertJSONPatch},
+			expected: []string{"the Revert removal policy requires a merge or strategic merge patch type"},
+		},
+		{
+			name:     "undefined target type",
+			patches:  map[string]utilsapi.PatchSpec{"p": newPatchSpec(types.StrategicMergePatchType, "example.com/v1", "Widget")},
+			expected: []string{"resource type:example.com/v1, Kind=Widget"},
+		},
+		{
+			name:     "undefined source type",
+			patches:  map[string]utilsapi.PatchSpec{"p": undefinedSource},
+			expected: []string{"resource type:example.com/v1, Kind=Widget"},
+		},
+		{
+			name:     "unparsable template",
+			patches:  map[string]utilsapi.PatchSpec{"p": unparsable},
+			expected: []string{"patch p:"},
+		},
+		{
+			name: "aggregated errors",
+			patches: map[string]utilsapi.PatchSpec{
+				"a": unparsable,
+				"b": createOnly,
+				"c": newPatchSpec(types.MergePatchType, "v1", "ConfigMap"),
+			},
+			expected: []string{"patch a:", "the CreateOnly mode is valid for locked resources only"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			validator := NewValidator(newTestConfig(t), ctrl.Log)
+			err := validator.ValidatePatches(context.TODO(), test.patches)
+			checkErrors(t, err, test.expected)
+		})
+	}
+}
+
+func TestValidatorFunc(t *testing.T) {
+	invalid := ValidatorFunc(func(ctx context.Context, obj runtime.Object) error {
+		return errors.New("invalid")
+	})
+	if _, err := invalid.ValidateCreate(context.TODO(), nil); err == nil {
+		t.Error("expected the create to be rejected")
+	}
+	if _, err := invalid.ValidateUpdate(context.TODO(), nil, nil); err == nil {
+		t.Error("expected the update to be rejected")
+	}
+	if _, err := invalid.ValidateDelete(context.TODO(), nil); err != nil {
+		t.Errorf("expected the delete to be allowed, got %v", err)
+	}
+}
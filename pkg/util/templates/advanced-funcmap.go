@@ -55,10 +55,13 @@ func AdvancedTemplateFuncMap(config *rest.Config, logger logr.Logger) template.F
 		"fromJson":      fromJSON,
 		"fromJsonArray": fromJSONArray,
 
-		// A variety of known templating functions that have not been implemented yet
-		"include":  func(string, interface{}) string { return "not implemented" },
-		"tpl":      func(string, interface{}) interface{} { return "not implemented" },
-		"required": func(string, interface{}) (interface{}, error) { return "not implemented", nil },
+		// include and tpl need the template they are executed in, NewTemplate binds them to it
+		"include": func(string, interface{}) (string, error) {
+			return "", errors.New("include is only available in templates created with NewTemplate")
+		},
+		"tpl": func(string, interface{}) (string, error) {
+			return "", errors.New("tpl is only available in templates created with NewTemplate")
+		},
 	}
 
 	for k, v := range extra {
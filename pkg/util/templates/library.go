@@ -0,0 +1,141 @@
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"text/template"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// maxIncludeDepth is the maximum number of nested calls to include and tpl, it stops templates that include themselves before they exhaust the stack
+const maxIncludeDepth = 100
+
+// Library is a set of sources of named templates, declared with define blocks.
+// The templates created by NewTemplate with a Library can invoke its named templates with include and template, so partials can be shared by many templates.
+type Library struct {
+	names   []string
+	sources map[string]string
+}
+
+// NewLibrary returns an empty Library
+func NewLibrary() *Library {
+	return &Library{
+		sources: map[string]string{},
+	}
+}
+
+// Add adds a source of named templates to the library, replacing the source with the same name, which is only used to identify the source in errors.
+// The named templates defined by a source replace the ones with the same name defined by the sources added before.
+// The source is parsed, so that errors are reported here rather than by every template using the library.
+func (l *Library) Add(name string, source string) error {
+	_, err := template.New(name).Funcs(AdvancedTemplateFuncMap(nil, logr.Discard())).Parse(source)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse template library source %s", name)
+	}
+	if _, ok := l.sources[name]; !ok {
+		l.names = append(l.names, name)
+	}
+	l.sources[name] = source
+	return nil
+}
+
+// AddConfigMap adds every data entry of the passed ConfigMap as a source, in the order of the keys. Sources are named <namespace>/<name>/<key>.
+func (l *Library) AddConfigMap(configMap *corev1.ConfigMap) error {
+	keys := make([]string, 0, len(configMap.Data))
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		err := l.Add(configMap.Namespace+"/"+configMap.Name+"/"+key, configMap.Data[key])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Key identifies the content of the library, templates created with libraries with the same key are equivalent. A nil library has an empty key.
+func (l *Library) Key() string {
+	if l == nil {
+		return ""
+	}
+	hash := sha256.New()
+	for _, name := range l.names {
+		hash.Write([]byte(name))
+		hash.Write([]byte{0})
+		hash.Write([]byte(l.sources[name]))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// NewTemplate parses text into a template with the functions of AdvancedTemplateFuncMap, library can be nil.
+// The named templates of library are defined in the template, before text, so that text can replace them. include and tpl are bound to the returned template.
+func NewTemplate(name string, text string, config *rest.Config, logger logr.Logger, library *Library) (*template.Template, error) {
+	tmpl := template.New(name).Funcs(AdvancedTemplateFuncMap(config, logger))
+	if library != nil {
+		for _, sourceName := range library.names {
+			_, err := tmpl.New(sourceName).Parse(library.sources[sourceName])
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to parse template library source %s", sourceName)
+			}
+		}
+	}
+	tmpl, err := tmpl.Parse(text)
+	if err != nil {
+		return nil, newTemplateError(name, nil, err)
+	}
+	return bindIncludeFunctions(tmpl, new(int32)), nil
+}
+
+// bindIncludeFunctions sets the include and tpl functions of tmpl, which need to execute the templates associated with it.
+// depth counts the nested calls being executed, it is shared by concurrent executions, which can only make the limit stricter.
+func bindIncludeFunctions(tmpl *template.Template, depth *int32) *template.Template {
+	enter := func(call string) error {
+		if atomic.AddInt32(depth, 1) > maxIncludeDepth {
+			atomic.AddInt32(depth, -1)
+			return errors.Errorf("%s: more than %d nested calls, the template probably references itself", call, maxIncludeDepth)
+		}
+		return nil
+	}
+	return tmpl.Funcs(template.FuncMap{
+		// include works as template, but its output can be piped to other functions
+		"include": func(name string, data interface{}) (string, error) {
+			err := enter("include " + name)
+			if err != nil {
+				return "", err
+			}
+			defer atomic.AddInt32(depth, -1)
+			var b strings.Builder
+			err = tmpl.ExecuteTemplate(&b, name, data)
+			return b.String(), err
+		},
+		// tpl processes a string as a template, it can use the named templates associated with tmpl
+		"tpl": func(text string, data interface{}) (string, error) {
+			err := enter("tpl")
+			if err != nil {
+				return "", err
+			}
+			defer atomic.AddInt32(depth, -1)
+			clone, err := tmpl.Clone()
+			if err != nil {
+				return "", err
+			}
+			tplTemplate, err := bindIncludeFunctions(clone, depth).New(tmpl.Name()).Parse(text)
+			if err != nil {
+				return "", errors.Wrap(err, "unable to parse tpl text")
+			}
+			var b strings.Builder
+			err = tplTemplate.Execute(&b, data)
+			return b.String(), err
+		},
+	})
+}
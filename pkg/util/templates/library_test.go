@@ -0,0 +1,301 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestLibrary returns a library with the passed sources, added in order, each source is a name followed by its text
+func newTestLibrary(t *testing.T, sources ...[2]string) *Library {
+	library := NewLibrary()
+	for _, source := range sources {
+		err := library.Add(source[0], source[1])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return library
+}
+
+// execute parses text with library and executes it with data
+func execute(text string, library *Library, data interface{}) (string, error) {
+	tmpl, err := NewTemplate("test", text, nil, logr.Discard(), library)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, data)
+	return b.String(), err
+}
+
+func TestNewTemplate(t *testing.T) {
+	library := newTestLibrary(t,
+		[2]string{"names", `{{ define "name" }}{{ .name }}{{ end }}{{ define "fullname" }}{{ include "name" . }}-full{{ end }}`},
+		[2]string{"labels", `{{ define "labels" }}app: {{ include "name" . | upper }}{{ end }}`},
+	)
+	tests := []struct {
+		name     string
+		text     string
+		library  *Library
+		expected string
+	}{
+		{
+			name:     "template action",
+			text:     `{{ template "name" . }}`,
+			library:  library,
+			expected: "app",
+		},
+		{
+			name:     "include",
+			text:     `{{ include "fullname" . }}`,
+			library:  library,
+			expected: "app-full",
+		},
+		{
+			name:     "include piped to other functions",
+			text:     `{{ include "labels" . | quote }}`,
+			library:  library,
+			expected: `"app: APP"`,
+		},
+		{
+			name:     "tpl",
+			text:     `{{ tpl "{{ .name }}-tpl" . }}`,
+			expected: "app-tpl",
+		},
+		{
+			name:     "tpl with library templates",
+			text:     `{{ tpl "{{ include \"fullname\" . }}" . }}`,
+			library:  library,
+			expected: "app-full",
+		},
+		{
+			name:     "include of templates defined in text",
+			text:     `{{ define "local" }}local {{ .name }}{{ end }}{{ include "local" . }}`,
+			expected: "local app",
+		},
+		{
+			name:     "text replaces library templates",
+			text:     `{{ define "name" }}other{{ end }}{{ include "fullname" . }}`,
+			library:  library,
+			expected: "other-full",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := execute(test.text, test.library, map[string]string{"name": "app"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		library  *Library
+		expected string
+	}{
+		{
+			name:     "unknown template",
+			text:     `{{ include "missing" . }}`,
+			expected: `no template "missing"`,
+		},
+		{
+			name:     "recursive include",
+			text:     `{{ define "loop" }}{{ include "loop" . }}{{ end }}{{ include "loop" . }}`,
+			expected: "include loop: more than 100 nested calls",
+		},
+		{
+			name:     "recursive library include",
+			text:     `{{ include "loop" . }}`,
+			library:  newTestLibrary(t, [2]string{"loop", `{{ define "loop" }}{{ include "loop" . }}{{ end }}`}),
+			expected: "include loop: more than 100 nested calls",
+		},
+		{
+			name:     "recursive tpl",
+			text:     `{{ define "loop" }}{{ tpl "{{ include \"loop\" . }}" . }}{{ end }}{{ include "loop" . }}`,
+			expected: "more than 100 nested calls",
+		},
+		{
+			name:     "unparsable tpl text",
+			text:     `{{ tpl "{{ .name " . }}`,
+			expected: "unable to parse tpl text",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := execute(test.text, test.library, map[string]string{"name": "app"})
+			if err == nil {
+				t.Fatalf("expected error %q, got nil", test.expected)
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected error %q to contain %q", err.Error(), test.expected)
+			}
+		})
+	}
+	// the depth is released after each call, so sequential includes are not limited
+	text := strings.Repeat(`{{ include "name" . }}`, maxIncludeDepth+1)
+	result, err := execute(text, newTestLibrary(t, [2]string{"names", `{{ define "name" }}a{{ end }}`}), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != strings.Repeat("a", maxIncludeDepth+1) {
+		t.Errorf("expected %d includes, got %q", maxIncludeDepth+1, result)
+	}
+}
+
+func TestLibraryOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		library  func(t *testing.T) *Library
+		expected string
+	}{
+		{
+			name: "later sources replace earlier ones",
+			library: func(t *testing.T) *Library {
+				return newTestLibrary(t,
+					[2]string{"a", `{{ define "name" }}a{{ end }}`},
+					[2]string{"b", `{{ define "name" }}b{{ end }}`},
+				)
+			},
+			expected: "b",
+		},
+		{
+			name: "replaced sources keep their position",
+			library: func(t *testing.T) *Library {
+				return newTestLibrary(t,
+					[2]string{"a", `{{ define "name" }}a{{ end }}`},
+					[2]string{"b", `{{ define "name" }}b{{ end }}`},
+					[2]string{"a", `{{ define "name" }}new a{{ end }}`},
+				)
+			},
+			expected: "b",
+		},
+		{
+			name: "configmap keys are added in order",
+			library: func(t *testing.T) *Library {
+				library := NewLibrary()
+				err := library.AddConfigMap(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "lib", Namespace: "ns"},
+					Data: map[string]string{
+						"b": `{{ define "name" }}b{{ end }}`,
+						"a": `{{ define "name" }}a{{ end }}`,
+					},
+				})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return library
+			},
+			expected: "b",
+		},
+		{
+			name: "configmaps added later replace earlier sources",
+			library: func(t *testing.T) *Library {
+				library := newTestLibrary(t, [2]string{"z", `{{ define "name" }}z{{ end }}`})
+				err := library.AddConfigMap(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "lib", Namespace: "ns"},
+					Data:       map[string]string{"a": `{{ define "name" }}a{{ end }}`},
+				})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return library
+			},
+			expected: "a",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := execute(`{{ include "name" . }}`, test.library(t), nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestLibraryAddErrors(t *testing.T) {
+	library := NewLibrary()
+	err := library.Add("broken", `{{ define "name" }}`)
+	if err == nil || !strings.Contains(err.Error(), "unable to parse template library source broken") {
+		t.Errorf("expected a parse error for source broken, got %v", err)
+	}
+	err = library.AddConfigMap(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "lib", Namespace: "ns"},
+		Data:       map[string]string{"a": `{{ define "a" }}a{{ end }}`, "b": `{{ .name `},
+	})
+	if err == nil || !strings.Contains(err.Error(), "ns/lib/b") {
+		t.Errorf("expected a parse error for source ns/lib/b, got %v", err)
+	}
+	if expected := newTestLibrary(t, [2]string{"ns/lib/a", `{{ define "a" }}a{{ end }}`}).Key(); library.Key() != expected {
+		t.Error("expected the sources that cannot be parsed not to be added")
+	}
+}
+
+func TestLibraryKey(t *testing.T) {
+	var nilLibrary *Library
+	if key := nilLibrary.Key(); key != "" {
+		t.Errorf("expected a nil library to have an empty key, got %q", key)
+	}
+	tests := []struct {
+		name    string
+		library *Library
+		same    bool
+	}{
+		{
+			name:    "same sources",
+			library: newTestLibrary(t, [2]string{"a", "x"}, [2]string{"b", "y"}),
+			same:    true,
+		},
+		{
+			name:    "same sources replaced",
+			library: newTestLibrary(t, [2]string{"a", "old"}, [2]string{"b", "y"}, [2]string{"a", "x"}),
+			same:    true,
+		},
+		{
+			name:    "other order",
+			library: newTestLibrary(t, [2]string{"b", "y"}, [2]string{"a", "x"}),
+			same:    false,
+		},
+		{
+			name:    "other content",
+			library: newTestLibrary(t, [2]string{"a", "x"}, [2]string{"b", "z"}),
+			same:    false,
+		},
+		{
+			name:    "other boundaries between name and content",
+			library: newTestLibrary(t, [2]string{"a", "x"}, [2]string{"by", ""}),
+			same:    false,
+		},
+		{
+			name:    "empty",
+			library: NewLibrary(),
+			same:    false,
+		},
+	}
+	reference := newTestLibrary(t, [2]string{"a", "x"}, [2]string{"b", "y"})
+	key := reference.Key()
+	if reference.Key() != key {
+		t.Error("expected the key to be stable")
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if same := test.library.Key() == key; same != test.same {
+				t.Errorf("expected same key to be %v, got %v", test.same, same)
+			}
+		})
+	}
+}
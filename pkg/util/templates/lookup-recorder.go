@@ -0,0 +1,96 @@
+package templates
+
+import (
+	"sort"
+	"sync"
+	"text/template"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+// LookupRecord is a call to the lookup function made during a render: it identifies a single object when Name is set, otherwise the list of the objects of a type, in Namespace if set
+type LookupRecord struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// GroupVersionKind returns the GVK of the looked up objects
+func (lr LookupRecord) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(lr.APIVersion, lr.Kind)
+}
+
+// LookupRecorder records the calls to the lookup function made by the templates it is attached to, see WithLookupRecorder, so that the looked up objects can be watched.
+// It is safe for concurrent use.
+type LookupRecorder struct {
+	records map[LookupRecord]struct{}
+	lock    sync.Mutex
+}
+
+// NewLookupRecorder returns an empty LookupRecorder
+func NewLookupRecorder() *LookupRecorder {
+	return &LookupRecorder{
+		records: map[LookupRecord]struct{}{},
+	}
+}
+
+// Record records a lookup, it can be used to watch objects read on behalf of a render by other means than the lookup function, such as the sources of a template library
+func (lr *LookupRecorder) Record(record LookupRecord) {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+	lr.records[record] = struct{}{}
+}
+
+// Records returns the recorded lookups, without duplicates and in a stable order
+func (lr *LookupRecorder) Records() []LookupRecord {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+	records := make([]LookupRecord, 0, len(lr.records))
+	for record := range lr.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].APIVersion != records[j].APIVersion {
+			return records[i].APIVersion < records[j].APIVersion
+		}
+		if records[i].Kind != records[j].Kind {
+			return records[i].Kind < records[j].Kind
+		}
+		if records[i].Namespace != records[j].Namespace {
+			return records[i].Namespace < records[j].Namespace
+		}
+		return records[i].Name < records[j].Name
+	})
+	return records
+}
+
+// NewRecordingLookupFunction returns a lookup function that works as the one returned by NewLookupFunction and records every call in recorder.
+// Calls are recorded before the lookup is made, so objects that are not found are recorded too and their creation can be noticed.
+func NewRecordingLookupFunction(config *rest.Config, logger logr.Logger, recorder *LookupRecorder) lookupFunc {
+	lookup := NewLookupFunction(config, logger)
+	return func(apiversion string, resource string, namespace string, name string) (map[string]interface{}, error) {
+		recorder.Record(LookupRecord{
+			APIVersion: apiversion,
+			Kind:       resource,
+			Namespace:  namespace,
+			Name:       name,
+		})
+		return lookup(apiversion, resource, namespace, name)
+	}
+}
+
+// WithLookupRecorder returns a copy of tmpl whose lookup function records its calls in recorder, tmpl itself is not modified, so it can be a template shared by concurrent renders.
+// tmpl must have been parsed with the functions of AdvancedTemplateFuncMap. include and tpl are bound to the copy, so that the lookups of the included templates are recorded too.
+func WithLookupRecorder(tmpl *template.Template, config *rest.Config, logger logr.Logger, recorder *LookupRecorder) (*template.Template, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	clone.Funcs(template.FuncMap{
+		"lookup": NewRecordingLookupFunction(config, logger, recorder),
+	})
+	return bindIncludeFunctions(clone, new(int32)), nil
+}
@@ -0,0 +1,123 @@
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/yaml"
+)
+
+// documentSeparatorRegexp matches the lines that separate the documents of a YAML stream, they can only be followed by a comment
+var documentSeparatorRegexp = regexp.MustCompile(`^---\s*(#.*)?$`)
+
+// ParseManifests parses a stream of manifests, as rendered by templates: YAML or JSON documents separated by --- lines, each one being an object, an array of objects or a List.
+// The items of Lists are flattened into the returned objects, empty documents are skipped. The lines reported by errors in the YAML are relative to the whole stream.
+func ParseManifests(data []byte) ([]unstructured.Unstructured, error) {
+	objs := []unstructured.Unstructured{}
+	for _, document := range splitDocuments(data) {
+		bb, err := yaml.YAMLToJSON(document.content)
+		if err != nil {
+			return []unstructured.Unstructured{}, &documentError{offset: document.offset, err: err}
+		}
+		// numbers are decoded as int64 when possible, as unstructured objects do
+		var content interface{}
+		err = json.Unmarshal(bb, &content)
+		if err != nil {
+			return []unstructured.Unstructured{}, &documentError{offset: document.offset, err: err}
+		}
+		documentObjs, err := flattenManifest(content)
+		if err != nil {
+			return []unstructured.Unstructured{}, &documentError{offset: document.offset, err: err}
+		}
+		objs = append(objs, documentObjs...)
+	}
+	return objs, nil
+}
+
+// document is a document of a YAML stream, offset is the number of lines of the stream that precede it
+type document struct {
+	content []byte
+	offset  int
+}
+
+func splitDocuments(data []byte) []document {
+	documents := []document{}
+	lines := bytes.Split(data, []byte("\n"))
+	start := 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && !documentSeparatorRegexp.Match(bytes.TrimRight(lines[i], "\r")) {
+			continue
+		}
+		content := bytes.Join(lines[start:i], []byte("\n"))
+		if len(bytes.TrimSpace(content)) > 0 {
+			documents = append(documents, document{
+				content: content,
+				offset:  start,
+			})
+		}
+		start = i + 1
+	}
+	return documents
+}
+
+// flattenManifest returns the objects of a parsed document: nothing for an empty document, the object itself, or the items of an array or of a List, flattened recursively
+func flattenManifest(content interface{}) ([]unstructured.Unstructured, error) {
+	switch typed := content.(type) {
+	case nil:
+		return []unstructured.Unstructured{}, nil
+	case []interface{}:
+		objs := []unstructured.Unstructured{}
+		for i := range typed {
+			itemObjs, err := flattenManifest(typed[i])
+			if err != nil {
+				return []unstructured.Unstructured{}, fmt.Errorf("item %d: %w", i, err)
+			}
+			objs = append(objs, itemObjs...)
+		}
+		return objs, nil
+	case map[string]interface{}:
+		obj := unstructured.Unstructured{Object: typed}
+		if !strings.HasSuffix(obj.GetKind(), "List") || !obj.IsList() {
+			if obj.GetKind() == "" {
+				return []unstructured.Unstructured{}, errors.New("object has no kind")
+			}
+			return []unstructured.Unstructured{obj}, nil
+		}
+		items, _, err := unstructured.NestedSlice(typed, "items")
+		if err != nil {
+			return []unstructured.Unstructured{}, err
+		}
+		objs, err := flattenManifest(items)
+		if err != nil {
+			return []unstructured.Unstructured{}, fmt.Errorf("%s: %w", obj.GetKind(), err)
+		}
+		return objs, nil
+	default:
+		return []unstructured.Unstructured{}, fmt.Errorf("expected an object, found %v", typed)
+	}
+}
+
+// documentError is an error in a document of a YAML stream. The lines reported by the yaml parser are relative to the document, Error reports them relative to the stream.
+type documentError struct {
+	offset int
+	err    error
+}
+
+// Error implements error
+func (e *documentError) Error() string {
+	return yamlLineRegexp.ReplaceAllStringFunc(e.err.Error(), func(match string) string {
+		line, _ := strconv.Atoi(yamlLineRegexp.FindStringSubmatch(match)[1])
+		return "yaml: line " + strconv.Itoa(line+e.offset) + ": "
+	})
+}
+
+// Unwrap returns the underlying error
+func (e *documentError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,158 @@
+package templates
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseManifests(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []string
+	}{
+		{
+			name: "single object",
+			data: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+`,
+			expected: []string{"ConfigMap/a"},
+		},
+		{
+			name: "multiple documents",
+			data: `
+--- # leading separator
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+---
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: b
+---
+`,
+			expected: []string{"ConfigMap/a", "Secret/b"},
+		},
+		{
+			name: "array",
+			data: `
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: a
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: b
+`,
+			expected: []string{"ConfigMap/a", "Secret/b"},
+		},
+		{
+			name:     "json array",
+			data:     `[{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}]`,
+			expected: []string{"ConfigMap/a"},
+		},
+		{
+			name: "list",
+			data: `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: a
+- apiVersion: v1
+  kind: List
+  items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: b
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: c
+`,
+			expected: []string{"ConfigMap/a", "Secret/b", "ServiceAccount/c"},
+		},
+		{
+			name: "empty",
+			data: `
+---
+# nothing here
+---
+`,
+			expected: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs, err := ParseManifests([]byte(test.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			actual := []string{}
+			for i := range objs {
+				actual = append(actual, objs[i].GetKind()+"/"+objs[i].GetName())
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseManifestsIntegers(t *testing.T) {
+	objs, err := ParseManifests([]byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: a\nspec:\n  replicas: 3\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	replicas := objs[0].Object["spec"].(map[string]interface{})["replicas"]
+	if _, ok := replicas.(int64); !ok {
+		t.Errorf("expected replicas to be decoded as int64, got %T", replicas)
+	}
+}
+
+func TestParseManifestsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "line relative to the stream",
+			data:     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: b\n x: : y\n",
+			expected: "yaml: line 9: ",
+		},
+		{
+			name:     "missing kind",
+			data:     "apiVersion: v1\nmetadata:\n  name: a\n",
+			expected: "object has no kind",
+		},
+		{
+			name:     "scalar item",
+			data:     "apiVersion: v1\nkind: List\nitems:\n- a\n",
+			expected: "List: item 0: expected an object",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseManifests([]byte(test.data))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected error containing %q, got %q", test.expected, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// snippetLines is the number of lines of rendered YAML reported by a TemplateError
+const snippetLines = 5
+
+var (
+	// templateLocationRegexp matches the location that text/template prefixes its errors with, the column is missing in parse errors
+	templateLocationRegexp = regexp.MustCompile(`template: ([^\s:]+):(\d+)(?::(\d+))?: `)
+	// yamlLineRegexp matches the line that the yaml parser reports in its errors
+	yamlLineRegexp = regexp.MustCompile(`yaml: line (\d+): `)
+)
+
+// TemplateError is an error rendering a template, located in the template or in the YAML rendered by the template, with a snippet of the rendered YAML.
+type TemplateError struct {
+	// Template is the name of the template where the error occurred, it is the name of a named template when the error occurred in an included template
+	Template string
+	// Line and Column locate the error in the template, counting from 1, they are 0 when unknown
+	Line   int
+	Column int
+	// RenderedLine locates the error in the rendered YAML, it is 0 unless the rendered YAML is malformed
+	RenderedLine int
+	// Snippet is the rendered YAML around the error: the lines around RenderedLine when the rendered YAML is malformed, the last lines rendered before the error otherwise
+	Snippet string
+	// Err is the error returned by the template engine, the yaml parser or the schema validation
+	Err error
+}
+
+// Error implements error
+func (e *TemplateError) Error() string {
+	b := strings.Builder{}
+	b.WriteString("template " + e.Template)
+	if e.Line > 0 {
+		b.WriteString(", line " + strconv.Itoa(e.Line))
+		if e.Column > 0 {
+			b.WriteString(", column " + strconv.Itoa(e.Column))
+		}
+	}
+	if e.RenderedLine > 0 {
+		b.WriteString(", rendered line " + strconv.Itoa(e.RenderedLine))
+	}
+	b.WriteString(": " + templateLocationRegexp.ReplaceAllString(e.Err.Error(), ""))
+	if e.Snippet != "" {
+		b.WriteString("\n" + e.Snippet)
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying error
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// newTemplateError returns the TemplateError of an error returned by the parsing or the execution of the template named name, located at the innermost template location in the error.
+// rendered is what the template had rendered when it failed, nil for parse errors.
+func newTemplateError(name string, rendered []byte, err error) *TemplateError {
+	templateError := &TemplateError{
+		Template: name,
+		Err:      err,
+	}
+	matches := templateLocationRegexp.FindAllStringSubmatch(err.Error(), -1)
+	if len(matches) > 0 {
+		innermost := matches[len(matches)-1]
+		templateError.Template = innermost[1]
+		templateError.Line, _ = strconv.Atoi(innermost[2])
+		// text/template counts columns from 0
+		if column, err := strconv.Atoi(innermost[3]); err == nil {
+			templateError.Column = column + 1
+		}
+	}
+	lines := strings.Split(strings.TrimRight(string(rendered), "\n"), "\n")
+	if len(rendered) > 0 {
+		first := len(lines) - snippetLines
+		if first < 0 {
+			first = 0
+		}
+		templateError.Snippet = formatSnippet(lines, first, len(lines), 0)
+	}
+	return templateError
+}
+
+// newRenderedError returns the TemplateError of an error about the YAML rendered by the template named name, located at the line reported by the yaml parser, if any
+func newRenderedError(name string, rendered []byte, err error) *TemplateError {
+	templateError := &TemplateError{
+		Template: name,
+		Err:      err,
+	}
+	match := yamlLineRegexp.FindStringSubmatch(err.Error())
+	if match == nil {
+		return templateError
+	}
+	templateError.RenderedLine, _ = strconv.Atoi(match[1])
+	lines := strings.Split(string(rendered), "\n")
+	first := templateError.RenderedLine - 1 - snippetLines/2
+	if first < 0 {
+		first = 0
+	}
+	last := first + snippetLines
+	if last > len(lines) {
+		last = len(lines)
+	}
+	templateError.Snippet = formatSnippet(lines, first, last, templateError.RenderedLine)
+	return templateError
+}
+
+// formatSnippet formats lines[first:last] with their line numbers, marking the line numbered marked, if any
+func formatSnippet(lines []string, first int, last int, marked int) string {
+	snippet := []string{}
+	for i := first; i < last; i++ {
+		marker := "  "
+		if i+1 == marked {
+			marker = "> "
+		}
+		snippet = append(snippet, fmt.Sprintf("%s%4d | %s", marker, i+1, lines[i]))
+	}
+	return strings.Join(snippet, "\n")
+}
@@ -0,0 +1,152 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestTemplateErrors(t *testing.T) {
+	library := newTestLibrary(t, [2]string{"lib", "{{ define \"labels\" }}\n  labels:\n    app: {{ .missing.key }}{{ end }}"})
+	tests := []struct {
+		name     string
+		text     string
+		strict   bool
+		expected TemplateError
+		message  string
+	}{
+		{
+			name: "parse error",
+			text: "apiVersion: v1\nkind: {{ .kind \n",
+			expected: TemplateError{
+				Template: "objectTemplate",
+				Line:     3,
+			},
+			message: "template objectTemplate, line 3: unclosed action started at objectTemplate:2",
+		},
+		{
+			name: "exec error",
+			text: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .name }}\ndata:\n  a: {{ fail \"boom\" }}\n",
+			expected: TemplateError{
+				Template: "objectTemplate",
+				Line:     6,
+				Column:   9,
+				Snippet:  "     2 | kind: ConfigMap\n     3 | metadata:\n     4 |   name: n\n     5 | data:\n     6 |   a: ",
+			},
+			message: "template objectTemplate, line 6, column 9: executing \"objectTemplate\" at <fail \"boom\">: error calling fail: boom\n" +
+				"     2 | kind: ConfigMap\n     3 | metadata:\n     4 |   name: n\n     5 | data:\n     6 |   a: ",
+		},
+		{
+			name: "rendered yaml error",
+			text: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .name }}\n data: x\n  b: c\n",
+			expected: TemplateError{
+				Template:     "objectTemplate",
+				RenderedLine: 4,
+				Snippet:      "     2 | kind: ConfigMap\n     3 | metadata:\n>    4 |   name: n\n     5 |  data: x\n     6 |   b: c",
+			},
+			message: "template objectTemplate, rendered line 4: yaml: line 4: did not find expected key\n" +
+				"     2 | kind: ConfigMap\n     3 | metadata:\n>    4 |   name: n\n     5 |  data: x\n     6 |   b: c",
+		},
+		{
+			name: "rendered object error",
+			text: "metadata:\n  name: {{ .name }}\n",
+			expected: TemplateError{
+				Template: "objectTemplate",
+			},
+			message: "template objectTemplate: object has no kind",
+		},
+		{
+			name:   "missing key",
+			text:   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .missing }}\n",
+			strict: true,
+			expected: TemplateError{
+				Template: "objectTemplate",
+				Line:     4,
+				Column:   12,
+				Snippet:  "     1 | apiVersion: v1\n     2 | kind: ConfigMap\n     3 | metadata:\n     4 |   name: ",
+			},
+			message: "template objectTemplate, line 4, column 12: executing \"objectTemplate\" at <.missing>: map has no entry for key \"missing\"\n" +
+				"     1 | apiVersion: v1\n     2 | kind: ConfigMap\n     3 | metadata:\n     4 |   name: ",
+		},
+		{
+			name:   "missing key in an included library template",
+			text:   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .name }}{{ include \"labels\" . }}\n",
+			strict: true,
+			expected: TemplateError{
+				Template: "lib",
+				Line:     3,
+				Column:   21,
+				Snippet:  "     1 | apiVersion: v1\n     2 | kind: ConfigMap\n     3 | metadata:\n     4 |   name: n",
+			},
+			message: "template lib, line 3, column 21: executing \"objectTemplate\" at <include \"labels\" .>: error calling include: executing \"labels\" at <.missing.key>: map has no entry for key \"missing\"\n" +
+				"     1 | apiVersion: v1\n     2 | kind: ConfigMap\n     3 | metadata:\n     4 |   name: n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := NewTemplate("objectTemplate", test.text, nil, logr.Discard(), library)
+			if err == nil {
+				if test.strict {
+					_, err = ProcessTemplateArrayStrict(context.TODO(), map[string]interface{}{"name": "n"}, tmpl, nil)
+				} else {
+					_, err = ProcessTemplateArray(context.TODO(), map[string]interface{}{"name": "n"}, tmpl)
+				}
+			}
+			var templateError *TemplateError
+			if !errors.As(err, &templateError) {
+				t.Fatalf("expected a TemplateError, got %v", err)
+			}
+			actual := *templateError
+			actual.Err = nil
+			if actual != test.expected {
+				t.Errorf("expected %#v, got %#v", test.expected, actual)
+			}
+			if err.Error() != test.message {
+				t.Errorf("expected message %q, got %q", test.message, err.Error())
+			}
+		})
+	}
+}
+
+func TestNewRenderedError(t *testing.T) {
+	rendered := []byte("a: 1\nb: 2\nc: 3\nd: 4\ne: 5\nf: 6\ng: 7")
+	tests := []struct {
+		name            string
+		err             error
+		expectedLine    int
+		expectedSnippet string
+	}{
+		{
+			name:            "first line",
+			err:             errors.New("yaml: line 1: mapping values are not allowed in this context"),
+			expectedLine:    1,
+			expectedSnippet: ">    1 | a: 1\n     2 | b: 2\n     3 | c: 3\n     4 | d: 4\n     5 | e: 5",
+		},
+		{
+			name:            "last line",
+			err:             errors.New("yaml: line 7: could not find expected ':'"),
+			expectedLine:    7,
+			expectedSnippet: "     5 | e: 5\n     6 | f: 6\n>    7 | g: 7",
+		},
+		{
+			name: "no line",
+			err:  errors.New("object has no kind"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			templateError := newRenderedError("objectTemplate", rendered, test.err)
+			if templateError.RenderedLine != test.expectedLine {
+				t.Errorf("expected rendered line %d, got %d", test.expectedLine, templateError.RenderedLine)
+			}
+			if templateError.Snippet != test.expectedSnippet {
+				t.Errorf("expected snippet %q, got %q", test.expectedSnippet, templateError.Snippet)
+			}
+			if !errors.Is(templateError, test.err) {
+				t.Error("expected the TemplateError to wrap the error")
+			}
+		})
+	}
+}
@@ -19,16 +19,18 @@ package templates
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"fmt"
 	"text/template"
 
+	"github.com/redhat-cop/operator-utils/pkg/util/discoveryclient"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"k8s.io/kubectl/pkg/util/openapi"
 	"k8s.io/kubectl/pkg/validation"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/yaml"
 )
 
-// ProcessTemplate processes an initialized Go template with a set of data. It expects one API object to be defined in the template
+// ProcessTemplate processes an initialized Go template with a set of data. It expects one API object to be defined in the template, possibly as the only document of a YAML stream
 // requires a context with log
 func ProcessTemplate(context context.Context, data interface{}, template *template.Template) (*unstructured.Unstructured, error) {
 	log := log.FromContext(context)
@@ -37,71 +39,82 @@ func ProcessTemplate(context context.Context, data interface{}, template *templa
 	err := template.Execute(&b, data)
 	if err != nil {
 		log.Error(err, "Error executing template", "template", template)
-		return &obj, err
+		return &obj, newTemplateError(template.Name(), b.Bytes(), err)
 	}
 
-	bb, err := yaml.YAMLToJSON(b.Bytes())
+	objs, err := ParseManifests(b.Bytes())
 	if err != nil {
-		log.Error(err, "Error transforming yaml to json", "manifest", b.String())
-		return &obj, err
+		log.Error(err, "Error parsing manifest", "manifest", b.String())
+		return &obj, newRenderedError(template.Name(), b.Bytes(), err)
 	}
-
-	err = obj.UnmarshalJSON(bb)
-	if err != nil {
-		log.Error(err, "Error unmarshalling json manifest", "manifest", string(bb))
-		return &obj, err
+	if len(objs) != 1 {
+		err := fmt.Errorf("expected one object, found %d", len(objs))
+		log.Error(err, "Error parsing manifest", "manifest", b.String())
+		return &obj, newRenderedError(template.Name(), b.Bytes(), err)
 	}
-	return &obj, err
+	return &objs[0], nil
 }
 
 // ProcessTemplateArray processes an initialized Go template with a set of data. It expects an arrays of API objects to be defined in the template. Dishomogeneus types are supported
+// The template can also render a stream of YAML documents separated by ---, and Lists, whose items are flattened, see ParseManifests.
 // requires a context with log
 func ProcessTemplateArray(context context.Context, data interface{}, template *template.Template) ([]unstructured.Unstructured, error) {
 	log := log.FromContext(context)
-	objs := []unstructured.Unstructured{}
 	var b bytes.Buffer
 	err := template.Execute(&b, data)
 	if err != nil {
 		log.Error(err, "Error executing template", "template", template)
+		return []unstructured.Unstructured{}, newTemplateError(template.Name(), b.Bytes(), err)
+	}
+	objs, err := ParseManifests(b.Bytes())
+	if err != nil {
+		log.Error(err, "Error parsing manifests", "manifests", b.String())
+		return []unstructured.Unstructured{}, newRenderedError(template.Name(), b.Bytes(), err)
+	}
+	return objs, nil
+}
+
+// ProcessTemplateArrayStrict works as ProcessTemplateArray in strict mode: references to missing map keys are errors, instead of rendering as <no value>,
+// and the rendered objects must conform to validationSchema, if not nil, which also rejects the fields that are unknown to the schema. See GetValidationSchema.
+// requires a context with log
+func ProcessTemplateArrayStrict(context context.Context, data interface{}, template *template.Template, validationSchema validation.Schema) ([]unstructured.Unstructured, error) {
+	strict, err := template.Clone()
+	if err != nil {
 		return []unstructured.Unstructured{}, err
 	}
-	bb, err := yaml.YAMLToJSON(b.Bytes())
+	// include and tpl are bound again, so that the included templates are executed in strict mode too
+	strict = bindIncludeFunctions(strict.Option("missingkey=error"), new(int32))
+	objs, err := ProcessTemplateArray(context, data, strict)
 	if err != nil {
-		log.Error(err, "Error transforming yaml to json", "manifest", b.String())
 		return []unstructured.Unstructured{}, err
 	}
-	if !IsJSONArray(bb) {
-		obj := unstructured.Unstructured{}
-		err = obj.UnmarshalJSON(bb)
-		objs = append(objs, obj)
-	} else {
-		intfs := &[]interface{}{}
-		err = json.Unmarshal(bb, &intfs)
+	if validationSchema == nil {
+		return objs, nil
+	}
+	for i := range objs {
+		err := ValidateUnstructured(context, &objs[i], validationSchema)
 		if err != nil {
-			log.Error(err, "Error unmarshalling json manifest", "manifest", string(bb))
-			return []unstructured.Unstructured{}, err
-		}
-		for _, intf := range *intfs {
-			b, err := json.Marshal(intf)
-			if err != nil {
-				log.Error(err, "Error marshalling", "interface", intf)
-				return []unstructured.Unstructured{}, err
-			}
-			obj := unstructured.Unstructured{}
-			err = obj.UnmarshalJSON(b)
-			if err != nil {
-				log.Error(err, "Error unmarshalling", "json", string(b))
-				return []unstructured.Unstructured{}, err
-			}
-			objs = append(objs, obj)
+			return []unstructured.Unstructured{}, &TemplateError{Template: template.Name(), Err: err}
 		}
 	}
+	return objs, nil
+}
 
+// GetValidationSchema returns a schema that validates objects against the openapi schema of the cluster identified by config
+func GetValidationSchema(config *rest.Config) (validation.Schema, error) {
+	discoveryCache, err := discoveryclient.GetDiscoveryCache(config)
 	if err != nil {
-		log.Error(err, "Error unmarshalling json manifest", "manifest", string(bb))
-		return []unstructured.Unstructured{}, err
+		return nil, err
+	}
+	doc, err := discoveryCache.Discovery().OpenAPISchema()
+	if err != nil {
+		return nil, err
+	}
+	resources, err := openapi.NewOpenAPIData(doc)
+	if err != nil {
+		return nil, err
 	}
-	return objs, err
+	return validation.NewSchemaValidation(resources), nil
 }
 
 // ValidateUnstructured validates the content of an unstructured against an openapi schema.
@@ -0,0 +1,152 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	openapi_v2 "github.com/google/gnostic-models/openapiv2"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/client-go/rest"
+)
+
+// openAPISchema defines only the ConfigMap, the other types are not validated against the schema
+const openAPISchema = `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "v1"},
+  "paths": {},
+  "definitions": {
+    "io.k8s.api.core.v1.ConfigMap": {
+      "type": "object",
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"type": "object"},
+        "data": {"type": "object", "additionalProperties": {"type": "string"}}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "", "kind": "ConfigMap", "version": "v1"}]
+    }
+  }
+}`
+
+// newTestConfig returns the rest config of a fake apiserver serving the passed openapi schema, or no schema if it is empty
+func newTestConfig(t *testing.T, schema string) *rest.Config {
+	var openAPIData []byte
+	if schema != "" {
+		document, err := openapi_v2.ParseDocument([]byte(schema))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		openAPIData, err = proto.Marshal(document)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openapi/v2" || openAPIData == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(openAPIData)
+	}))
+	t.Cleanup(server.Close)
+	return &rest.Config{Host: server.URL}
+}
+
+func TestGetValidationSchema(t *testing.T) {
+	_, err := GetValidationSchema(newTestConfig(t, openAPISchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = GetValidationSchema(newTestConfig(t, ""))
+	if err == nil {
+		t.Error("expected an error when the cluster does not serve an openapi schema")
+	}
+}
+
+func TestProcessTemplateArrayStrict(t *testing.T) {
+	schema, err := GetValidationSchema(newTestConfig(t, openAPISchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			name: "valid",
+			text: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .name }}\ndata:\n  key: value\n",
+		},
+		{
+			name: "type unknown to the schema",
+			text: "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: {{ .name }}\nspec:\n  key: value\n",
+		},
+		{
+			name:     "missing key",
+			text:     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .missing }}\n",
+			expected: []string{"template objectTemplate, line 4, column 12", `map has no entry for key "missing"`},
+		},
+		{
+			name:     "unknown field",
+			text:     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .name }}\nspec:\n  key: value\n",
+			expected: []string{"template objectTemplate: ", `unknown field "spec"`},
+		},
+		{
+			name:     "invalid second object",
+			text:     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\ndata: [a]\n",
+			expected: []string{"template objectTemplate: ", "data"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := NewTemplate("objectTemplate", test.text, nil, logr.Discard(), nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			objs, err := ProcessTemplateArrayStrict(context.TODO(), map[string]interface{}{"name": "app"}, tmpl, schema)
+			if len(test.expected) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(objs) != 1 || objs[0].GetName() != "app" {
+					t.Errorf("expected one object named app, got %v", objs)
+				}
+				return
+			}
+			var templateError *TemplateError
+			if !errors.As(err, &templateError) {
+				t.Fatalf("expected a TemplateError, got %v", err)
+			}
+			for _, message := range test.expected {
+				if !strings.Contains(err.Error(), message) {
+					t.Errorf("expected error %q to contain %q", err.Error(), message)
+				}
+			}
+			if len(objs) != 0 {
+				t.Errorf("expected no objects, got %v", objs)
+			}
+		})
+	}
+	// the template is not changed, missing keys are rendered as <no value> outside of strict mode
+	tmpl, err := NewTemplate("objectTemplate", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .missing }}\n", nil, logr.Discard(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = ProcessTemplateArrayStrict(context.TODO(), map[string]interface{}{}, tmpl, nil)
+	if err == nil {
+		t.Fatal("expected an error for the missing key")
+	}
+	objs, err := ProcessTemplateArray(context.TODO(), map[string]interface{}{}, tmpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(objs) != 1 || objs[0].GetName() != "<no value>" {
+		t.Errorf("expected the missing key to render as <no value>, got %v", objs)
+	}
+}